@@ -0,0 +1,427 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"regexp"
+	"runtime"
+	"strconv"
+	"time"
+)
+
+const (
+	// defaultListenAddr is the address the server listens on
+	defaultListenAddr = ":8080"
+
+	// defaultShutdownTimeout is how long shutdown waits for requests and running jobs
+	defaultShutdownTimeout = 30 * time.Second
+
+	// defaultMaxUploadSize is the largest accepted upload in bytes
+	defaultMaxUploadSize = 10 << 30
+
+	// envPrefix is prepended to the name of every configuration environment variable
+	envPrefix = "CSV_PROCESSOR_"
+)
+
+// Duration is a time.Duration that is written as a string such as "24h" in config files
+type Duration struct {
+	time.Duration
+}
+
+// MarshalJSON encodes the duration as a string
+func (d Duration) MarshalJSON() ([]byte, error) {
+	return json.Marshal(d.String())
+}
+
+// UnmarshalJSON decodes a duration string such as "90m"
+func (d *Duration) UnmarshalJSON(data []byte) error {
+	var value string
+	if err := json.Unmarshal(data, &value); err != nil {
+		return fmt.Errorf("duration must be a string such as \"24h\": %w", err)
+	}
+	parsed, err := time.ParseDuration(value)
+	if err != nil {
+		return err
+	}
+	d.Duration = parsed
+	return nil
+}
+
+// RetentionConfig controls how long files and job records are kept
+type RetentionConfig struct {
+	UploadTTL       Duration `json:"upload_ttl"`
+	ProcessedTTL    Duration `json:"processed_ttl"`
+	JobTTL          Duration `json:"job_ttl"`
+	JanitorInterval Duration `json:"janitor_interval"`
+}
+
+// Policy returns the retention policy described by the configuration
+func (rc RetentionConfig) Policy() RetentionPolicy {
+	return RetentionPolicy{
+		UploadTTL:    rc.UploadTTL.Duration,
+		ProcessedTTL: rc.ProcessedTTL.Duration,
+		JobTTL:       rc.JobTTL.Duration,
+	}
+}
+
+// ValidationConfig controls how email addresses are validated
+type ValidationConfig struct {
+	Profile      string      `json:"profile"`
+	EmailPattern string      `json:"email_pattern"`
+	SMTPUTF8     bool        `json:"smtputf8"`
+	DNS          DNSConfig   `json:"dns"`
+	SMTP         SMTPConfig  `json:"smtp"`
+	Lists        ListsConfig `json:"lists"`
+
+	// Policies are named domain policies uploads may select with policy=<name>
+	Policies map[string]DomainPolicyConfig `json:"policies"`
+}
+
+// DNSConfig controls the optional check that email domains can receive mail
+type DNSConfig struct {
+	Enabled          bool     `json:"enabled"`
+	Resolver         string   `json:"resolver"`
+	Timeout          Duration `json:"timeout"`
+	CacheTTL         Duration `json:"cache_ttl"`
+	NegativeCacheTTL Duration `json:"negative_cache_ttl"`
+	MaxConcurrent    int      `json:"max_concurrent"`
+}
+
+// CheckerOptions returns the domain checker options described by the configuration
+func (dc DNSConfig) CheckerOptions() DomainCheckerOptions {
+	return DomainCheckerOptions{
+		Resolver:         dc.Resolver,
+		Timeout:          dc.Timeout.Duration,
+		CacheTTL:         dc.CacheTTL.Duration,
+		NegativeCacheTTL: dc.NegativeCacheTTL.Duration,
+		MaxConcurrent:    dc.MaxConcurrent,
+	}
+}
+
+// SMTPConfig controls the optional SMTP mailbox verification uploads may request
+type SMTPConfig struct {
+	Enabled       bool     `json:"enabled"`
+	Server        string   `json:"server"`
+	HeloName      string   `json:"helo_name"`
+	MailFrom      string   `json:"mail_from"`
+	Timeout       Duration `json:"timeout"`
+	MaxConcurrent int      `json:"max_concurrent"`
+}
+
+// ProberOptions returns the SMTP prober options described by the
+// configuration, finding mail exchangers through resolver
+func (sc SMTPConfig) ProberOptions(resolver string) SMTPProberOptions {
+	return SMTPProberOptions{
+		Server:        sc.Server,
+		Resolver:      resolver,
+		HeloName:      sc.HeloName,
+		MailFrom:      sc.MailFrom,
+		Timeout:       sc.Timeout.Duration,
+		MaxConcurrent: sc.MaxConcurrent,
+	}
+}
+
+// ListsConfig names files of disposable and free-mail domains and of role
+// account local parts that extend the embedded defaults
+type ListsConfig struct {
+	DisposableFile    string   `json:"disposable_file"`
+	FreeProvidersFile string   `json:"free_providers_file"`
+	RoleAccountsFile  string   `json:"role_accounts_file"`
+	ReloadInterval    Duration `json:"reload_interval"`
+}
+
+// Config holds the server configuration
+type Config struct {
+	ListenAddr       string           `json:"listen_addr"`
+	StorageDir       string           `json:"storage_dir"`
+	MaxUploadSize    int64            `json:"max_upload_size"`
+	MaxValidateBatch int              `json:"max_validate_batch"`
+	MaxRowErrors     int              `json:"max_row_errors"`
+	Workers          int              `json:"workers"`
+	QueueSize        int              `json:"queue_size"`
+	ShutdownTimeout  Duration         `json:"shutdown_timeout"`
+	Retention        RetentionConfig  `json:"retention"`
+	Validation       ValidationConfig `json:"validation"`
+}
+
+// DefaultConfig returns the configuration used when nothing is overridden
+func DefaultConfig() *Config {
+	return &Config{
+		ListenAddr:       defaultListenAddr,
+		StorageDir:       defaultStorageDir,
+		MaxUploadSize:    defaultMaxUploadSize,
+		MaxValidateBatch: defaultMaxValidateBatch,
+		MaxRowErrors:     defaultMaxRowErrors,
+		Workers:          runtime.NumCPU(),
+		QueueSize:        defaultQueueSize,
+		ShutdownTimeout:  Duration{defaultShutdownTimeout},
+		Retention: RetentionConfig{
+			UploadTTL:       Duration{defaultUploadTTL},
+			ProcessedTTL:    Duration{defaultProcessedTTL},
+			JobTTL:          Duration{defaultJobTTL},
+			JanitorInterval: Duration{defaultJanitorInterval},
+		},
+		Validation: ValidationConfig{
+			Profile:  string(defaultValidationProfile),
+			SMTPUTF8: true,
+			DNS: DNSConfig{
+				Timeout:          Duration{defaultDNSTimeout},
+				CacheTTL:         Duration{defaultDNSCacheTTL},
+				NegativeCacheTTL: Duration{defaultDNSNegativeCacheTTL},
+				MaxConcurrent:    defaultDNSMaxConcurrent,
+			},
+			SMTP: SMTPConfig{
+				HeloName:      defaultSMTPHeloName,
+				MailFrom:      defaultSMTPMailFrom,
+				Timeout:       Duration{defaultSMTPTimeout},
+				MaxConcurrent: defaultSMTPMaxConcurrent,
+			},
+			Lists: ListsConfig{
+				ReloadInterval: Duration{defaultDomainListReloadInterval},
+			},
+		},
+	}
+}
+
+// LoadConfig builds the configuration from defaults, an optional JSON config
+// file, environment variables and command-line flags, in increasing order of
+// precedence. The config file is named by the -config flag or the
+// CSV_PROCESSOR_CONFIG environment variable.
+func LoadConfig(args []string, getenv func(string) string) (*Config, error) {
+	// First pass only looks for the config file location; errors are
+	// reported by the second pass
+	scan := flag.NewFlagSet("csv-processor", flag.ContinueOnError)
+	scan.SetOutput(io.Discard)
+	configPath := scan.String("config", getenv(envPrefix+"CONFIG"), "")
+	registerFlags(scan, DefaultConfig())
+	scan.Parse(args)
+
+	cfg := DefaultConfig()
+	if *configPath != "" {
+		if err := cfg.loadFile(*configPath); err != nil {
+			return nil, err
+		}
+	}
+	if err := cfg.applyEnv(getenv); err != nil {
+		return nil, err
+	}
+
+	// Second pass applies only the flags that were given, on top of file and env
+	flags := flag.NewFlagSet("csv-processor", flag.ContinueOnError)
+	flags.String("config", *configPath, "Path to a JSON config file (env "+envPrefix+"CONFIG)")
+	registerFlags(flags, cfg)
+	if err := flags.Parse(args); err != nil {
+		return nil, err
+	}
+
+	if err := cfg.Validate(); err != nil {
+		return nil, err
+	}
+	return cfg, nil
+}
+
+// registerFlags defines a flag for every setting, defaulting to the current value in cfg
+func registerFlags(fs *flag.FlagSet, cfg *Config) {
+	fs.StringVar(&cfg.ListenAddr, "addr", cfg.ListenAddr, "Listen address (env "+envPrefix+"LISTEN_ADDR)")
+	fs.StringVar(&cfg.StorageDir, "storage-dir", cfg.StorageDir, "Directory for uploaded and processed files (env "+envPrefix+"STORAGE_DIR)")
+	fs.Int64Var(&cfg.MaxUploadSize, "max-upload-size", cfg.MaxUploadSize, "Maximum upload size in bytes, 0 for no limit (env "+envPrefix+"MAX_UPLOAD_SIZE)")
+	fs.IntVar(&cfg.MaxValidateBatch, "max-validate-batch", cfg.MaxValidateBatch, "Maximum number of addresses per /API/validate request (env "+envPrefix+"MAX_VALIDATE_BATCH)")
+	fs.IntVar(&cfg.MaxRowErrors, "max-row-errors", cfg.MaxRowErrors, "Most malformed rows a lenient job tolerates, 0 for no limit (env "+envPrefix+"MAX_ROW_ERRORS)")
+	fs.IntVar(&cfg.Workers, "workers", cfg.Workers, "Number of processing workers (env "+envPrefix+"WORKERS)")
+	fs.IntVar(&cfg.QueueSize, "queue-size", cfg.QueueSize, "Maximum number of queued jobs (env "+envPrefix+"QUEUE_SIZE)")
+	fs.DurationVar(&cfg.ShutdownTimeout.Duration, "shutdown-timeout", cfg.ShutdownTimeout.Duration, "How long shutdown waits for requests and running jobs (env "+envPrefix+"SHUTDOWN_TIMEOUT)")
+	fs.DurationVar(&cfg.Retention.UploadTTL.Duration, "upload-ttl", cfg.Retention.UploadTTL.Duration, "How long raw uploads are kept, 0 to keep forever (env "+envPrefix+"UPLOAD_TTL)")
+	fs.DurationVar(&cfg.Retention.ProcessedTTL.Duration, "processed-ttl", cfg.Retention.ProcessedTTL.Duration, "How long processed files are kept, 0 to keep forever (env "+envPrefix+"PROCESSED_TTL)")
+	fs.DurationVar(&cfg.Retention.JobTTL.Duration, "job-ttl", cfg.Retention.JobTTL.Duration, "How long finished job records are kept, 0 to keep forever (env "+envPrefix+"JOB_TTL)")
+	fs.DurationVar(&cfg.Retention.JanitorInterval.Duration, "janitor-interval", cfg.Retention.JanitorInterval.Duration, "How often expired data is removed (env "+envPrefix+"JANITOR_INTERVAL)")
+	fs.StringVar(&cfg.Validation.Profile, "email-profile", cfg.Validation.Profile, "Email validation profile: strict, practical or permissive (env "+envPrefix+"EMAIL_PROFILE)")
+	fs.StringVar(&cfg.Validation.EmailPattern, "email-pattern", cfg.Validation.EmailPattern, "Optional regular expression a valid email address must also match (env "+envPrefix+"EMAIL_PATTERN)")
+	fs.BoolVar(&cfg.Validation.SMTPUTF8, "smtputf8", cfg.Validation.SMTPUTF8, "Accept internationalized addresses with Unicode local parts and domains (env "+envPrefix+"SMTPUTF8)")
+	fs.BoolVar(&cfg.Validation.DNS.Enabled, "dns-check", cfg.Validation.DNS.Enabled, "Reject addresses whose domain has no MX, A or AAAA records (env "+envPrefix+"DNS_CHECK)")
+	fs.StringVar(&cfg.Validation.DNS.Resolver, "dns-resolver", cfg.Validation.DNS.Resolver, "DNS server host:port for domain checks, system resolver if empty (env "+envPrefix+"DNS_RESOLVER)")
+	fs.DurationVar(&cfg.Validation.DNS.Timeout.Duration, "dns-timeout", cfg.Validation.DNS.Timeout.Duration, "Timeout for a single domain lookup (env "+envPrefix+"DNS_TIMEOUT)")
+	fs.DurationVar(&cfg.Validation.DNS.CacheTTL.Duration, "dns-cache-ttl", cfg.Validation.DNS.CacheTTL.Duration, "How long domains that can receive mail are cached (env "+envPrefix+"DNS_CACHE_TTL)")
+	fs.DurationVar(&cfg.Validation.DNS.NegativeCacheTTL.Duration, "dns-negative-cache-ttl", cfg.Validation.DNS.NegativeCacheTTL.Duration, "How long domains without a mail server are cached (env "+envPrefix+"DNS_NEGATIVE_CACHE_TTL)")
+	fs.IntVar(&cfg.Validation.DNS.MaxConcurrent, "dns-max-concurrent", cfg.Validation.DNS.MaxConcurrent, "Maximum concurrent domain lookups (env "+envPrefix+"DNS_MAX_CONCURRENT)")
+	fs.BoolVar(&cfg.Validation.SMTP.Enabled, "smtp-probe", cfg.Validation.SMTP.Enabled, "Allow uploads to request SMTP mailbox verification (env "+envPrefix+"SMTP_PROBE)")
+	fs.StringVar(&cfg.Validation.SMTP.Server, "smtp-server", cfg.Validation.SMTP.Server, "SMTP host:port to probe instead of each domain's MX (env "+envPrefix+"SMTP_SERVER)")
+	fs.StringVar(&cfg.Validation.SMTP.HeloName, "smtp-helo", cfg.Validation.SMTP.HeloName, "Name sent in EHLO when probing (env "+envPrefix+"SMTP_HELO)")
+	fs.StringVar(&cfg.Validation.SMTP.MailFrom, "smtp-mail-from", cfg.Validation.SMTP.MailFrom, "Envelope sender used when probing (env "+envPrefix+"SMTP_MAIL_FROM)")
+	fs.DurationVar(&cfg.Validation.SMTP.Timeout.Duration, "smtp-timeout", cfg.Validation.SMTP.Timeout.Duration, "Timeout for a single mailbox probe (env "+envPrefix+"SMTP_TIMEOUT)")
+	fs.IntVar(&cfg.Validation.SMTP.MaxConcurrent, "smtp-max-concurrent", cfg.Validation.SMTP.MaxConcurrent, "Maximum concurrent mailbox probes (env "+envPrefix+"SMTP_MAX_CONCURRENT)")
+	fs.StringVar(&cfg.Validation.Lists.DisposableFile, "disposable-domains-file", cfg.Validation.Lists.DisposableFile, "File of extra disposable email domains, one per line (env "+envPrefix+"DISPOSABLE_DOMAINS_FILE)")
+	fs.StringVar(&cfg.Validation.Lists.FreeProvidersFile, "free-providers-file", cfg.Validation.Lists.FreeProvidersFile, "File of extra free-mail provider domains, one per line (env "+envPrefix+"FREE_PROVIDERS_FILE)")
+	fs.StringVar(&cfg.Validation.Lists.RoleAccountsFile, "role-accounts-file", cfg.Validation.Lists.RoleAccountsFile, "File of extra role account local parts, one per line (env "+envPrefix+"ROLE_ACCOUNTS_FILE)")
+	fs.DurationVar(&cfg.Validation.Lists.ReloadInterval.Duration, "domain-lists-reload-interval", cfg.Validation.Lists.ReloadInterval.Duration, "How often list files are checked for changes (env "+envPrefix+"DOMAIN_LISTS_RELOAD_INTERVAL)")
+}
+
+// loadFile overlays the settings in a JSON config file onto cfg
+func (cfg *Config) loadFile(path string) error {
+	file, err := os.Open(path)
+	if err != nil {
+		return fmt.Errorf("failed to open config file: %w", err)
+	}
+	defer file.Close()
+
+	decoder := json.NewDecoder(file)
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(cfg); err != nil {
+		return fmt.Errorf("failed to parse config file %s: %w", path, err)
+	}
+	return nil
+}
+
+// applyEnv overlays settings from CSV_PROCESSOR_* environment variables onto cfg
+func (cfg *Config) applyEnv(getenv func(string) string) error {
+	stringSettings := map[string]*string{
+		"LISTEN_ADDR":             &cfg.ListenAddr,
+		"STORAGE_DIR":             &cfg.StorageDir,
+		"EMAIL_PROFILE":           &cfg.Validation.Profile,
+		"EMAIL_PATTERN":           &cfg.Validation.EmailPattern,
+		"DNS_RESOLVER":            &cfg.Validation.DNS.Resolver,
+		"SMTP_SERVER":             &cfg.Validation.SMTP.Server,
+		"SMTP_HELO":               &cfg.Validation.SMTP.HeloName,
+		"SMTP_MAIL_FROM":          &cfg.Validation.SMTP.MailFrom,
+		"DISPOSABLE_DOMAINS_FILE": &cfg.Validation.Lists.DisposableFile,
+		"FREE_PROVIDERS_FILE":     &cfg.Validation.Lists.FreeProvidersFile,
+		"ROLE_ACCOUNTS_FILE":      &cfg.Validation.Lists.RoleAccountsFile,
+	}
+	for name, target := range stringSettings {
+		if value := getenv(envPrefix + name); value != "" {
+			*target = value
+		}
+	}
+
+	intSettings := map[string]*int{
+		"MAX_VALIDATE_BATCH":  &cfg.MaxValidateBatch,
+		"MAX_ROW_ERRORS":      &cfg.MaxRowErrors,
+		"WORKERS":             &cfg.Workers,
+		"QUEUE_SIZE":          &cfg.QueueSize,
+		"DNS_MAX_CONCURRENT":  &cfg.Validation.DNS.MaxConcurrent,
+		"SMTP_MAX_CONCURRENT": &cfg.Validation.SMTP.MaxConcurrent,
+	}
+	for name, target := range intSettings {
+		if value := getenv(envPrefix + name); value != "" {
+			parsed, err := strconv.Atoi(value)
+			if err != nil {
+				return fmt.Errorf("invalid %s%s: %w", envPrefix, name, err)
+			}
+			*target = parsed
+		}
+	}
+
+	if value := getenv(envPrefix + "MAX_UPLOAD_SIZE"); value != "" {
+		parsed, err := strconv.ParseInt(value, 10, 64)
+		if err != nil {
+			return fmt.Errorf("invalid %sMAX_UPLOAD_SIZE: %w", envPrefix, err)
+		}
+		cfg.MaxUploadSize = parsed
+	}
+
+	boolSettings := map[string]*bool{
+		"SMTPUTF8":   &cfg.Validation.SMTPUTF8,
+		"DNS_CHECK":  &cfg.Validation.DNS.Enabled,
+		"SMTP_PROBE": &cfg.Validation.SMTP.Enabled,
+	}
+	for name, target := range boolSettings {
+		if value := getenv(envPrefix + name); value != "" {
+			parsed, err := strconv.ParseBool(value)
+			if err != nil {
+				return fmt.Errorf("invalid %s%s: %w", envPrefix, name, err)
+			}
+			*target = parsed
+		}
+	}
+
+	durationSettings := map[string]*time.Duration{
+		"SHUTDOWN_TIMEOUT":             &cfg.ShutdownTimeout.Duration,
+		"UPLOAD_TTL":                   &cfg.Retention.UploadTTL.Duration,
+		"PROCESSED_TTL":                &cfg.Retention.ProcessedTTL.Duration,
+		"JOB_TTL":                      &cfg.Retention.JobTTL.Duration,
+		"JANITOR_INTERVAL":             &cfg.Retention.JanitorInterval.Duration,
+		"DNS_TIMEOUT":                  &cfg.Validation.DNS.Timeout.Duration,
+		"DNS_CACHE_TTL":                &cfg.Validation.DNS.CacheTTL.Duration,
+		"DNS_NEGATIVE_CACHE_TTL":       &cfg.Validation.DNS.NegativeCacheTTL.Duration,
+		"SMTP_TIMEOUT":                 &cfg.Validation.SMTP.Timeout.Duration,
+		"DOMAIN_LISTS_RELOAD_INTERVAL": &cfg.Validation.Lists.ReloadInterval.Duration,
+	}
+	for name, target := range durationSettings {
+		if value := getenv(envPrefix + name); value != "" {
+			parsed, err := time.ParseDuration(value)
+			if err != nil {
+				return fmt.Errorf("invalid %s%s: %w", envPrefix, name, err)
+			}
+			*target = parsed
+		}
+	}
+
+	return nil
+}
+
+// Validate checks that the configuration is usable
+func (cfg *Config) Validate() error {
+	if cfg.ListenAddr == "" {
+		return errors.New("listen address must not be empty")
+	}
+	if cfg.StorageDir == "" {
+		return errors.New("storage directory must not be empty")
+	}
+	if cfg.MaxUploadSize < 0 {
+		return errors.New("max upload size must not be negative")
+	}
+	if cfg.MaxValidateBatch < 1 {
+		return errors.New("max validate batch must be at least 1")
+	}
+	if cfg.MaxRowErrors < 0 {
+		return errors.New("max row errors must not be negative")
+	}
+	if cfg.Workers < 1 {
+		return errors.New("workers must be at least 1")
+	}
+	if cfg.QueueSize < 1 {
+		return errors.New("queue size must be at least 1")
+	}
+	if cfg.ShutdownTimeout.Duration <= 0 {
+		return errors.New("shutdown timeout must be positive")
+	}
+	if cfg.Retention.UploadTTL.Duration < 0 || cfg.Retention.ProcessedTTL.Duration < 0 || cfg.Retention.JobTTL.Duration < 0 {
+		return errors.New("retention TTLs must not be negative")
+	}
+	if cfg.Retention.JanitorInterval.Duration <= 0 {
+		return errors.New("janitor interval must be positive")
+	}
+	if _, err := ParseValidationProfile(cfg.Validation.Profile); err != nil {
+		return err
+	}
+	if _, err := regexp.Compile(cfg.Validation.EmailPattern); err != nil {
+		return fmt.Errorf("invalid email pattern: %w", err)
+	}
+	if dns := cfg.Validation.DNS; dns.Timeout.Duration <= 0 || dns.CacheTTL.Duration <= 0 || dns.NegativeCacheTTL.Duration <= 0 {
+		return errors.New("DNS timeout and cache TTLs must be positive")
+	}
+	if cfg.Validation.DNS.MaxConcurrent < 1 {
+		return errors.New("DNS max concurrent lookups must be at least 1")
+	}
+	if cfg.Validation.SMTP.Timeout.Duration <= 0 {
+		return errors.New("SMTP timeout must be positive")
+	}
+	if cfg.Validation.SMTP.MaxConcurrent < 1 {
+		return errors.New("SMTP max concurrent probes must be at least 1")
+	}
+	if cfg.Validation.SMTP.HeloName == "" || cfg.Validation.SMTP.MailFrom == "" {
+		return errors.New("SMTP HELO name and sender must not be empty")
+	}
+	if cfg.Validation.Lists.ReloadInterval.Duration <= 0 {
+		return errors.New("domain list reload interval must be positive")
+	}
+	for name, policy := range cfg.Validation.Policies {
+		if _, err := NewDomainPolicy(policy.Allow, policy.Deny); err != nil {
+			return fmt.Errorf("policy %q: %w", name, err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,194 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"flag"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// envMap returns a getenv function backed by a map
+func envMap(values map[string]string) func(string) string {
+	return func(name string) string {
+		return values[name]
+	}
+}
+
+func TestDefaultConfig(t *testing.T) {
+	cfg := DefaultConfig()
+
+	if cfg.ListenAddr != ":8080" {
+		t.Errorf("ListenAddr mismatch. Expected: :8080, Got: %s", cfg.ListenAddr)
+	}
+	if cfg.StorageDir != "uploads" {
+		t.Errorf("StorageDir mismatch. Expected: uploads, Got: %s", cfg.StorageDir)
+	}
+	if cfg.MaxUploadSize != 10<<30 {
+		t.Errorf("MaxUploadSize mismatch. Expected: %d, Got: %d", 10<<30, cfg.MaxUploadSize)
+	}
+	if cfg.Workers < 1 {
+		t.Errorf("Expected at least one worker, got %d", cfg.Workers)
+	}
+	if err := cfg.Validate(); err != nil {
+		t.Errorf("Default config should be valid: %v", err)
+	}
+}
+
+func TestLoadConfigPrecedence(t *testing.T) {
+	configPath := filepath.Join(t.TempDir(), "config.json")
+	configFile := `{
+		"listen_addr": ":9000",
+		"storage_dir": "/data/from-file",
+		"workers": 2,
+		"queue_size": 20,
+		"retention": {"upload_ttl": "2h", "job_ttl": "48h"}
+	}`
+	if err := os.WriteFile(configPath, []byte(configFile), 0644); err != nil {
+		t.Fatalf("Failed to write config file: %v", err)
+	}
+
+	env := envMap(map[string]string{
+		"CSV_PROCESSOR_CONFIG":      configPath,
+		"CSV_PROCESSOR_STORAGE_DIR": "/data/from-env",
+		"CSV_PROCESSOR_WORKERS":     "4",
+		"CSV_PROCESSOR_JOB_TTL":     "72h",
+		"CSV_PROCESSOR_SMTPUTF8":    "false",
+	})
+	args := []string{"-workers", "8"}
+
+	cfg, err := LoadConfig(args, env)
+	if err != nil {
+		t.Fatalf("LoadConfig failed: %v", err)
+	}
+
+	tests := []struct {
+		name     string
+		actual   interface{}
+		expected interface{}
+	}{
+		{"listen address from file", cfg.ListenAddr, ":9000"},
+		{"storage dir from env over file", cfg.StorageDir, "/data/from-env"},
+		{"workers from flag over env and file", cfg.Workers, 8},
+		{"queue size from file", cfg.QueueSize, 20},
+		{"upload TTL from file", cfg.Retention.UploadTTL.Duration, 2 * time.Hour},
+		{"job TTL from env over file", cfg.Retention.JobTTL.Duration, 72 * time.Hour},
+		{"processed TTL default", cfg.Retention.ProcessedTTL.Duration, defaultProcessedTTL},
+		{"max upload size default", cfg.MaxUploadSize, int64(defaultMaxUploadSize)},
+		{"shutdown timeout default", cfg.ShutdownTimeout.Duration, defaultShutdownTimeout},
+		{"SMTPUTF8 from env", cfg.Validation.SMTPUTF8, false},
+		{"validation profile default", cfg.Validation.Profile, "practical"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if tt.actual != tt.expected {
+				t.Errorf("Expected: %v, Got: %v", tt.expected, tt.actual)
+			}
+		})
+	}
+}
+
+func TestLoadConfigFlagSelectsFile(t *testing.T) {
+	configPath := filepath.Join(t.TempDir(), "config.json")
+	if err := os.WriteFile(configPath, []byte(`{"listen_addr": "127.0.0.1:9999"}`), 0644); err != nil {
+		t.Fatalf("Failed to write config file: %v", err)
+	}
+
+	cfg, err := LoadConfig([]string{"-config", configPath}, envMap(nil))
+	if err != nil {
+		t.Fatalf("LoadConfig failed: %v", err)
+	}
+	if cfg.ListenAddr != "127.0.0.1:9999" {
+		t.Errorf("ListenAddr mismatch. Expected: 127.0.0.1:9999, Got: %s", cfg.ListenAddr)
+	}
+}
+
+func TestLoadConfigErrors(t *testing.T) {
+	dir := t.TempDir()
+	unknownField := filepath.Join(dir, "unknown.json")
+	os.WriteFile(unknownField, []byte(`{"listen_port": 8080}`), 0644)
+	badPolicy := filepath.Join(dir, "policy.json")
+	os.WriteFile(badPolicy, []byte(`{"validation": {"policies": {"acme": {"allow": ["acme.[com"]}}}}`), 0644)
+	badDuration := filepath.Join(dir, "duration.json")
+	os.WriteFile(badDuration, []byte(`{"retention": {"job_ttl": "forever"}}`), 0644)
+
+	tests := []struct {
+		name string
+		args []string
+		env  map[string]string
+	}{
+		{"Missing config file", []string{"-config", filepath.Join(dir, "missing.json")}, nil},
+		{"Unknown config field", []string{"-config", unknownField}, nil},
+		{"Invalid duration in file", []string{"-config", badDuration}, nil},
+		{"Invalid policy pattern in file", []string{"-config", badPolicy}, nil},
+		{"Invalid env integer", nil, map[string]string{"CSV_PROCESSOR_WORKERS": "many"}},
+		{"Invalid env duration", nil, map[string]string{"CSV_PROCESSOR_UPLOAD_TTL": "1 day"}},
+		{"Invalid env boolean", nil, map[string]string{"CSV_PROCESSOR_SMTPUTF8": "sometimes"}},
+		{"Unknown flag", []string{"-port", "8080"}, nil},
+		{"Zero workers", []string{"-workers", "0"}, nil},
+		{"Zero validate batch", []string{"-max-validate-batch", "0"}, nil},
+		{"Negative row errors", []string{"-max-row-errors", "-1"}, nil},
+		{"Negative TTL", []string{"-job-ttl", "-1h"}, nil},
+		{"Zero shutdown timeout", []string{"-shutdown-timeout", "0s"}, nil},
+		{"Invalid email pattern", []string{"-email-pattern", "("}, nil},
+		{"Unknown email profile", nil, map[string]string{"CSV_PROCESSOR_EMAIL_PROFILE": "lenient"}},
+		{"Zero DNS timeout", []string{"-dns-timeout", "0s"}, nil},
+		{"Zero DNS concurrency", nil, map[string]string{"CSV_PROCESSOR_DNS_MAX_CONCURRENT": "0"}},
+		{"Zero SMTP timeout", []string{"-smtp-timeout", "0s"}, nil},
+		{"Empty SMTP sender", []string{"-smtp-mail-from", ""}, nil},
+		{"Zero list reload interval", []string{"-domain-lists-reload-interval", "0s"}, nil},
+		{"Invalid env SMTP probe", nil, map[string]string{"CSV_PROCESSOR_SMTP_PROBE": "sometimes"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := LoadConfig(tt.args, envMap(tt.env)); err == nil {
+				t.Error("Expected error")
+			}
+		})
+	}
+}
+
+func TestLoadConfigHelp(t *testing.T) {
+	_, err := LoadConfig([]string{"-h"}, envMap(nil))
+	if !errors.Is(err, flag.ErrHelp) {
+		t.Errorf("Expected flag.ErrHelp, got %v", err)
+	}
+}
+
+func TestDurationJSON(t *testing.T) {
+	data, err := json.Marshal(Duration{90 * time.Minute})
+	if err != nil {
+		t.Fatalf("Marshal failed: %v", err)
+	}
+	if string(data) != `"1h30m0s"` {
+		t.Errorf("Marshal mismatch. Expected: \"1h30m0s\", Got: %s", string(data))
+	}
+
+	var d Duration
+	if err := json.Unmarshal([]byte(`"45s"`), &d); err != nil {
+		t.Fatalf("Unmarshal failed: %v", err)
+	}
+	if d.Duration != 45*time.Second {
+		t.Errorf("Unmarshal mismatch. Expected: 45s, Got: %v", d.Duration)
+	}
+
+	if err := json.Unmarshal([]byte(`45`), &d); err == nil {
+		t.Error("Expected error for numeric duration")
+	}
+}
+
+func TestRetentionConfigPolicy(t *testing.T) {
+	rc := RetentionConfig{
+		UploadTTL:    Duration{time.Hour},
+		ProcessedTTL: Duration{2 * time.Hour},
+		JobTTL:       Duration{3 * time.Hour},
+	}
+	expected := RetentionPolicy{UploadTTL: time.Hour, ProcessedTTL: 2 * time.Hour, JobTTL: 3 * time.Hour}
+	if rc.Policy() != expected {
+		t.Errorf("Policy mismatch. Expected: %+v, Got: %+v", expected, rc.Policy())
+	}
+}
@@ -0,0 +1,357 @@
+package main
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// dialectSampleBytes is how much of a file is read to detect its dialect
+const dialectSampleBytes = 64 << 10
+
+// dialectDelimiters are the delimiters tried when detecting a dialect, in order of preference
+var dialectDelimiters = []rune{',', ';', '\t', '|'}
+
+// CSVDialect describes how the rows of a CSV file are written
+type CSVDialect struct {
+	// Delimiter separates the fields of a row
+	Delimiter rune
+
+	// Quote encloses fields containing delimiters, quotes or line breaks;
+	// either a double or a single quote
+	Quote rune
+
+	// HasHeader reports whether the first row holds column names
+	HasHeader bool
+}
+
+// defaultDialect is RFC 4180 with a header row, used when nothing else is detected
+var defaultDialect = CSVDialect{Delimiter: ',', Quote: '"', HasHeader: true}
+
+// dialectJSON is the JSON form of CSVDialect, with characters as strings
+type dialectJSON struct {
+	Delimiter string `json:"delimiter"`
+	Quote     string `json:"quote"`
+	HasHeader bool   `json:"has_header"`
+}
+
+// MarshalJSON encodes the dialect with its characters as strings
+func (d CSVDialect) MarshalJSON() ([]byte, error) {
+	return json.Marshal(dialectJSON{string(d.Delimiter), string(d.Quote), d.HasHeader})
+}
+
+// UnmarshalJSON decodes a dialect encoded by MarshalJSON
+func (d *CSVDialect) UnmarshalJSON(data []byte) error {
+	var encoded dialectJSON
+	if err := json.Unmarshal(data, &encoded); err != nil {
+		return err
+	}
+	delimiter, _ := utf8.DecodeRuneInString(encoded.Delimiter)
+	quote, _ := utf8.DecodeRuneInString(encoded.Quote)
+	*d = CSVDialect{Delimiter: delimiter, Quote: quote, HasHeader: encoded.HasHeader}
+	return nil
+}
+
+// ParseDelimiter parses a delimiter given as a single character or as one
+// of the names comma, semicolon, tab and pipe; empty means detect it
+func ParseDelimiter(name string) (rune, error) {
+	switch strings.ToLower(name) {
+	case "":
+		return 0, nil
+	case "comma":
+		return ',', nil
+	case "semicolon":
+		return ';', nil
+	case "tab", `\t`:
+		return '\t', nil
+	case "pipe":
+		return '|', nil
+	}
+
+	delimiter, size := utf8.DecodeRuneInString(name)
+	if size != len(name) || delimiter == utf8.RuneError || strings.ContainsRune("\"'\r\n", delimiter) {
+		return 0, fmt.Errorf("invalid delimiter %q", name)
+	}
+	return delimiter, nil
+}
+
+// ParseQuote parses a quote character given as itself or as the names
+// double and single; empty means detect it
+func ParseQuote(name string) (rune, error) {
+	switch strings.ToLower(name) {
+	case "":
+		return 0, nil
+	case `"`, "double":
+		return '"', nil
+	case "'", "single":
+		return '\'', nil
+	default:
+		return 0, fmt.Errorf("invalid quote character %q", name)
+	}
+}
+
+// DetectDialect samples the start of the file at path to detect its
+// delimiter, quote character and whether its first row is a header.
+// Settings given in opts are used as they are rather than detected.
+func (cp *CSVProcessor) DetectDialect(path string, opts ProcessOptions) (CSVDialect, error) {
+	if opts.Delimiter != 0 && opts.Quote != 0 && opts.HasHeader != nil {
+		return CSVDialect{Delimiter: opts.Delimiter, Quote: opts.Quote, HasHeader: *opts.HasHeader}, nil
+	}
+
+	encoding, err := cp.DetectEncoding(path, opts)
+	if err != nil {
+		return CSVDialect{}, err
+	}
+
+	file, err := os.Open(path)
+	if err != nil {
+		return CSVDialect{}, fmt.Errorf("failed to open input file: %w", err)
+	}
+	defer file.Close()
+
+	// The sample is taken from the text decoded to UTF-8
+	sample := make([]byte, dialectSampleBytes)
+	n, err := io.ReadFull(encoding.decode(file), sample)
+	truncated := err == nil
+	if err != nil && err != io.EOF && err != io.ErrUnexpectedEOF {
+		return CSVDialect{}, fmt.Errorf("failed to read input file: %w", err)
+	}
+	return sniffDialect(sample[:n], truncated, opts), nil
+}
+
+// sniffDialect detects the dialect of sample, the start of a file that
+// continues past it when truncated is set
+func sniffDialect(sample []byte, truncated bool, opts ProcessOptions) CSVDialect {
+	// A line cut off by the end of the sample is left out
+	if truncated {
+		if newline := bytes.LastIndexByte(sample, '\n'); newline >= 0 {
+			sample = sample[:newline+1]
+		}
+	}
+
+	dialect := defaultDialect
+	delimiters := dialectDelimiters
+	if opts.Delimiter != 0 {
+		dialect.Delimiter = opts.Delimiter
+		delimiters = []rune{opts.Delimiter}
+	}
+	if opts.Quote != 0 {
+		dialect.Quote = opts.Quote
+	}
+
+	// The delimiter that splits the most rows into the same number of
+	// fields wins; the first quote character that parses is used with it
+	bestScore := 0.0
+	for _, delimiter := range delimiters {
+		for _, quote := range quoteCandidates(sample, delimiter, opts.Quote) {
+			candidate := CSVDialect{Delimiter: delimiter, Quote: quote}
+			rows, ok := sampleRows(sample, candidate, truncated, opts.Lenient != LenientOff)
+			if !ok {
+				continue
+			}
+			if score := dialectScore(rows); score > bestScore {
+				bestScore = score
+				dialect.Delimiter, dialect.Quote = delimiter, quote
+			}
+			break
+		}
+	}
+
+	if opts.HasHeader != nil {
+		dialect.HasHeader = *opts.HasHeader
+	} else {
+		rows, _ := sampleRows(sample, dialect, truncated, opts.Lenient != LenientOff)
+		dialect.HasHeader = detectHeader(rows)
+	}
+	return dialect
+}
+
+// quoteCandidates returns the quote characters to try with delimiter, the
+// one that more often starts a field first. A single quote is only tried
+// when some field starts with one, so a stray double quote is reported as
+// a malformed row rather than switching the file to single quotes.
+func quoteCandidates(sample []byte, delimiter rune, quote rune) []rune {
+	if quote != 0 {
+		return []rune{quote}
+	}
+	singles := quoteStarts(sample, delimiter, '\'')
+	switch {
+	case singles == 0:
+		return []rune{'"'}
+	case singles > quoteStarts(sample, delimiter, '"'):
+		return []rune{'\'', '"'}
+	default:
+		return []rune{'"', '\''}
+	}
+}
+
+// quoteStarts counts the fields in sample that start with quote
+func quoteStarts(sample []byte, delimiter rune, quote byte) int {
+	count := 0
+	for i := 0; i < len(sample); i++ {
+		if sample[i] != quote {
+			continue
+		}
+		if i == 0 || sample[i-1] == '\n' {
+			count++
+			continue
+		}
+		if previous, _ := utf8.DecodeLastRune(sample[:i]); previous == delimiter {
+			count++
+		}
+	}
+	return count
+}
+
+// sampleRows parses the non-empty rows of sample in dialect, reporting
+// whether it parsed. In a truncated sample a quoted field may continue
+// past the end, so an unterminated quote is accepted there. Stray quotes
+// are accepted when lazy is set, as lenient processing will.
+func sampleRows(sample []byte, dialect CSVDialect, truncated bool, lazy bool) ([][]string, bool) {
+	reader := newCSVReader(bytes.NewReader(sample), dialect)
+	reader.FieldsPerRecord = -1
+	reader.LazyQuotes = lazy
+
+	var rows [][]string
+	for {
+		record, err := reader.Read()
+		if err == io.EOF {
+			return rows, true
+		}
+		if err != nil {
+			return rows, truncated && len(rows) > 0 && errors.Is(err, csv.ErrQuote)
+		}
+		if !isEmptyRecord(record) {
+			rows = append(rows, record)
+		}
+	}
+}
+
+// dialectScore rates how well rows were split: the fraction of rows with
+// the most common number of fields, or 0 when that is a single field
+func dialectScore(rows [][]string) float64 {
+	counts := make(map[int]int)
+	width := 0
+	for _, row := range rows {
+		counts[len(row)]++
+		if counts[len(row)] > counts[width] || (counts[len(row)] == counts[width] && len(row) > width) {
+			width = len(row)
+		}
+	}
+	if width < 2 {
+		return 0
+	}
+	return float64(counts[width]) / float64(len(rows))
+}
+
+// detectHeader guesses whether the first of rows is a header. Headers
+// rarely contain addresses, and a header above a numeric column is
+// rarely numeric itself. Without evidence either way the first row is
+// taken to be a header.
+func detectHeader(rows [][]string) bool {
+	if len(rows) == 0 {
+		return true
+	}
+	for _, field := range rows[0] {
+		if strings.Contains(field, "@") {
+			return false
+		}
+	}
+
+	votes := 0
+	for column, field := range rows[0] {
+		numeric, seen := true, false
+		for _, row := range rows[1:] {
+			if column >= len(row) || strings.TrimSpace(row[column]) == "" {
+				continue
+			}
+			seen = true
+			if !isNumber(row[column]) {
+				numeric = false
+				break
+			}
+		}
+		if !seen || !numeric {
+			continue
+		}
+		if isNumber(field) {
+			votes--
+		} else {
+			votes++
+		}
+	}
+	return votes >= 0
+}
+
+// isNumber reports whether field holds a number
+func isNumber(field string) bool {
+	_, err := strconv.ParseFloat(strings.TrimSpace(field), 64)
+	return err == nil
+}
+
+// isEmptyRecord reports whether record is a blank line
+func isEmptyRecord(record []string) bool {
+	return len(record) == 0 || (len(record) == 1 && strings.TrimSpace(record[0]) == "")
+}
+
+// dialectReader reads the records of a CSV file in a dialect.
+// encoding/csv only supports double quotes, so files quoted with single
+// quotes are read with the two quote characters exchanged, and each field
+// is exchanged back.
+type dialectReader struct {
+	*csv.Reader
+	swapQuotes bool
+}
+
+// quoteSwapper exchanges single and double quotes
+var quoteSwapper = strings.NewReplacer(`'`, `"`, `"`, `'`)
+
+// newCSVReader creates a reader for r in dialect
+func newCSVReader(r io.Reader, dialect CSVDialect) *dialectReader {
+	swapQuotes := dialect.Quote == '\''
+	if swapQuotes {
+		r = &quoteSwapReader{r}
+	}
+
+	reader := csv.NewReader(r)
+	if dialect.Delimiter != 0 {
+		reader.Comma = dialect.Delimiter
+	}
+	return &dialectReader{Reader: reader, swapQuotes: swapQuotes}
+}
+
+// Read reads one record with its fields as written in the file
+func (dr *dialectReader) Read() ([]string, error) {
+	record, err := dr.Reader.Read()
+	if dr.swapQuotes {
+		for i := range record {
+			record[i] = quoteSwapper.Replace(record[i])
+		}
+	}
+	return record, err
+}
+
+// quoteSwapReader exchanges single and double quotes in the bytes it reads
+type quoteSwapReader struct {
+	r io.Reader
+}
+
+func (qr *quoteSwapReader) Read(p []byte) (int, error) {
+	n, err := qr.r.Read(p)
+	for i, b := range p[:n] {
+		switch b {
+		case '\'':
+			p[i] = '"'
+		case '"':
+			p[i] = '\''
+		}
+	}
+	return n, err
+}
@@ -0,0 +1,191 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestSniffDialect(t *testing.T) {
+	yes, no := true, false
+
+	tests := []struct {
+		name     string
+		sample   string
+		opts     ProcessOptions
+		expected CSVDialect
+	}{
+		{"Comma", "name,email\nJohn,john@example.com\n", ProcessOptions{}, CSVDialect{',', '"', true}},
+		{"Semicolon", "name;email;city\nJohn;john@example.com;Paris, France\nJane;jane@example.com;Lyon\n", ProcessOptions{}, CSVDialect{';', '"', true}},
+		{"Tab", "name\temail\nJohn\tjohn@example.com\n", ProcessOptions{}, CSVDialect{'\t', '"', true}},
+		{"Pipe", "name|email\nJohn|john@example.com\n", ProcessOptions{}, CSVDialect{'|', '"', true}},
+		{"Semicolons inside comma fields", "name,emails\nJohn,a@example.com;b@example.com\nJane,c@example.com\n", ProcessOptions{}, CSVDialect{',', '"', true}},
+		{"Single quotes", "'name','email'\n'Doe, John','john@example.com'\n", ProcessOptions{}, CSVDialect{',', '\'', true}},
+		{"Stray double quote", "name,email\na,a@example.com\nb,\"x\"y,q\nc,c,d\n", ProcessOptions{}, CSVDialect{',', '"', true}},
+		{"Apostrophes are not quotes", "name,email\nO'Brien,obrien@example.com\n'Tis,tis@example.com\n", ProcessOptions{}, CSVDialect{',', '"', true}},
+		{"Single column", "email\njohn@example.com\n", ProcessOptions{}, CSVDialect{',', '"', true}},
+		{"Headerless addresses", "John,john@example.com\nJane,jane@example.com\n", ProcessOptions{}, CSVDialect{',', '"', false}},
+		{"Headerless numbers", "1,John\n2,Jane\n", ProcessOptions{}, CSVDialect{',', '"', false}},
+		{"Header over numbers", "id,name\n1,John\n2,Jane\n", ProcessOptions{}, CSVDialect{',', '"', true}},
+		{"Empty", "", ProcessOptions{}, defaultDialect},
+		{"Delimiter override", "name,email;x\nJohn,john@example.com;y\n", ProcessOptions{Delimiter: ';'}, CSVDialect{';', '"', true}},
+		{"Header override", "John,john@example.com\n", ProcessOptions{HasHeader: &yes}, CSVDialect{',', '"', true}},
+		{"Headerless override", "name,email\n", ProcessOptions{HasHeader: &no}, CSVDialect{',', '"', false}},
+		{"Quote override", "name,email\n", ProcessOptions{Quote: '\''}, CSVDialect{',', '\'', true}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dialect := sniffDialect([]byte(tt.sample), false, tt.opts)
+			if dialect != tt.expected {
+				t.Errorf("Dialect mismatch. Expected: %+v, Got: %+v", tt.expected, dialect)
+			}
+		})
+	}
+}
+
+func TestSniffDialectTruncated(t *testing.T) {
+	// The sample ends inside a quoted field that spans lines
+	sample := "name;notes\nJohn;plain\nJane;plain\nAnn;\"first line\nsecond"
+
+	dialect := sniffDialect([]byte(sample), true, ProcessOptions{})
+	if dialect.Delimiter != ';' {
+		t.Errorf("Delimiter mismatch. Expected: ;, Got: %q", dialect.Delimiter)
+	}
+}
+
+func TestDetectDialect(t *testing.T) {
+	processor := NewCSVProcessor()
+	path := filepath.Join(t.TempDir(), "input.csv")
+
+	// The delimiter is only found in the sample, so a large file is
+	// detected from its first rows
+	content := "name;email\n" + strings.Repeat("John;john@example.com\n", dialectSampleBytes/10)
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatalf("Failed to write test CSV: %v", err)
+	}
+
+	dialect, err := processor.DetectDialect(path, ProcessOptions{})
+	if err != nil {
+		t.Fatalf("DetectDialect failed: %v", err)
+	}
+	if dialect != (CSVDialect{';', '"', true}) {
+		t.Errorf("Dialect mismatch. Expected: semicolon with header, Got: %+v", dialect)
+	}
+
+	if _, err := processor.DetectDialect(filepath.Join(t.TempDir(), "missing.csv"), ProcessOptions{}); err == nil {
+		t.Error("Expected error for missing file")
+	}
+}
+
+func TestParseDelimiter(t *testing.T) {
+	tests := []struct {
+		input       string
+		expected    rune
+		expectError bool
+	}{
+		{"", 0, false},
+		{",", ',', false},
+		{";", ';', false},
+		{"semicolon", ';', false},
+		{"TAB", '\t', false},
+		{`\t`, '\t', false},
+		{"\t", '\t', false},
+		{"pipe", '|', false},
+		{"§", '§', false},
+		{`"`, 0, true},
+		{"'", 0, true},
+		{"\n", 0, true},
+		{";;", 0, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(fmt.Sprintf("%q", tt.input), func(t *testing.T) {
+			delimiter, err := ParseDelimiter(tt.input)
+			if tt.expectError {
+				if err == nil {
+					t.Errorf("Expected error, got %q", delimiter)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("ParseDelimiter failed: %v", err)
+			}
+			if delimiter != tt.expected {
+				t.Errorf("Delimiter mismatch. Expected: %q, Got: %q", tt.expected, delimiter)
+			}
+		})
+	}
+}
+
+func TestParseQuote(t *testing.T) {
+	tests := []struct {
+		input       string
+		expected    rune
+		expectError bool
+	}{
+		{"", 0, false},
+		{`"`, '"', false},
+		{"double", '"', false},
+		{"'", '\'', false},
+		{"Single", '\'', false},
+		{"`", 0, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(fmt.Sprintf("%q", tt.input), func(t *testing.T) {
+			quote, err := ParseQuote(tt.input)
+			if tt.expectError {
+				if err == nil {
+					t.Errorf("Expected error, got %q", quote)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("ParseQuote failed: %v", err)
+			}
+			if quote != tt.expected {
+				t.Errorf("Quote mismatch. Expected: %q, Got: %q", tt.expected, quote)
+			}
+		})
+	}
+}
+
+func TestCSVDialectJSON(t *testing.T) {
+	dialect := CSVDialect{Delimiter: '\t', Quote: '\'', HasHeader: false}
+
+	data, err := json.Marshal(dialect)
+	if err != nil {
+		t.Fatalf("Marshal failed: %v", err)
+	}
+	expected := `{"delimiter":"\t","quote":"'","has_header":false}`
+	if string(data) != expected {
+		t.Errorf("JSON mismatch. Expected: %s, Got: %s", expected, data)
+	}
+
+	var decoded CSVDialect
+	if err := json.Unmarshal(data, &decoded); err != nil {
+		t.Fatalf("Unmarshal failed: %v", err)
+	}
+	if decoded != dialect {
+		t.Errorf("Round trip mismatch. Expected: %+v, Got: %+v", dialect, decoded)
+	}
+}
+
+func TestDialectReaderSingleQuotes(t *testing.T) {
+	input := `'Doe, John','say "hi"','it''s'` + "\n"
+
+	reader := newCSVReader(strings.NewReader(input), CSVDialect{Delimiter: ',', Quote: '\''})
+	record, err := reader.Read()
+	if err != nil {
+		t.Fatalf("Read failed: %v", err)
+	}
+
+	expected := []string{"Doe, John", `say "hi"`, "it's"}
+	if strings.Join(record, "|") != strings.Join(expected, "|") {
+		t.Errorf("Record mismatch. Expected: %q, Got: %q", expected, record)
+	}
+}
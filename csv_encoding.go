@@ -0,0 +1,173 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"unicode/utf8"
+
+	"golang.org/x/text/encoding"
+	"golang.org/x/text/encoding/htmlindex"
+	"golang.org/x/text/encoding/unicode"
+	"golang.org/x/text/transform"
+)
+
+// defaultLegacyEncoding is assumed for files that are not valid UTF-8 or UTF-16
+const defaultLegacyEncoding = "windows-1252"
+
+// byteOrderMarks are the BOMs recognized at the start of a file
+var byteOrderMarks = []struct {
+	bom      []byte
+	encoding string
+}{
+	{[]byte{0xEF, 0xBB, 0xBF}, "utf-8"},
+	{[]byte{0xFF, 0xFE}, "utf-16le"},
+	{[]byte{0xFE, 0xFF}, "utf-16be"},
+}
+
+// TextEncoding identifies the character encoding of a file
+type TextEncoding struct {
+	// Name is the WHATWG name of the encoding, such as "utf-8",
+	// "utf-16le" or "windows-1252"
+	Name string `json:"name"`
+
+	// BOM reports whether the file starts with a byte order mark
+	BOM bool `json:"bom,omitempty"`
+}
+
+// ParseEncoding returns the WHATWG name of an encoding label such as
+// "latin1" or "UTF-16LE"; empty means detect it
+func ParseEncoding(label string) (string, error) {
+	if label == "" {
+		return "", nil
+	}
+	enc, err := htmlindex.Get(label)
+	if err != nil {
+		return "", fmt.Errorf("unknown encoding %q", label)
+	}
+	name, err := htmlindex.Name(enc)
+	if err != nil || name == "replacement" {
+		return "", fmt.Errorf("unsupported encoding %q", label)
+	}
+	return name, nil
+}
+
+// DetectEncoding samples the start of the file at path to detect its
+// character encoding. A byte order mark always wins; otherwise the
+// encoding given in opts is used, or UTF-16 and UTF-8 are recognized and
+// anything else is taken to be Windows-1252.
+func (cp *CSVProcessor) DetectEncoding(path string, opts ProcessOptions) (TextEncoding, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return TextEncoding{}, fmt.Errorf("failed to open input file: %w", err)
+	}
+	defer file.Close()
+
+	sample := make([]byte, dialectSampleBytes)
+	n, err := io.ReadFull(file, sample)
+	truncated := err == nil
+	if err != nil && err != io.EOF && err != io.ErrUnexpectedEOF {
+		return TextEncoding{}, fmt.Errorf("failed to read input file: %w", err)
+	}
+
+	detected := detectEncoding(sample[:n], truncated)
+	if opts.Encoding != "" && !detected.BOM {
+		return TextEncoding{Name: opts.Encoding}, nil
+	}
+	return detected, nil
+}
+
+// detectEncoding detects the encoding of sample, the start of a file that
+// continues past it when truncated is set
+func detectEncoding(sample []byte, truncated bool) TextEncoding {
+	for _, mark := range byteOrderMarks {
+		if bytes.HasPrefix(sample, mark.bom) {
+			return TextEncoding{Name: mark.encoding, BOM: true}
+		}
+	}
+
+	// Mostly ASCII text in UTF-16 has a NUL in every other byte
+	var evenNULs, oddNULs int
+	for i, b := range sample {
+		if b != 0 {
+			continue
+		}
+		if i%2 == 0 {
+			evenNULs++
+		} else {
+			oddNULs++
+		}
+	}
+	switch half := len(sample) / 2; {
+	case oddNULs > half/2 && evenNULs <= oddNULs/8:
+		return TextEncoding{Name: "utf-16le"}
+	case evenNULs > half/2 && oddNULs <= evenNULs/8:
+		return TextEncoding{Name: "utf-16be"}
+	}
+
+	// A character cut off by the end of the sample is left out
+	if truncated {
+		if newline := bytes.LastIndexByte(sample, '\n'); newline >= 0 {
+			sample = sample[:newline+1]
+		}
+	}
+	if utf8.Valid(sample) {
+		return TextEncoding{Name: "utf-8"}
+	}
+	return TextEncoding{Name: defaultLegacyEncoding}
+}
+
+// transcodes reports whether files in the encoding must be converted to be
+// read as UTF-8
+func (te TextEncoding) transcodes() bool {
+	return te.BOM || (te.Name != "" && te.Name != "utf-8")
+}
+
+// encoding returns the x/text encoding named by te
+func (te TextEncoding) encoding() encoding.Encoding {
+	enc, err := htmlindex.Get(te.Name)
+	if err != nil {
+		return unicode.UTF8
+	}
+	return enc
+}
+
+// decode returns r converted to UTF-8, without a byte order mark
+func (te TextEncoding) decode(r io.Reader) io.Reader {
+	if !te.transcodes() {
+		return r
+	}
+	return transform.NewReader(r, unicode.BOMOverride(te.encoding().NewDecoder()))
+}
+
+// encode returns a writer that converts UTF-8 to the encoding before
+// writing to w, starting with a byte order mark when te has one.
+// Characters the encoding cannot represent are replaced. The writer must
+// be closed to flush it.
+func (te TextEncoding) encode(w io.Writer) (io.WriteCloser, error) {
+	encoder := transform.NewWriter(w, encoding.ReplaceUnsupported(te.encoding().NewEncoder()))
+	if te.BOM {
+		if _, err := io.WriteString(encoder, "\ufeff"); err != nil {
+			return nil, err
+		}
+	}
+	return encoder, nil
+}
+
+// countingReader counts the bytes read through it
+type countingReader struct {
+	r io.Reader
+	n int64
+}
+
+func (cr *countingReader) Read(p []byte) (int, error) {
+	n, err := cr.r.Read(p)
+	cr.n += int64(n)
+	return n, err
+}
+
+// Count returns the number of bytes read so far
+func (cr *countingReader) Count() int64 {
+	return cr.n
+}
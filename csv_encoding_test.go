@@ -0,0 +1,147 @@
+package main
+
+import (
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"golang.org/x/text/encoding/charmap"
+	"golang.org/x/text/encoding/unicode"
+)
+
+// mustEncode encodes s with a test encoder, failing the test on error
+func mustEncode(t *testing.T, encode func(string) (string, error), s string) string {
+	t.Helper()
+	encoded, err := encode(s)
+	if err != nil {
+		t.Fatalf("Failed to encode test data: %v", err)
+	}
+	return encoded
+}
+
+func TestDetectEncoding(t *testing.T) {
+	utf16LE := unicode.UTF16(unicode.LittleEndian, unicode.IgnoreBOM).NewEncoder()
+	utf16BE := unicode.UTF16(unicode.BigEndian, unicode.IgnoreBOM).NewEncoder()
+	windows1252 := charmap.Windows1252.NewEncoder()
+	content := "name,email\nJosé,jose@example.com\n"
+
+	tests := []struct {
+		name      string
+		sample    string
+		truncated bool
+		expected  TextEncoding
+	}{
+		{"ASCII", "name,email\n", false, TextEncoding{Name: "utf-8"}},
+		{"UTF-8", content, false, TextEncoding{Name: "utf-8"}},
+		{"UTF-8 BOM", "\ufeff" + content, false, TextEncoding{Name: "utf-8", BOM: true}},
+		{"UTF-16LE BOM", "\xff\xfe" + mustEncode(t, utf16LE.String, content), false, TextEncoding{Name: "utf-16le", BOM: true}},
+		{"UTF-16BE BOM", "\xfe\xff" + mustEncode(t, utf16BE.String, content), false, TextEncoding{Name: "utf-16be", BOM: true}},
+		{"UTF-16LE without BOM", mustEncode(t, utf16LE.String, content), false, TextEncoding{Name: "utf-16le"}},
+		{"UTF-16BE without BOM", mustEncode(t, utf16BE.String, content), false, TextEncoding{Name: "utf-16be"}},
+		{"Windows-1252", mustEncode(t, windows1252.String, content), false, TextEncoding{Name: "windows-1252"}},
+		{"UTF-8 cut off mid-character", content + "Zoë"[:3], true, TextEncoding{Name: "utf-8"}},
+		{"Empty", "", false, TextEncoding{Name: "utf-8"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			encoding := detectEncoding([]byte(tt.sample), tt.truncated)
+			if encoding != tt.expected {
+				t.Errorf("Encoding mismatch. Expected: %+v, Got: %+v", tt.expected, encoding)
+			}
+		})
+	}
+}
+
+func TestDetectEncodingOverride(t *testing.T) {
+	processor := NewCSVProcessor()
+	tempDir := t.TempDir()
+
+	plain := filepath.Join(tempDir, "plain.csv")
+	withBOM := filepath.Join(tempDir, "bom.csv")
+	os.WriteFile(plain, []byte("name,email\n"), 0644)
+	os.WriteFile(withBOM, []byte("\ufeffname,email\n"), 0644)
+
+	tests := []struct {
+		name     string
+		path     string
+		expected TextEncoding
+	}{
+		{"Override used", plain, TextEncoding{Name: "iso-8859-2"}},
+		{"BOM wins", withBOM, TextEncoding{Name: "utf-8", BOM: true}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			encoding, err := processor.DetectEncoding(tt.path, ProcessOptions{Encoding: "iso-8859-2"})
+			if err != nil {
+				t.Fatalf("DetectEncoding failed: %v", err)
+			}
+			if encoding != tt.expected {
+				t.Errorf("Encoding mismatch. Expected: %+v, Got: %+v", tt.expected, encoding)
+			}
+		})
+	}
+}
+
+func TestParseEncoding(t *testing.T) {
+	tests := []struct {
+		label       string
+		expected    string
+		expectError bool
+	}{
+		{"", "", false},
+		{"UTF-8", "utf-8", false},
+		{"utf-16le", "utf-16le", false},
+		{"latin1", "windows-1252", false},
+		{"cp1252", "windows-1252", false},
+		{"Shift_JIS", "shift_jis", false},
+		{"ebcdic", "", true},
+		{"replacement", "", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.label, func(t *testing.T) {
+			name, err := ParseEncoding(tt.label)
+			if tt.expectError {
+				if err == nil {
+					t.Errorf("Expected error, got %s", name)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("ParseEncoding failed: %v", err)
+			}
+			if name != tt.expected {
+				t.Errorf("Name mismatch. Expected: %s, Got: %s", tt.expected, name)
+			}
+		})
+	}
+}
+
+func TestTextEncodingRoundTrip(t *testing.T) {
+	encoding := TextEncoding{Name: "utf-16le", BOM: true}
+	text := "name,email\nJosé,jose@example.com\n"
+
+	var encoded strings.Builder
+	writer, err := encoding.encode(&encoded)
+	if err != nil {
+		t.Fatalf("encode failed: %v", err)
+	}
+	writer.Write([]byte(text))
+	writer.Close()
+
+	if !strings.HasPrefix(encoded.String(), "\xff\xfe") {
+		t.Errorf("Expected output to start with a UTF-16LE BOM, got %q", encoded.String()[:2])
+	}
+
+	decoded, err := io.ReadAll(encoding.decode(strings.NewReader(encoded.String())))
+	if err != nil {
+		t.Fatalf("decode failed: %v", err)
+	}
+	if string(decoded) != text {
+		t.Errorf("Round trip mismatch. Expected: %q, Got: %q", text, decoded)
+	}
+}
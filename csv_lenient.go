@@ -0,0 +1,259 @@
+package main
+
+import (
+	"bytes"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// LenientMode selects what happens to malformed rows
+type LenientMode string
+
+const (
+	// LenientOff fails processing at the first malformed row
+	LenientOff LenientMode = ""
+
+	// LenientSkip leaves malformed rows out of the processed file
+	LenientSkip LenientMode = "skip"
+
+	// LenientPass copies malformed rows to the processed file as they are,
+	// without validation results
+	LenientPass LenientMode = "pass"
+)
+
+// defaultMaxRowErrors is the most malformed rows a lenient job tolerates
+const defaultMaxRowErrors = 1000
+
+// errTooManyRowErrors is returned when a lenient job meets more malformed rows than allowed
+var errTooManyRowErrors = errors.New("too many malformed rows")
+
+// errMalformedFirstRow is returned when the first row of a lenient job is
+// malformed, leaving nothing to check the other rows against
+var errMalformedFirstRow = errors.New("malformed first row")
+
+// ParseLenientMode parses a lenient mode name; empty, "none" and "false" turn lenient parsing off
+func ParseLenientMode(name string) (LenientMode, error) {
+	switch mode := LenientMode(strings.ToLower(strings.TrimSpace(name))); mode {
+	case LenientOff, "none", "false":
+		return LenientOff, nil
+	case LenientSkip, LenientPass:
+		return mode, nil
+	default:
+		return "", fmt.Errorf("unknown lenient mode %q", name)
+	}
+}
+
+// checkRow reports why a row read with lazy quotes is malformed, or nil
+// when it is well formed: raw is its text and width the number of fields
+// in the first row
+func checkRow(raw string, record []string, width int, dialect CSVDialect) error {
+	// Lazy quotes accept stray quotes, so quoted rows are parsed again strictly
+	if strings.ContainsRune(raw, dialect.Quote) {
+		strict := newCSVReader(strings.NewReader(raw), dialect)
+		strict.FieldsPerRecord = -1
+		for {
+			_, err := strict.Read()
+			if err == io.EOF {
+				break
+			}
+			var parseErr *csv.ParseError
+			if errors.As(err, &parseErr) {
+				return parseErr.Err
+			}
+			if err != nil {
+				return err
+			}
+		}
+	}
+
+	if len(record) != width {
+		return fmt.Errorf("%w: expected %d, got %d", csv.ErrFieldCount, width, len(record))
+	}
+	return nil
+}
+
+// RowError describes a malformed row skipped or passed through in lenient mode
+type RowError struct {
+	// Line is the line the row starts on, counting from 1
+	Line int
+
+	// Offset is the byte offset of the row in the text decoded to UTF-8,
+	// which is its offset in the file unless the file was transcoded
+	Offset int64
+
+	// Raw is the text of the row, without its line ending
+	Raw string
+
+	Err error
+}
+
+func (e *RowError) Error() string {
+	return fmt.Sprintf("line %d: %v", e.Line, e.Err)
+}
+
+func (e *RowError) Unwrap() error {
+	return e.Err
+}
+
+// rowReader reads the records of a CSV file
+type rowReader interface {
+	Read() ([]string, error)
+	InputOffset() int64
+}
+
+// lenientReader reads records with lazy quotes and any number of fields.
+// Rows that a strict reader would reject, or whose number of fields differs
+// from the first row, are returned as a *RowError instead of a record.
+type lenientReader struct {
+	dialect  CSVDialect
+	recorder *recordingReader
+	reader   *dialectReader
+
+	// base and lineBase are the offset and number of lines in the text
+	// before the input of reader
+	base     int64
+	lineBase int
+
+	// width is the number of fields in the first row
+	width int
+
+	// crlf reports whether the first row ends with CRLF
+	crlf bool
+}
+
+// newLenientReader creates a lenient reader for r in dialect
+func newLenientReader(r io.Reader, dialect CSVDialect) *lenientReader {
+	lr := &lenientReader{dialect: dialect}
+	lr.open(&recordingReader{r: r}, 0, 0)
+	return lr
+}
+
+// open starts reading from recorder, which begins at offset base after lineBase lines
+func (lr *lenientReader) open(recorder *recordingReader, base int64, lineBase int) {
+	lr.recorder, lr.base, lr.lineBase = recorder, base, lineBase
+	lr.reader = newCSVReader(recorder, lr.dialect)
+	lr.reader.LazyQuotes = true
+	lr.reader.FieldsPerRecord = -1
+}
+
+// InputOffset returns the offset in the text of the end of the last row read
+func (lr *lenientReader) InputOffset() int64 {
+	return lr.base + lr.reader.InputOffset()
+}
+
+// Read reads the next record, returning a *RowError if it is malformed
+func (lr *lenientReader) Read() ([]string, error) {
+	start := lr.reader.InputOffset()
+	record, err := lr.reader.Read()
+	if err != nil {
+		return record, err
+	}
+	raw := lr.recorder.take(start, lr.reader.InputOffset())
+
+	if isEmptyRecord(record) {
+		return record, nil
+	}
+
+	// The first row sets the number of fields the others must have
+	first := lr.width == 0
+	if first {
+		lr.width = len(record)
+		lr.crlf = strings.HasSuffix(raw, "\r\n")
+	}
+
+	rowErr := checkRow(raw, record, lr.width, lr.dialect)
+	if rowErr == nil {
+		return record, nil
+	}
+
+	// Blank lines before the row are skipped, like the reader does
+	line, _ := lr.reader.FieldPos(0)
+	text := strings.TrimLeft(raw, "\r\n")
+	skipped := len(raw) - len(text)
+	text = strings.TrimRight(text, "\r\n")
+	malformed := &RowError{Line: lr.lineBase + line, Offset: lr.base + start + int64(skipped), Raw: text, Err: rowErr}
+	if first {
+		return nil, fmt.Errorf("%w: %v", errMalformedFirstRow, malformed)
+	}
+
+	// A stray quote runs on over the following lines, so only its own line
+	// is malformed and reading resumes on the next one
+	if newline := strings.IndexByte(text, '\n'); newline >= 0 && !errors.Is(rowErr, csv.ErrFieldCount) {
+		malformed.Raw = strings.TrimRight(text[:newline], "\r")
+		rest := skipped + newline + 1
+		lr.open(lr.recorder.rewind(raw[rest:]), lr.base+start+int64(rest), malformed.Line)
+	}
+	return nil, malformed
+}
+
+// rowErrorReport is a CSV file listing the malformed rows of a lenient job
+type rowErrorReport struct {
+	file   *os.File
+	writer *csv.Writer
+}
+
+// createRowErrorReport creates the report at path, or a report that is
+// discarded when path is empty
+func createRowErrorReport(path string) (*rowErrorReport, error) {
+	report := &rowErrorReport{writer: csv.NewWriter(io.Discard)}
+	if path != "" {
+		file, err := os.Create(path)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create error report: %w", err)
+		}
+		report.file = file
+		report.writer = csv.NewWriter(file)
+	}
+	return report, report.writer.Write([]string{"line", "byte_offset", "raw", "error"})
+}
+
+// Write records a malformed row
+func (rr *rowErrorReport) Write(rowErr *RowError) error {
+	return rr.writer.Write([]string{strconv.Itoa(rowErr.Line), strconv.FormatInt(rowErr.Offset, 10), rowErr.Raw, rowErr.Err.Error()})
+}
+
+// Close flushes and closes the report
+func (rr *rowErrorReport) Close() error {
+	rr.writer.Flush()
+	err := rr.writer.Error()
+	if rr.file != nil {
+		if closeErr := rr.file.Close(); err == nil {
+			err = closeErr
+		}
+	}
+	return err
+}
+
+// recordingReader keeps the text read through it so that the raw text of
+// each row can be recovered from the reader's offsets
+type recordingReader struct {
+	r    io.Reader
+	buf  []byte
+	base int64
+}
+
+func (rr *recordingReader) Read(p []byte) (int, error) {
+	n, err := rr.r.Read(p)
+	rr.buf = append(rr.buf, p[:n]...)
+	return n, err
+}
+
+// take returns the text between offsets start and end and forgets
+// everything before end. Rows must be taken in order.
+func (rr *recordingReader) take(start, end int64) string {
+	text := string(rr.buf[start-rr.base : end-rr.base])
+	rr.buf = rr.buf[end-rr.base:]
+	rr.base = end
+	return text
+}
+
+// rewind returns a reader that reads text again, followed by whatever
+// rr has not yet returned
+func (rr *recordingReader) rewind(text string) *recordingReader {
+	return &recordingReader{r: io.MultiReader(strings.NewReader(text), bytes.NewReader(rr.buf), rr.r)}
+}
@@ -0,0 +1,142 @@
+package main
+
+import (
+	"encoding/csv"
+	"errors"
+	"io"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestParseLenientMode(t *testing.T) {
+	tests := []struct {
+		input       string
+		expected    LenientMode
+		expectError bool
+	}{
+		{"", LenientOff, false},
+		{"false", LenientOff, false},
+		{"skip", LenientSkip, false},
+		{" Pass ", LenientPass, false},
+		{"ignore", "", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.input, func(t *testing.T) {
+			mode, err := ParseLenientMode(tt.input)
+			if tt.expectError {
+				if err == nil {
+					t.Error("Expected error, got nil")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("ParseLenientMode failed: %v", err)
+			}
+			if mode != tt.expected {
+				t.Errorf("Mode mismatch. Expected: %s, Got: %s", tt.expected, mode)
+			}
+		})
+	}
+}
+
+func TestCheckRow(t *testing.T) {
+	singleQuotes := CSVDialect{Delimiter: ',', Quote: '\''}
+
+	tests := []struct {
+		name     string
+		raw      string
+		record   []string
+		dialect  CSVDialect
+		expected error
+	}{
+		{"Well formed", "a,b\n", []string{"a", "b"}, defaultDialect, nil},
+		{"Quoted", "\"a,1\",b\n", []string{"a,1", "b"}, defaultDialect, nil},
+		{"Too few fields", "a\n", []string{"a"}, defaultDialect, csv.ErrFieldCount},
+		{"Too many fields", "a,b,c\n", []string{"a", "b", "c"}, defaultDialect, csv.ErrFieldCount},
+		{"Bare quote", "a \"b\",c\n", []string{"a \"b\"", "c"}, defaultDialect, csv.ErrBareQuote},
+		{"Unterminated quote", "\"a,b\n", []string{"a,b\n"}, defaultDialect, csv.ErrQuote},
+		{"Single quotes", "'a,1',b\n", []string{"a,1", "b"}, singleQuotes, nil},
+		{"Bare single quote", "O'Brien,b\n", []string{"O'Brien", "b"}, singleQuotes, csv.ErrBareQuote},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := checkRow(tt.raw, tt.record, 2, tt.dialect)
+			if !errors.Is(err, tt.expected) {
+				t.Errorf("Error mismatch. Expected: %v, Got: %v", tt.expected, err)
+			}
+		})
+	}
+}
+
+func TestLenientReader(t *testing.T) {
+	input := "name,email\n" +
+		"\"John,john@example.com\n" +
+		"Jane,jane@example.com\n" +
+		"\"Doe, Ann\",\"ann\n@example.com\"\n" +
+		"Bob\n" +
+		"Eve,eve@example.com\n"
+
+	reader := newLenientReader(strings.NewReader(input), defaultDialect)
+	var records [][]string
+	var malformed []RowError
+	for {
+		record, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		var rowErr *RowError
+		if errors.As(err, &rowErr) {
+			malformed = append(malformed, *rowErr)
+			continue
+		}
+		if err != nil {
+			t.Fatalf("Read failed: %v", err)
+		}
+		records = append(records, record)
+	}
+
+	expectedRecords := [][]string{
+		{"name", "email"},
+		{"Jane", "jane@example.com"},
+		{"Doe, Ann", "ann\n@example.com"},
+		{"Eve", "eve@example.com"},
+	}
+	if !reflect.DeepEqual(records, expectedRecords) {
+		t.Errorf("Records mismatch. Expected: %q, Got: %q", expectedRecords, records)
+	}
+
+	expectedMalformed := []RowError{
+		{Line: 2, Offset: 11, Raw: "\"John,john@example.com", Err: csv.ErrQuote},
+		{Line: 6, Offset: 86, Raw: "Bob", Err: csv.ErrFieldCount},
+	}
+	if len(malformed) != len(expectedMalformed) {
+		t.Fatalf("Expected %d malformed rows, got %d: %+v", len(expectedMalformed), len(malformed), malformed)
+	}
+	for i, expected := range expectedMalformed {
+		got := malformed[i]
+		if got.Line != expected.Line || got.Offset != expected.Offset || got.Raw != expected.Raw || !errors.Is(got.Err, expected.Err) {
+			t.Errorf("Malformed row %d mismatch. Expected: %+v, Got: %+v", i, expected, got)
+		}
+	}
+
+	if reader.InputOffset() != int64(len(input)) {
+		t.Errorf("Offset mismatch. Expected: %d, Got: %d", len(input), reader.InputOffset())
+	}
+}
+
+func TestLenientReaderMalformedFirstRow(t *testing.T) {
+	// A stray quote in the header would swallow the rows after it
+	input := "name,\"email\nJohn,john@example.com\n"
+
+	reader := newLenientReader(strings.NewReader(input), defaultDialect)
+	record, err := reader.Read()
+	if !errors.Is(err, errMalformedFirstRow) {
+		t.Fatalf("Expected %v, got %q and %v", errMalformedFirstRow, record, err)
+	}
+	if !strings.Contains(err.Error(), "line 1") {
+		t.Errorf("Error should name the line, got %q", err.Error())
+	}
+}
@@ -1,7 +1,12 @@
 package main
 
 import (
+	"bufio"
+	"context"
+	"crypto/sha256"
 	"encoding/csv"
+	"encoding/hex"
+	"errors"
 	"fmt"
 	"io"
 	"os"
@@ -9,20 +14,266 @@ import (
 	"strings"
 )
 
+// defaultStorageDir is the directory holding uploaded and processed files
+const defaultStorageDir = "uploads"
+
 // CSVProcessor handles CSV file processing
 type CSVProcessor struct {
-	validator *EmailValidator
+	validator  *EmailValidator
+	storageDir string
+
+	// prober, if set, allows uploads to request mailbox verification
+	prober *SMTPProber
+
+	// policies are the named domain policies uploads may select
+	policies map[string]*DomainPolicy
 }
 
-// NewCSVProcessor creates a new CSV processor
+// NewCSVProcessor creates a new CSV processor with the default settings
 func NewCSVProcessor() *CSVProcessor {
 	return &CSVProcessor{
-		validator: NewEmailValidator(),
+		validator:  NewEmailValidator(),
+		storageDir: defaultStorageDir,
+	}
+}
+
+// NewCSVProcessorWithConfig creates a new CSV processor using the storage
+// directory and validation settings from cfg
+func NewCSVProcessorWithConfig(cfg *Config) (*CSVProcessor, error) {
+	opts := EmailValidatorOptions{
+		Profile:  ValidationProfile(cfg.Validation.Profile),
+		Pattern:  cfg.Validation.EmailPattern,
+		SMTPUTF8: cfg.Validation.SMTPUTF8,
+	}
+	if cfg.Validation.DNS.Enabled {
+		opts.DomainChecker = NewDomainChecker(cfg.Validation.DNS.CheckerOptions())
+	}
+
+	// Domain list files extend the embedded defaults
+	lists := cfg.Validation.Lists
+	var err error
+	if opts.Disposable, err = NewDomainList(defaultDisposableDomains, lists.DisposableFile, lists.ReloadInterval.Duration); err != nil {
+		return nil, err
+	}
+	if opts.FreeProviders, err = NewDomainList(defaultFreeProviders, lists.FreeProvidersFile, lists.ReloadInterval.Duration); err != nil {
+		return nil, err
+	}
+	if opts.RoleAccounts, err = NewRoleAccountList(defaultRoleAccounts, lists.RoleAccountsFile, lists.ReloadInterval.Duration); err != nil {
+		return nil, err
+	}
+
+	validator, err := NewEmailValidatorWithOptions(opts)
+	if err != nil {
+		return nil, err
+	}
+
+	processor := &CSVProcessor{
+		validator:  validator,
+		storageDir: cfg.StorageDir,
+		policies:   make(map[string]*DomainPolicy),
+	}
+	for name, policyCfg := range cfg.Validation.Policies {
+		if processor.policies[name], err = NewDomainPolicy(policyCfg.Allow, policyCfg.Deny); err != nil {
+			return nil, fmt.Errorf("policy %q: %w", name, err)
+		}
+	}
+	if cfg.Validation.SMTP.Enabled {
+		processor.prober = NewSMTPProber(cfg.Validation.SMTP.ProberOptions(cfg.Validation.DNS.Resolver))
+	}
+	return processor, nil
+}
+
+// errMailboxVerificationDisabled is returned when mailbox verification is
+// requested but no SMTP prober is configured
+var errMailboxVerificationDisabled = errors.New("mailbox verification is not enabled")
+
+// ResolvePolicy returns the domain policy selected by opts: the named
+// policy narrowed by any inline patterns, or nil when there is none
+func (cp *CSVProcessor) ResolvePolicy(opts ProcessOptions) (*DomainPolicy, error) {
+	var named *DomainPolicy
+	if opts.Policy != "" {
+		var exists bool
+		if named, exists = cp.policies[opts.Policy]; !exists {
+			return nil, fmt.Errorf("unknown domain policy %q", opts.Policy)
+		}
+	}
+	if len(opts.AllowDomains) == 0 && len(opts.DenyDomains) == 0 {
+		return named, nil
+	}
+
+	inline, err := NewDomainPolicy(opts.AllowDomains, opts.DenyDomains)
+	if err != nil {
+		return nil, err
+	}
+	return named.Merge(inline), nil
+}
+
+// CanVerifyMailbox reports whether uploads may request mailbox verification
+func (cp *CSVProcessor) CanVerifyMailbox() bool {
+	return cp.prober != nil
+}
+
+// progressInterval is the number of rows between progress reports
+const progressInterval = 500
+
+// ProgressFunc receives progress reports while a file is being processed
+type ProgressFunc func(progress ProcessingProgress)
+
+// OutputMode selects which validation columns are appended to each row
+type OutputMode string
+
+const (
+	// OutputHasEmail appends a single has_email column for the whole row
+	OutputHasEmail OutputMode = "has_email"
+
+	// OutputColumns appends <col>_email_valid, and optionally
+	// <col>_email_reason, for each chosen column
+	OutputColumns OutputMode = "columns"
+)
+
+// ParseOutputMode parses an output mode name, defaulting to OutputHasEmail when empty
+func ParseOutputMode(name string) (OutputMode, error) {
+	switch mode := OutputMode(strings.ToLower(strings.TrimSpace(name))); mode {
+	case "":
+		return OutputHasEmail, nil
+	case OutputHasEmail, OutputColumns:
+		return mode, nil
+	default:
+		return "", fmt.Errorf("unknown output mode %q", name)
 	}
 }
 
+// ProcessOptions controls how a CSV file is processed
+type ProcessOptions struct {
+	// Progress, if set, is called periodically and once more when processing ends
+	Progress ProgressFunc
+
+	// Output selects the validation columns appended to each row
+	Output OutputMode
+
+	// Columns and ColumnIndexes choose the columns that are validated, by
+	// header name and by zero-based index; every column is validated when
+	// both are empty
+	Columns       []string
+	ColumnIndexes []int
+
+	// Reasons adds a <col>_email_reason column after each <col>_email_valid column
+	Reasons bool
+
+	// ASCII adds the valid address with its domain in punycode, as
+	// <col>_email_ascii in OutputColumns mode or email_ascii otherwise
+	ASCII bool
+
+	// Providers adds whether each valid address is at a disposable or
+	// free-mail domain, as <col>_is_disposable and <col>_is_free_provider
+	// or is_disposable and is_free_provider
+	Providers bool
+
+	// RoleAccounts adds whether each valid address is a role account such
+	// as info@ or noreply@, as <col>_is_role_account or is_role_account
+	RoleAccounts bool
+
+	// ExcludeRoleAccounts stops role accounts from counting as a valid
+	// email for has_email and the rows-with-email count
+	ExcludeRoleAccounts bool
+
+	// Suggestions adds a corrected address when a domain looks like a typo,
+	// such as gmial.com, as <col>_email_suggestion or email_suggestion
+	Suggestions bool
+
+	// Normalize adds or writes the canonical form of each valid address;
+	// see NormalizeMode
+	Normalize NormalizeMode
+
+	// StripTags removes +tag subaddresses from every normalized address
+	StripTags bool
+
+	// Extract also finds addresses embedded in free text, such as display
+	// names, mailto: links and lists, and outputs them as
+	// <col>_extracted_emails or extracted_emails
+	Extract ExtractMode
+
+	// Policy names a domain policy from the configuration, and AllowDomains
+	// and DenyDomains add inline patterns. Addresses rejected by the policy
+	// are invalid and explained in <col>_policy_violation or policy_violation.
+	Policy       string
+	AllowDomains []string
+	DenyDomains  []string
+
+	// VerifyMailbox probes the mail server of each valid address and adds
+	// its classification as <col>_mailbox_status or mailbox_status
+	VerifyMailbox bool
+
+	// Delimiter and Quote, when not zero, and HasHeader, when not nil,
+	// override the detected dialect; see DetectDialect
+	Delimiter rune
+	Quote     rune
+	HasHeader *bool
+
+	// Encoding, when not empty, is the WHATWG name of the input's character
+	// encoding, used instead of detecting it; see DetectEncoding
+	Encoding string
+
+	// KeepEncoding writes the processed file in the input's encoding
+	// rather than UTF-8
+	KeepEncoding bool
+
+	// Lenient tolerates stray quotes and rows with the wrong number of
+	// fields, which are recorded in the error report and skipped or passed
+	// through; see LenientMode
+	Lenient LenientMode
+
+	// MaxErrors is the most malformed rows tolerated in lenient mode before
+	// processing fails; 0 means no limit
+	MaxErrors int
+
+	// ErrorReportPath, if set, is where lenient mode writes the malformed rows
+	ErrorReportPath string
+
+	// OutputColumn names the column appended in OutputHasEmail mode,
+	// has_email when empty
+	OutputColumn string
+
+	// OnCollision selects what happens when an appended column has the
+	// name of an existing column; see CollisionMode
+	OnCollision CollisionMode
+}
+
 // ProcessCSV processes a CSV file and adds email validation column
 func (cp *CSVProcessor) ProcessCSV(inputPath, outputPath string) error {
+	return cp.ProcessCSVWithOptions(context.Background(), inputPath, outputPath, ProcessOptions{})
+}
+
+// ProcessCSVWithOptions processes a CSV file and adds email validation column,
+// reporting progress as it streams through the input. Processing stops with
+// ctx.Err() once ctx is cancelled.
+func (cp *CSVProcessor) ProcessCSVWithOptions(ctx context.Context, inputPath, outputPath string, opts ProcessOptions) error {
+	if opts.VerifyMailbox && !cp.CanVerifyMailbox() {
+		return errMailboxVerificationDisabled
+	}
+
+	// Apply the upload's domain policy to this run only
+	policy, err := cp.ResolvePolicy(opts)
+	if err != nil {
+		return err
+	}
+	if policy != nil {
+		scoped := *cp
+		scoped.validator = cp.validator.WithPolicy(policy)
+		cp = &scoped
+	}
+	normalizer := NewEmailNormalizer(cp.validator, NormalizeOptions{StripTags: opts.StripTags})
+	extras := cp.extraColumns(opts, normalizer)
+
+	encoding, err := cp.DetectEncoding(inputPath, opts)
+	if err != nil {
+		return err
+	}
+	dialect, err := cp.DetectDialect(inputPath, opts)
+	if err != nil {
+		return err
+	}
+
 	// Open input file
 	inputFile, err := os.Open(inputPath)
 	if err != nil {
@@ -30,6 +281,17 @@ func (cp *CSVProcessor) ProcessCSV(inputPath, outputPath string) error {
 	}
 	defer inputFile.Close()
 
+	// Total size is used to compute percent complete
+	progress := ProcessingProgress{}
+	if info, err := inputFile.Stat(); err == nil {
+		progress.TotalBytes = info.Size()
+	}
+	reportProgress := func() {
+		if opts.Progress != nil {
+			opts.Progress(progress)
+		}
+	}
+
 	// Create output file
 	outputFile, err := os.Create(outputPath)
 	if err != nil {
@@ -37,34 +299,134 @@ func (cp *CSVProcessor) ProcessCSV(inputPath, outputPath string) error {
 	}
 	defer outputFile.Close()
 
-	// Create CSV reader and writer
-	reader := csv.NewReader(inputFile)
-	writer := csv.NewWriter(outputFile)
-	defer writer.Flush()
+	var output io.Writer = outputFile
+	var encoder io.WriteCloser
+	if opts.KeepEncoding && encoding.transcodes() {
+		if encoder, err = encoding.encode(outputFile); err != nil {
+			return fmt.Errorf("failed to write output file: %w", err)
+		}
+		output = encoder
+	}
+
+	// Create CSV reader and writer; the output keeps the input's delimiter.
+	// The writer uses buffered as its buffer, so passed through rows written
+	// to it directly stay in order.
+	counter := &countingReader{r: inputFile}
+	var reader rowReader = newCSVReader(encoding.decode(counter), dialect)
+	buffered := bufio.NewWriter(output)
+	writer := csv.NewWriter(buffered)
+	writer.Comma = dialect.Delimiter
+
+	// Lenient parsing reports malformed rows instead of failing
+	var lenient *lenientReader
+	var report *rowErrorReport
+	if opts.Lenient != LenientOff {
+		lenient = newLenientReader(encoding.decode(counter), dialect)
+		reader = lenient
+		if report, err = createRowErrorReport(opts.ErrorReportPath); err != nil {
+			return err
+		}
+		defer report.Close()
+	}
+
+	// Offsets in transcoded text do not match the file, so progress counts
+	// the bytes decoded instead
+	bytesRead := reader.InputOffset
+	if encoding.transcodes() {
+		bytesRead = counter.Count
+	}
 
 	// Process each row
 	rowNum := 0
+	var targets, positions []int
 	for {
+		// Stop promptly when the job is cancelled
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+
 		record, err := reader.Read()
 		if err == io.EOF {
 			break
 		}
+		var rowErr *RowError
+		if errors.As(err, &rowErr) {
+			progress.RowErrors++
+			if err := report.Write(rowErr); err != nil {
+				return fmt.Errorf("failed to write error report: %w", err)
+			}
+			if opts.MaxErrors > 0 && progress.RowErrors > int64(opts.MaxErrors) {
+				reportProgress()
+				return fmt.Errorf("%w: more than %d", errTooManyRowErrors, opts.MaxErrors)
+			}
+
+			// Passed through rows are copied exactly as they were read
+			if opts.Lenient == LenientPass {
+				lineEnding := "\n"
+				if writer.UseCRLF {
+					lineEnding = "\r\n"
+				}
+				if _, err := buffered.WriteString(rowErr.Raw + lineEnding); err != nil {
+					return fmt.Errorf("failed to write CSV row %d: %w", rowNum, err)
+				}
+				rowNum++
+			}
+			progress.BytesRead = bytesRead()
+			continue
+		}
 		if err != nil {
 			return fmt.Errorf("failed to read CSV row %d: %w", rowNum, err)
 		}
 
 		// Skip empty rows
-		if len(record) == 0 || (len(record) == 1 && strings.TrimSpace(record[0]) == "") {
+		if isEmptyRecord(record) {
 			continue
 		}
 
-		// For header row (first row), add "has_email" column
+		var header []string
 		if rowNum == 0 {
-			record = append(record, "has_email")
+			// Plan the output columns against the first row
+			if targets, header, positions, err = planColumns(record, dialect.HasHeader, opts, extras); err != nil {
+				return err
+			}
+
+			// Rows passed through keep their line endings, so the rest of the file does too
+			if opts.Lenient == LenientPass {
+				writer.UseCRLF = lenient.crlf
+			}
+		}
+
+		if header != nil {
+			record = header
 		} else {
-			// For data rows, check if any field contains a valid email
-			hasEmail := cp.validator.HasValidEmail(record)
-			record = append(record, fmt.Sprintf("%t", hasEmail))
+			var values []string
+			var hasEmail bool
+			if opts.Output == OutputColumns {
+				// Validate each chosen column separately
+				values, hasEmail = cp.columnResults(ctx, record, targets, opts, extras)
+			} else {
+				// For data rows, check if any target field contains a valid email
+				field, result, found := cp.firstValidEmail(ctx, selectFields(record, targets), opts)
+				hasEmail = countsAsEmail(result, opts.ExcludeRoleAccounts)
+				values = append(values, fmt.Sprintf("%t", hasEmail))
+				if opts.Extract != ExtractOff {
+					values = append(values, formatExtracted(found, opts.Extract))
+				}
+				for _, extra := range extras {
+					values = append(values, extra.value(ctx, field, result))
+				}
+			}
+
+			// Rewrite addresses only after they have been reported on
+			if opts.Normalize == NormalizeInPlace {
+				cp.normalizeFields(ctx, record, targets, normalizer)
+			}
+			record = placeValues(record, values, positions)
+
+			progress.RowsProcessed++
+			if hasEmail {
+				progress.RowsWithEmail++
+			}
 		}
 
 		// Write the modified record
@@ -73,31 +435,386 @@ func (cp *CSVProcessor) ProcessCSV(inputPath, outputPath string) error {
 		}
 
 		rowNum++
+
+		progress.BytesRead = bytesRead()
+		if rowNum%progressInterval == 0 {
+			reportProgress()
+		}
 	}
 
+	// Buffered rows are written out last, so a full disk may only show up here
+	writer.Flush()
+	if err := writer.Error(); err != nil {
+		return fmt.Errorf("failed to write output file: %w", err)
+	}
+	if encoder != nil {
+		if err := encoder.Close(); err != nil {
+			return fmt.Errorf("failed to write output file: %w", err)
+		}
+	}
+	if err := outputFile.Close(); err != nil {
+		return fmt.Errorf("failed to close output file: %w", err)
+	}
+
+	progress.BytesRead = bytesRead()
+	reportProgress()
+
 	return nil
 }
 
-// SaveUploadedFile saves the uploaded file to the filesystem
-func (cp *CSVProcessor) SaveUploadedFile(fileData []byte, filename string) (string, error) {
+// ColumnNotFoundError is returned when a requested column is not in the header row
+type ColumnNotFoundError struct {
+	// Name is the requested header name, or empty when Index was requested
+	Name    string
+	Index   int
+	Headers []string
+}
+
+func (e *ColumnNotFoundError) Error() string {
+	available := strings.Join(e.Headers, ", ")
+	if e.Name != "" {
+		return fmt.Sprintf("column %q not found; available columns: %s", e.Name, available)
+	}
+	return fmt.Sprintf("column index %d out of range; available columns: %s", e.Index, available)
+}
+
+// errColumnsNeedHeader is returned when columns are chosen by name in a file without a header row
+var errColumnsNeedHeader = errors.New("columns can only be chosen by name in files with a header row; use column_index")
+
+// resolveTargets resolves the columns requested in opts against the first
+// row of a file, which is only a header when hasHeader is set
+func resolveTargets(first []string, hasHeader bool, opts ProcessOptions) ([]int, error) {
+	if !hasHeader && len(opts.Columns) > 0 {
+		return nil, errColumnsNeedHeader
+	}
+	return resolveColumns(first, opts.Columns, opts.ColumnIndexes)
+}
+
+// resolveColumns maps column names and zero-based indexes to indexes in
+// header. It returns nil, meaning every column, when none are requested.
+func resolveColumns(header []string, names []string, indexes []int) ([]int, error) {
+	if len(names) == 0 && len(indexes) == 0 {
+		return nil, nil
+	}
+
+	resolved := make([]int, 0, len(names)+len(indexes))
+	seen := make(map[int]bool)
+	add := func(index int) {
+		if !seen[index] {
+			seen[index] = true
+			resolved = append(resolved, index)
+		}
+	}
+
+	for _, name := range names {
+		index := -1
+		for i, column := range header {
+			if strings.TrimSpace(column) == strings.TrimSpace(name) {
+				index = i
+				break
+			}
+		}
+		if index < 0 {
+			return nil, &ColumnNotFoundError{Name: name, Index: -1, Headers: header}
+		}
+		add(index)
+	}
+
+	for _, index := range indexes {
+		if index < 0 || index >= len(header) {
+			return nil, &ColumnNotFoundError{Index: index, Headers: header}
+		}
+		add(index)
+	}
+
+	return resolved, nil
+}
+
+// allColumns returns the index of every column in header
+func allColumns(header []string) []int {
+	indexes := make([]int, len(header))
+	for i := range header {
+		indexes[i] = i
+	}
+	return indexes
+}
+
+// selectFields returns the fields of record at targets, or the whole record when targets is nil
+func selectFields(record []string, targets []int) []string {
+	if targets == nil {
+		return record
+	}
+
+	fields := make([]string, 0, len(targets))
+	for _, i := range targets {
+		if i < len(record) {
+			fields = append(fields, record[i])
+		}
+	}
+	return fields
+}
+
+// ReadHeader returns the first non-empty row of a CSV file in encoding and dialect, or nil if the file has none
+func (cp *CSVProcessor) ReadHeader(path string, encoding TextEncoding, dialect CSVDialect) ([]string, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open input file: %w", err)
+	}
+	defer file.Close()
+
+	reader := newCSVReader(encoding.decode(file), dialect)
+	for {
+		record, err := reader.Read()
+		if err == io.EOF {
+			return nil, nil
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to read CSV header: %w", err)
+		}
+		if isEmptyRecord(record) {
+			continue
+		}
+		return record, nil
+	}
+}
+
+// extraColumn is an optional output column describing a validated address.
+// Its header is name, prefixed with "<col>_" in OutputColumns mode.
+type extraColumn struct {
+	name  string
+	value func(ctx context.Context, field string, result ValidationResult) string
+}
+
+// extraColumns returns the optional columns selected by opts, in output order
+func (cp *CSVProcessor) extraColumns(opts ProcessOptions, normalizer *EmailNormalizer) []extraColumn {
+	var extras []extraColumn
+	if opts.ASCII {
+		extras = append(extras, extraColumn{"email_ascii", func(_ context.Context, _ string, result ValidationResult) string {
+			return result.ASCII
+		}})
+	}
+	if opts.Normalize == NormalizeColumn {
+		extras = append(extras, extraColumn{"normalized_email", func(_ context.Context, field string, result ValidationResult) string {
+			if !result.Valid {
+				return ""
+			}
+			return normalizer.Normalize(field)
+		}})
+	}
+	if opts.Providers {
+		extras = append(extras,
+			extraColumn{"is_disposable", func(_ context.Context, _ string, result ValidationResult) string {
+				return formatFlag(result, result.Disposable)
+			}},
+			extraColumn{"is_free_provider", func(_ context.Context, _ string, result ValidationResult) string {
+				return formatFlag(result, result.FreeProvider)
+			}},
+		)
+	}
+	if opts.RoleAccounts {
+		extras = append(extras, extraColumn{"is_role_account", func(_ context.Context, _ string, result ValidationResult) string {
+			return formatFlag(result, result.RoleAccount)
+		}})
+	}
+	if opts.Suggestions {
+		extras = append(extras, extraColumn{"email_suggestion", func(_ context.Context, field string, _ ValidationResult) string {
+			return cp.validator.Suggest(field)
+		}})
+	}
+	if opts.Policy != "" || len(opts.AllowDomains) > 0 || len(opts.DenyDomains) > 0 {
+		extras = append(extras, extraColumn{"policy_violation", func(_ context.Context, _ string, result ValidationResult) string {
+			return result.PolicyViolation
+		}})
+	}
+	if opts.VerifyMailbox {
+		extras = append(extras, extraColumn{"mailbox_status", func(ctx context.Context, _ string, result ValidationResult) string {
+			if !result.Valid {
+				return ""
+			}
+			return string(cp.prober.Probe(ctx, result.ASCII))
+		}})
+	}
+	return extras
+}
+
+// formatFlag formats a property of a valid address, leaving it empty for invalid ones
+func formatFlag(result ValidationResult, flag bool) string {
+	if !result.Valid {
+		return ""
+	}
+	return fmt.Sprintf("%t", flag)
+}
+
+// columnHeaders returns the per-column result headers for the target columns
+func columnHeaders(header []string, targets []int, opts ProcessOptions, extras []extraColumn) []string {
+	var names []string
+	for _, i := range targets {
+		column := strings.TrimSpace(header[i])
+		names = append(names, column+"_email_valid")
+		if opts.Reasons {
+			names = append(names, column+"_email_reason")
+		}
+		if opts.Extract != ExtractOff {
+			names = append(names, column+"_extracted_emails")
+		}
+		for _, extra := range extras {
+			names = append(names, column+"_"+extra.name)
+		}
+	}
+	return names
+}
+
+// columnResults validates each target column of record and returns the
+// results, reporting whether any of them held a valid email
+func (cp *CSVProcessor) columnResults(ctx context.Context, record []string, targets []int, opts ProcessOptions, extras []extraColumn) ([]string, bool) {
+	var values []string
+	hasEmail := false
+	for _, i := range targets {
+		// Short rows are treated as having empty trailing fields
+		var field string
+		if i < len(record) {
+			field = record[i]
+		}
+
+		address, result, found := cp.validateField(ctx, field, opts)
+		values = append(values, fmt.Sprintf("%t", result.Valid))
+		if opts.Reasons {
+			values = append(values, string(result.Reason))
+		}
+		if opts.Extract != ExtractOff {
+			values = append(values, formatExtracted(found, opts.Extract))
+		}
+		for _, extra := range extras {
+			values = append(values, extra.value(ctx, address, result))
+		}
+		if countsAsEmail(result, opts.ExcludeRoleAccounts) {
+			hasEmail = true
+		}
+	}
+	return values, hasEmail
+}
+
+// normalizeFields replaces each valid address among the target fields of
+// record, or all of its fields when targets is nil, with its canonical form
+func (cp *CSVProcessor) normalizeFields(ctx context.Context, record []string, targets []int, normalizer *EmailNormalizer) {
+	if targets == nil {
+		targets = allColumns(record)
+	}
+	for _, i := range targets {
+		if i >= len(record) {
+			continue
+		}
+		if result := cp.validator.ValidateContext(ctx, record[i]); result.Valid {
+			record[i] = normalizer.Normalize(record[i])
+		}
+	}
+}
+
+// validateField validates field. With extraction on, it also returns the
+// addresses embedded in field and, when field is not itself an address,
+// describes the first of them instead.
+func (cp *CSVProcessor) validateField(ctx context.Context, field string, opts ProcessOptions) (string, ValidationResult, []ExtractedEmail) {
+	result := cp.validator.ValidateContext(ctx, field)
+	if opts.Extract == ExtractOff {
+		return field, result, nil
+	}
+
+	found := cp.validator.ExtractEmails(ctx, field)
+	if !result.Valid && len(found) > 0 {
+		return found[0].Address, found[0].Result, found
+	}
+	return field, result, found
+}
+
+// firstValidEmail returns the first address among fields that counts
+// toward has_email and its result, along with every address extracted from
+// fields. When no address counts, it returns the first invalid field that
+// looks like an address, so its typos can still be suggested.
+func (cp *CSVProcessor) firstValidEmail(ctx context.Context, fields []string, opts ProcessOptions) (string, ValidationResult, []ExtractedEmail) {
+	var address string
+	var result ValidationResult
+	var found []ExtractedEmail
+	seen := make(map[string]bool)
+	matched := false
+
+	for _, field := range fields {
+		fieldAddress, fieldResult, fieldFound := cp.validateField(ctx, field, opts)
+		for _, email := range fieldFound {
+			if key := strings.ToLower(email.Result.ASCII); !seen[key] {
+				seen[key] = true
+				found = append(found, email)
+			}
+		}
+
+		switch {
+		case matched:
+		case countsAsEmail(fieldResult, opts.ExcludeRoleAccounts):
+			address, result, matched = fieldAddress, fieldResult, true
+		case address == "" && !fieldResult.Valid && strings.Contains(field, "@"):
+			address, result = fieldAddress, fieldResult
+		}
+
+		// Every field is scanned when extracting all addresses
+		if matched && opts.Extract == ExtractOff {
+			break
+		}
+	}
+	return address, result, found
+}
+
+// countsAsEmail reports whether result counts toward has_email
+func countsAsEmail(result ValidationResult, excludeRoles bool) bool {
+	return result.Valid && !(excludeRoles && result.RoleAccount)
+}
+
+// StoredFile describes a file written to storage
+type StoredFile struct {
+	Path   string
+	Size   int64
+	SHA256 string
+}
+
+// SaveUploadedFile streams an uploaded file to the filesystem, hashing it on the way
+func (cp *CSVProcessor) SaveUploadedFile(src io.Reader, filename string) (*StoredFile, error) {
 	// Create uploads directory if it doesn't exist
-	uploadsDir := "uploads"
-	if err := os.MkdirAll(uploadsDir, 0755); err != nil {
-		return "", fmt.Errorf("failed to create uploads directory: %w", err)
+	if err := os.MkdirAll(cp.storageDir, 0755); err != nil {
+		return nil, fmt.Errorf("failed to create uploads directory: %w", err)
 	}
 
 	// Generate file path
-	filePath := filepath.Join(uploadsDir, filename)
+	filePath := filepath.Join(cp.storageDir, filename)
 
 	// Write file
-	if err := os.WriteFile(filePath, fileData, 0644); err != nil {
-		return "", fmt.Errorf("failed to write uploaded file: %w", err)
+	file, err := os.Create(filePath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create uploaded file: %w", err)
 	}
 
-	return filePath, nil
+	hasher := sha256.New()
+	size, err := io.Copy(io.MultiWriter(file, hasher), src)
+	if err != nil {
+		file.Close()
+		os.Remove(filePath)
+		return nil, fmt.Errorf("failed to write uploaded file: %w", err)
+	}
+	if err := file.Close(); err != nil {
+		os.Remove(filePath)
+		return nil, fmt.Errorf("failed to write uploaded file: %w", err)
+	}
+
+	return &StoredFile{
+		Path:   filePath,
+		Size:   size,
+		SHA256: hex.EncodeToString(hasher.Sum(nil)),
+	}, nil
 }
 
 // GetProcessedFilePath returns the path for the processed file
 func (cp *CSVProcessor) GetProcessedFilePath(jobID string) string {
-	return filepath.Join("uploads", fmt.Sprintf("processed_%s.csv", jobID))
+	return filepath.Join(cp.storageDir, fmt.Sprintf("%s%s.csv", processedFilePrefix, jobID))
+}
+
+// GetErrorReportPath returns the path for the malformed row report of a lenient job
+func (cp *CSVProcessor) GetErrorReportPath(jobID string) string {
+	return filepath.Join(cp.storageDir, fmt.Sprintf("%s%s.csv", errorReportFilePrefix, jobID))
 }
@@ -1,10 +1,20 @@
 package main
 
 import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/csv"
+	"encoding/hex"
+	"errors"
+	"fmt"
 	"os"
 	"path/filepath"
 	"strings"
 	"testing"
+
+	"golang.org/x/text/encoding/charmap"
+	"golang.org/x/text/encoding/unicode"
 )
 
 func TestNewCSVProcessor(t *testing.T) {
@@ -206,25 +216,44 @@ func TestProcessCSVErrorHandling(t *testing.T) {
 	if err == nil {
 		t.Error("Expected error for invalid output path")
 	}
+
+	// A full disk must fail the job instead of leaving a truncated file
+	if _, err := os.Stat("/dev/full"); err == nil {
+		inputFile := filepath.Join(tempDir, "input.csv")
+		if err := os.WriteFile(inputFile, []byte("name,email\nJohn Doe,john@example.com\n"), 0644); err != nil {
+			t.Fatalf("Failed to write test CSV: %v", err)
+		}
+		if err := processor.ProcessCSV(inputFile, "/dev/full"); err == nil {
+			t.Error("Expected error when the output cannot be written")
+		}
+	}
 }
 
 func TestSaveUploadedFile(t *testing.T) {
-	processor := NewCSVProcessor()
-
-	tempDir := t.TempDir()
-
-	// Change to temp directory to avoid creating uploads in project root
-	originalDir, _ := os.Getwd()
-	os.Chdir(tempDir)
-	defer os.Chdir(originalDir)
+	cfg := DefaultConfig()
+	cfg.StorageDir = t.TempDir()
+	processor, err := NewCSVProcessorWithConfig(cfg)
+	if err != nil {
+		t.Fatalf("NewCSVProcessorWithConfig failed: %v", err)
+	}
 
 	testData := []byte("test,data,here\n1,2,3")
 	filename := "test.csv"
 
-	filePath, err := processor.SaveUploadedFile(testData, filename)
+	stored, err := processor.SaveUploadedFile(bytes.NewReader(testData), filename)
 	if err != nil {
 		t.Fatalf("SaveUploadedFile failed: %v", err)
 	}
+	filePath := stored.Path
+
+	// Verify size and hash were computed while streaming
+	if stored.Size != int64(len(testData)) {
+		t.Errorf("File size mismatch. Expected: %d, Got: %d", len(testData), stored.Size)
+	}
+	expectedHash := sha256.Sum256(testData)
+	if stored.SHA256 != hex.EncodeToString(expectedHash[:]) {
+		t.Errorf("File hash mismatch. Expected: %x, Got: %s", expectedHash, stored.SHA256)
+	}
 
 	// Verify file was created
 	if _, err := os.Stat(filePath); os.IsNotExist(err) {
@@ -242,7 +271,7 @@ func TestSaveUploadedFile(t *testing.T) {
 	}
 
 	// Verify file path format
-	expectedPath := filepath.Join("uploads", filename)
+	expectedPath := filepath.Join(cfg.StorageDir, filename)
 	if filePath != expectedPath {
 		t.Errorf("File path mismatch. Expected: %s, Got: %s", expectedPath, filePath)
 	}
@@ -287,3 +316,1343 @@ Test User,test@example.com`
 		<-done
 	}
 }
+
+func TestProcessCSVWithProgress(t *testing.T) {
+	processor := NewCSVProcessor()
+
+	tempDir := t.TempDir()
+	inputFile := filepath.Join(tempDir, "input.csv")
+	outputFile := filepath.Join(tempDir, "output.csv")
+
+	// Enough rows to trigger intermediate progress reports
+	var builder strings.Builder
+	builder.WriteString("name,email\n")
+	for i := 0; i < 1200; i++ {
+		if i%3 == 0 {
+			builder.WriteString(fmt.Sprintf("User %d,invalid-email\n", i))
+		} else {
+			builder.WriteString(fmt.Sprintf("User %d,user%d@example.com\n", i, i))
+		}
+	}
+	testCSV := builder.String()
+
+	err := os.WriteFile(inputFile, []byte(testCSV), 0644)
+	if err != nil {
+		t.Fatalf("Failed to write test CSV: %v", err)
+	}
+
+	var reports []ProcessingProgress
+	err = processor.ProcessCSVWithOptions(context.Background(), inputFile, outputFile, ProcessOptions{
+		Progress: func(progress ProcessingProgress) {
+			reports = append(reports, progress)
+		},
+	})
+	if err != nil {
+		t.Fatalf("ProcessCSVWithOptions failed: %v", err)
+	}
+
+	if len(reports) < 2 {
+		t.Fatalf("Expected intermediate and final progress reports, got %d", len(reports))
+	}
+
+	// Reports should never go backwards
+	for i := 1; i < len(reports); i++ {
+		if reports[i].RowsProcessed < reports[i-1].RowsProcessed || reports[i].BytesRead < reports[i-1].BytesRead {
+			t.Errorf("Progress went backwards between report %d and %d", i-1, i)
+		}
+	}
+
+	final := reports[len(reports)-1]
+	if final.RowsProcessed != 1200 {
+		t.Errorf("RowsProcessed mismatch. Expected: 1200, Got: %d", final.RowsProcessed)
+	}
+	if final.RowsWithEmail != 800 {
+		t.Errorf("RowsWithEmail mismatch. Expected: 800, Got: %d", final.RowsWithEmail)
+	}
+	if final.TotalBytes != int64(len(testCSV)) {
+		t.Errorf("TotalBytes mismatch. Expected: %d, Got: %d", len(testCSV), final.TotalBytes)
+	}
+	if final.BytesRead != final.TotalBytes {
+		t.Errorf("BytesRead mismatch. Expected: %d, Got: %d", final.TotalBytes, final.BytesRead)
+	}
+}
+
+func TestProcessCSVCancelled(t *testing.T) {
+	processor := NewCSVProcessor()
+
+	tempDir := t.TempDir()
+	inputFile := filepath.Join(tempDir, "input.csv")
+	outputFile := filepath.Join(tempDir, "output.csv")
+
+	var builder strings.Builder
+	builder.WriteString("name,email\n")
+	for i := 0; i < 2000; i++ {
+		builder.WriteString(fmt.Sprintf("User %d,user%d@example.com\n", i, i))
+	}
+
+	err := os.WriteFile(inputFile, []byte(builder.String()), 0644)
+	if err != nil {
+		t.Fatalf("Failed to write test CSV: %v", err)
+	}
+
+	// Cancel as soon as the first progress report arrives
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	var lastProgress ProcessingProgress
+	err = processor.ProcessCSVWithOptions(ctx, inputFile, outputFile, ProcessOptions{
+		Progress: func(progress ProcessingProgress) {
+			lastProgress = progress
+			cancel()
+		},
+	})
+
+	if !errors.Is(err, context.Canceled) {
+		t.Fatalf("Expected context.Canceled, got %v", err)
+	}
+	if lastProgress.RowsProcessed >= 2000 {
+		t.Errorf("Processing should stop early, processed %d rows", lastProgress.RowsProcessed)
+	}
+}
+
+func TestNewCSVProcessorWithConfig(t *testing.T) {
+	cfg := DefaultConfig()
+	cfg.StorageDir = filepath.Join(t.TempDir(), "storage")
+
+	processor, err := NewCSVProcessorWithConfig(cfg)
+	if err != nil {
+		t.Fatalf("NewCSVProcessorWithConfig failed: %v", err)
+	}
+
+	expectedPath := filepath.Join(cfg.StorageDir, "processed_job.csv")
+	if path := processor.GetProcessedFilePath("job"); path != expectedPath {
+		t.Errorf("GetProcessedFilePath mismatch. Expected: %s, Got: %s", expectedPath, path)
+	}
+
+	stored, err := processor.SaveUploadedFile(strings.NewReader("name,email"), "test.csv")
+	if err != nil {
+		t.Fatalf("SaveUploadedFile failed: %v", err)
+	}
+	if filepath.Dir(stored.Path) != cfg.StorageDir {
+		t.Errorf("Upload saved outside storage directory: %s", stored.Path)
+	}
+
+	// Invalid validation settings are rejected
+	cfg.Validation.EmailPattern = "("
+	if _, err := NewCSVProcessorWithConfig(cfg); err == nil {
+		t.Error("Expected error for invalid email pattern")
+	}
+
+	cfg.Validation.EmailPattern = ""
+	cfg.Validation.Lists.DisposableFile = filepath.Join(t.TempDir(), "missing.txt")
+	if _, err := NewCSVProcessorWithConfig(cfg); err == nil {
+		t.Error("Expected error for missing disposable domains file")
+	}
+}
+
+// failingReader returns some data and then an error
+type failingReader struct {
+	data []byte
+	read bool
+}
+
+func (r *failingReader) Read(p []byte) (int, error) {
+	if r.read {
+		return 0, errors.New("connection reset")
+	}
+	r.read = true
+	return copy(p, r.data), nil
+}
+
+func TestSaveUploadedFileReadError(t *testing.T) {
+	cfg := DefaultConfig()
+	cfg.StorageDir = t.TempDir()
+	processor, err := NewCSVProcessorWithConfig(cfg)
+	if err != nil {
+		t.Fatalf("NewCSVProcessorWithConfig failed: %v", err)
+	}
+
+	_, err = processor.SaveUploadedFile(&failingReader{data: []byte("name,email\n")}, "partial.csv")
+	if err == nil {
+		t.Fatal("Expected error for failing reader")
+	}
+
+	// Partially written files must not be left behind
+	if _, err := os.Stat(filepath.Join(cfg.StorageDir, "partial.csv")); !os.IsNotExist(err) {
+		t.Error("Partial upload should be removed")
+	}
+}
+
+func TestProcessCSVColumnOutput(t *testing.T) {
+	processor := NewCSVProcessor()
+
+	testCSV := `name,email,backup_email
+John,john@example.com,
+Jane,jane.example.com,jane@backup.org
+Bob,bob@invalid,bob@@example.com
+`
+
+	tests := []struct {
+		name          string
+		opts          ProcessOptions
+		expected      string
+		rowsWithEmail int64
+	}{
+		{
+			name: "Chosen columns with reasons",
+			opts: ProcessOptions{Output: OutputColumns, Columns: []string{"email", "backup_email"}, Reasons: true},
+			expected: `name,email,backup_email,email_email_valid,email_email_reason,backup_email_email_valid,backup_email_email_reason
+John,john@example.com,,true,,false,empty
+Jane,jane.example.com,jane@backup.org,false,missing_at,true,
+Bob,bob@invalid,bob@@example.com,false,bad_tld,false,multiple_at
+`,
+			rowsWithEmail: 2,
+		},
+		{
+			name: "Chosen column without reasons",
+			opts: ProcessOptions{Output: OutputColumns, Columns: []string{"email"}},
+			expected: `name,email,backup_email,email_email_valid
+John,john@example.com,,true
+Jane,jane.example.com,jane@backup.org,false
+Bob,bob@invalid,bob@@example.com,false
+`,
+			rowsWithEmail: 1,
+		},
+		{
+			name: "Every column by default",
+			opts: ProcessOptions{Output: OutputColumns},
+			expected: `name,email,backup_email,name_email_valid,email_email_valid,backup_email_email_valid
+John,john@example.com,,false,true,false
+Jane,jane.example.com,jane@backup.org,false,false,true
+Bob,bob@invalid,bob@@example.com,false,false,false
+`,
+			rowsWithEmail: 2,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tempDir := t.TempDir()
+			inputFile := filepath.Join(tempDir, "input.csv")
+			outputFile := filepath.Join(tempDir, "output.csv")
+			if err := os.WriteFile(inputFile, []byte(testCSV), 0644); err != nil {
+				t.Fatalf("Failed to write test CSV: %v", err)
+			}
+
+			var last ProcessingProgress
+			tt.opts.Progress = func(progress ProcessingProgress) {
+				last = progress
+			}
+			if err := processor.ProcessCSVWithOptions(context.Background(), inputFile, outputFile, tt.opts); err != nil {
+				t.Fatalf("ProcessCSVWithOptions failed: %v", err)
+			}
+
+			outputData, err := os.ReadFile(outputFile)
+			if err != nil {
+				t.Fatalf("Failed to read output file: %v", err)
+			}
+			if string(outputData) != tt.expected {
+				t.Errorf("Output mismatch. Expected:\n%s\nGot:\n%s", tt.expected, outputData)
+			}
+			if last.RowsWithEmail != tt.rowsWithEmail {
+				t.Errorf("RowsWithEmail mismatch. Expected: %d, Got: %d", tt.rowsWithEmail, last.RowsWithEmail)
+			}
+		})
+	}
+}
+
+func TestProcessCSVUnknownColumn(t *testing.T) {
+	processor := NewCSVProcessor()
+
+	tempDir := t.TempDir()
+	inputFile := filepath.Join(tempDir, "input.csv")
+	outputFile := filepath.Join(tempDir, "output.csv")
+	if err := os.WriteFile(inputFile, []byte("name,email\nJohn,john@example.com\n"), 0644); err != nil {
+		t.Fatalf("Failed to write test CSV: %v", err)
+	}
+
+	opts := ProcessOptions{Output: OutputColumns, Columns: []string{"contact"}}
+	err := processor.ProcessCSVWithOptions(context.Background(), inputFile, outputFile, opts)
+
+	var columnErr *ColumnNotFoundError
+	if !errors.As(err, &columnErr) {
+		t.Fatalf("Expected ColumnNotFoundError, got %v", err)
+	}
+	if columnErr.Name != "contact" {
+		t.Errorf("Column mismatch. Expected: contact, Got: %s", columnErr.Name)
+	}
+	if strings.Join(columnErr.Headers, ",") != "name,email" {
+		t.Errorf("Headers mismatch. Expected: name,email, Got: %v", columnErr.Headers)
+	}
+}
+
+func TestProcessCSVTargetColumns(t *testing.T) {
+	processor := NewCSVProcessor()
+
+	// The notes column holds an address that must not count
+	testCSV := `name,email,notes
+John,john@example.com,
+Jane,,ask jane@example.com
+Bob,,bob@example.com
+`
+
+	tests := []struct {
+		name     string
+		opts     ProcessOptions
+		expected []string
+	}{
+		{"Every column", ProcessOptions{}, []string{"true", "false", "true"}},
+		{"By name", ProcessOptions{Columns: []string{"email"}}, []string{"true", "false", "false"}},
+		{"By index", ProcessOptions{ColumnIndexes: []int{2}}, []string{"false", "false", "true"}},
+		{"By name and index", ProcessOptions{Columns: []string{"email"}, ColumnIndexes: []int{2}}, []string{"true", "false", "true"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tempDir := t.TempDir()
+			inputFile := filepath.Join(tempDir, "input.csv")
+			outputFile := filepath.Join(tempDir, "output.csv")
+			if err := os.WriteFile(inputFile, []byte(testCSV), 0644); err != nil {
+				t.Fatalf("Failed to write test CSV: %v", err)
+			}
+
+			if err := processor.ProcessCSVWithOptions(context.Background(), inputFile, outputFile, tt.opts); err != nil {
+				t.Fatalf("ProcessCSVWithOptions failed: %v", err)
+			}
+
+			outputData, err := os.ReadFile(outputFile)
+			if err != nil {
+				t.Fatalf("Failed to read output file: %v", err)
+			}
+			lines := strings.Split(strings.TrimSpace(string(outputData)), "\n")
+			for i, line := range lines[1:] {
+				fields := strings.Split(line, ",")
+				if fields[len(fields)-1] != tt.expected[i] {
+					t.Errorf("Row %d: has_email = %s, expected %s", i+1, fields[len(fields)-1], tt.expected[i])
+				}
+			}
+		})
+	}
+}
+
+func TestProcessCSVColumnIndexOutOfRange(t *testing.T) {
+	processor := NewCSVProcessor()
+
+	tempDir := t.TempDir()
+	inputFile := filepath.Join(tempDir, "input.csv")
+	outputFile := filepath.Join(tempDir, "output.csv")
+	if err := os.WriteFile(inputFile, []byte("name,email\nJohn,john@example.com\n"), 0644); err != nil {
+		t.Fatalf("Failed to write test CSV: %v", err)
+	}
+
+	err := processor.ProcessCSVWithOptions(context.Background(), inputFile, outputFile, ProcessOptions{ColumnIndexes: []int{5}})
+
+	var columnErr *ColumnNotFoundError
+	if !errors.As(err, &columnErr) || columnErr.Index != 5 {
+		t.Errorf("Expected ColumnNotFoundError for index 5, got %v", err)
+	}
+}
+
+func TestReadHeader(t *testing.T) {
+	processor := NewCSVProcessor()
+	tempDir := t.TempDir()
+
+	tests := []struct {
+		name     string
+		content  string
+		expected string
+	}{
+		{"Header row", "name,email\nJohn,john@example.com\n", "name,email"},
+		{"Leading empty rows", "\n\nname,email\n", "name,email"},
+		{"Empty file", "", ""},
+	}
+
+	for i, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(tempDir, fmt.Sprintf("input%d.csv", i))
+			if err := os.WriteFile(path, []byte(tt.content), 0644); err != nil {
+				t.Fatalf("Failed to write test CSV: %v", err)
+			}
+
+			header, err := processor.ReadHeader(path, TextEncoding{Name: "utf-8"}, defaultDialect)
+			if err != nil {
+				t.Fatalf("ReadHeader failed: %v", err)
+			}
+			if strings.Join(header, ",") != tt.expected {
+				t.Errorf("Header mismatch. Expected: %s, Got: %s", tt.expected, strings.Join(header, ","))
+			}
+		})
+	}
+}
+
+func TestProcessCSVASCIIOutput(t *testing.T) {
+	processor := NewCSVProcessor()
+
+	testCSV := `name,email
+José,josé@bücher.de
+Li,用户@例子.广告
+Bob,bob@invalid
+`
+
+	tests := []struct {
+		name     string
+		opts     ProcessOptions
+		expected string
+	}{
+		{
+			name: "Has email mode",
+			opts: ProcessOptions{ASCII: true},
+			expected: `name,email,has_email,email_ascii
+José,josé@bücher.de,true,josé@xn--bcher-kva.de
+Li,用户@例子.广告,true,用户@xn--fsqu00a.xn--4rr70v
+Bob,bob@invalid,false,
+`,
+		},
+		{
+			name: "Columns mode",
+			opts: ProcessOptions{Output: OutputColumns, Columns: []string{"email"}, ASCII: true},
+			expected: `name,email,email_email_valid,email_email_ascii
+José,josé@bücher.de,true,josé@xn--bcher-kva.de
+Li,用户@例子.广告,true,用户@xn--fsqu00a.xn--4rr70v
+Bob,bob@invalid,false,
+`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tempDir := t.TempDir()
+			inputFile := filepath.Join(tempDir, "input.csv")
+			outputFile := filepath.Join(tempDir, "output.csv")
+			if err := os.WriteFile(inputFile, []byte(testCSV), 0644); err != nil {
+				t.Fatalf("Failed to write test CSV: %v", err)
+			}
+
+			if err := processor.ProcessCSVWithOptions(context.Background(), inputFile, outputFile, tt.opts); err != nil {
+				t.Fatalf("ProcessCSVWithOptions failed: %v", err)
+			}
+
+			outputData, err := os.ReadFile(outputFile)
+			if err != nil {
+				t.Fatalf("Failed to read output file: %v", err)
+			}
+			if string(outputData) != tt.expected {
+				t.Errorf("Output mismatch. Expected:\n%s\nGot:\n%s", tt.expected, outputData)
+			}
+		})
+	}
+}
+
+func TestProcessCSVVerifyMailbox(t *testing.T) {
+	server := newFakeSMTPServer(t, "alice@example.com")
+
+	cfg := DefaultConfig()
+	cfg.StorageDir = t.TempDir()
+	cfg.Validation.SMTP.Enabled = true
+	cfg.Validation.SMTP.Server = server.Addr()
+
+	processor, err := NewCSVProcessorWithConfig(cfg)
+	if err != nil {
+		t.Fatalf("NewCSVProcessorWithConfig failed: %v", err)
+	}
+	if !processor.CanVerifyMailbox() {
+		t.Fatal("Expected mailbox verification to be enabled")
+	}
+
+	testCSV := `name,email
+Alice,alice@example.com
+Bob,bob@example.com
+Carol,carol@catchall.example
+Dan,not-an-email
+`
+
+	tests := []struct {
+		name     string
+		opts     ProcessOptions
+		expected string
+	}{
+		{
+			name: "Has email mode",
+			opts: ProcessOptions{VerifyMailbox: true},
+			expected: `name,email,has_email,mailbox_status
+Alice,alice@example.com,true,deliverable
+Bob,bob@example.com,true,undeliverable
+Carol,carol@catchall.example,true,catch_all
+Dan,not-an-email,false,
+`,
+		},
+		{
+			name: "Columns mode",
+			opts: ProcessOptions{Output: OutputColumns, Columns: []string{"email"}, VerifyMailbox: true},
+			expected: `name,email,email_email_valid,email_mailbox_status
+Alice,alice@example.com,true,deliverable
+Bob,bob@example.com,true,undeliverable
+Carol,carol@catchall.example,true,catch_all
+Dan,not-an-email,false,
+`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tempDir := t.TempDir()
+			inputFile := filepath.Join(tempDir, "input.csv")
+			outputFile := filepath.Join(tempDir, "output.csv")
+			if err := os.WriteFile(inputFile, []byte(testCSV), 0644); err != nil {
+				t.Fatalf("Failed to write test CSV: %v", err)
+			}
+
+			if err := processor.ProcessCSVWithOptions(context.Background(), inputFile, outputFile, tt.opts); err != nil {
+				t.Fatalf("ProcessCSVWithOptions failed: %v", err)
+			}
+
+			outputData, err := os.ReadFile(outputFile)
+			if err != nil {
+				t.Fatalf("Failed to read output file: %v", err)
+			}
+			if string(outputData) != tt.expected {
+				t.Errorf("Output mismatch. Expected:\n%s\nGot:\n%s", tt.expected, outputData)
+			}
+		})
+	}
+}
+
+func TestProcessCSVVerifyMailboxDisabled(t *testing.T) {
+	processor := NewCSVProcessor()
+	if processor.CanVerifyMailbox() {
+		t.Fatal("Expected mailbox verification to be disabled by default")
+	}
+
+	tempDir := t.TempDir()
+	inputFile := filepath.Join(tempDir, "input.csv")
+	if err := os.WriteFile(inputFile, []byte("email\nalice@example.com\n"), 0644); err != nil {
+		t.Fatalf("Failed to write test CSV: %v", err)
+	}
+
+	err := processor.ProcessCSVWithOptions(context.Background(), inputFile, filepath.Join(tempDir, "output.csv"), ProcessOptions{VerifyMailbox: true})
+	if !errors.Is(err, errMailboxVerificationDisabled) {
+		t.Errorf("Expected errMailboxVerificationDisabled, got %v", err)
+	}
+}
+
+func TestProcessCSVProviderColumns(t *testing.T) {
+	listFile := filepath.Join(t.TempDir(), "disposable.txt")
+	if err := os.WriteFile(listFile, []byte("throwaway.example\n"), 0644); err != nil {
+		t.Fatalf("Failed to write list: %v", err)
+	}
+
+	cfg := DefaultConfig()
+	cfg.StorageDir = t.TempDir()
+	cfg.Validation.Lists.DisposableFile = listFile
+
+	processor, err := NewCSVProcessorWithConfig(cfg)
+	if err != nil {
+		t.Fatalf("NewCSVProcessorWithConfig failed: %v", err)
+	}
+
+	testCSV := `name,email
+Alice,alice@gmail.com
+Bob,bob@mailinator.com
+Carol,carol@throwaway.example
+Dan,dan@company.example
+Eve,not-an-email
+`
+
+	tests := []struct {
+		name     string
+		opts     ProcessOptions
+		expected string
+	}{
+		{
+			name: "Has email mode",
+			opts: ProcessOptions{Providers: true},
+			expected: `name,email,has_email,is_disposable,is_free_provider
+Alice,alice@gmail.com,true,false,true
+Bob,bob@mailinator.com,true,true,false
+Carol,carol@throwaway.example,true,true,false
+Dan,dan@company.example,true,false,false
+Eve,not-an-email,false,,
+`,
+		},
+		{
+			name: "Columns mode",
+			opts: ProcessOptions{Output: OutputColumns, Columns: []string{"email"}, Providers: true},
+			expected: `name,email,email_email_valid,email_is_disposable,email_is_free_provider
+Alice,alice@gmail.com,true,false,true
+Bob,bob@mailinator.com,true,true,false
+Carol,carol@throwaway.example,true,true,false
+Dan,dan@company.example,true,false,false
+Eve,not-an-email,false,,
+`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tempDir := t.TempDir()
+			inputFile := filepath.Join(tempDir, "input.csv")
+			outputFile := filepath.Join(tempDir, "output.csv")
+			if err := os.WriteFile(inputFile, []byte(testCSV), 0644); err != nil {
+				t.Fatalf("Failed to write test CSV: %v", err)
+			}
+
+			if err := processor.ProcessCSVWithOptions(context.Background(), inputFile, outputFile, tt.opts); err != nil {
+				t.Fatalf("ProcessCSVWithOptions failed: %v", err)
+			}
+
+			outputData, err := os.ReadFile(outputFile)
+			if err != nil {
+				t.Fatalf("Failed to read output file: %v", err)
+			}
+			if string(outputData) != tt.expected {
+				t.Errorf("Output mismatch. Expected:\n%s\nGot:\n%s", tt.expected, outputData)
+			}
+		})
+	}
+}
+
+func TestProcessCSVRoleAccounts(t *testing.T) {
+	processor := NewCSVProcessor()
+
+	testCSV := `name,email,backup
+Support,support@company.example,
+Jane,info@company.example,jane@company.example
+John,john@company.example,
+`
+
+	tests := []struct {
+		name              string
+		opts              ProcessOptions
+		expected          string
+		expectedWithEmail int64
+	}{
+		{
+			name: "Role accounts count",
+			opts: ProcessOptions{RoleAccounts: true},
+			expected: `name,email,backup,has_email,is_role_account
+Support,support@company.example,,true,true
+Jane,info@company.example,jane@company.example,true,true
+John,john@company.example,,true,false
+`,
+			expectedWithEmail: 3,
+		},
+		{
+			name: "Role accounts excluded",
+			opts: ProcessOptions{RoleAccounts: true, ExcludeRoleAccounts: true},
+			expected: `name,email,backup,has_email,is_role_account
+Support,support@company.example,,false,
+Jane,info@company.example,jane@company.example,true,false
+John,john@company.example,,true,false
+`,
+			expectedWithEmail: 2,
+		},
+		{
+			name: "Columns mode",
+			opts: ProcessOptions{Output: OutputColumns, Columns: []string{"email"}, RoleAccounts: true, ExcludeRoleAccounts: true},
+			expected: `name,email,backup,email_email_valid,email_is_role_account
+Support,support@company.example,,true,true
+Jane,info@company.example,jane@company.example,true,true
+John,john@company.example,,true,false
+`,
+			expectedWithEmail: 1,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tempDir := t.TempDir()
+			inputFile := filepath.Join(tempDir, "input.csv")
+			outputFile := filepath.Join(tempDir, "output.csv")
+			if err := os.WriteFile(inputFile, []byte(testCSV), 0644); err != nil {
+				t.Fatalf("Failed to write test CSV: %v", err)
+			}
+
+			var lastProgress ProcessingProgress
+			tt.opts.Progress = func(progress ProcessingProgress) {
+				lastProgress = progress
+			}
+			if err := processor.ProcessCSVWithOptions(context.Background(), inputFile, outputFile, tt.opts); err != nil {
+				t.Fatalf("ProcessCSVWithOptions failed: %v", err)
+			}
+
+			outputData, err := os.ReadFile(outputFile)
+			if err != nil {
+				t.Fatalf("Failed to read output file: %v", err)
+			}
+			if string(outputData) != tt.expected {
+				t.Errorf("Output mismatch. Expected:\n%s\nGot:\n%s", tt.expected, outputData)
+			}
+			if lastProgress.RowsWithEmail != tt.expectedWithEmail {
+				t.Errorf("RowsWithEmail mismatch. Expected: %d, Got: %d", tt.expectedWithEmail, lastProgress.RowsWithEmail)
+			}
+		})
+	}
+}
+
+func TestProcessCSVSuggestions(t *testing.T) {
+	processor := NewCSVProcessor()
+
+	testCSV := `name,email
+Jane,jane@gmial.com
+Bob,bob@yahoo
+Amy,amy@gmail.com
+Dan,not-an-email
+`
+
+	tests := []struct {
+		name     string
+		opts     ProcessOptions
+		expected string
+	}{
+		{
+			name: "Has email mode",
+			opts: ProcessOptions{Suggestions: true},
+			expected: `name,email,has_email,email_suggestion
+Jane,jane@gmial.com,true,jane@gmail.com
+Bob,bob@yahoo,false,bob@yahoo.com
+Amy,amy@gmail.com,true,
+Dan,not-an-email,false,
+`,
+		},
+		{
+			name: "Columns mode",
+			opts: ProcessOptions{Output: OutputColumns, Columns: []string{"email"}, Reasons: true, Suggestions: true},
+			expected: `name,email,email_email_valid,email_email_reason,email_email_suggestion
+Jane,jane@gmial.com,true,,jane@gmail.com
+Bob,bob@yahoo,false,bad_tld,bob@yahoo.com
+Amy,amy@gmail.com,true,,
+Dan,not-an-email,false,missing_at,
+`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tempDir := t.TempDir()
+			inputFile := filepath.Join(tempDir, "input.csv")
+			outputFile := filepath.Join(tempDir, "output.csv")
+			if err := os.WriteFile(inputFile, []byte(testCSV), 0644); err != nil {
+				t.Fatalf("Failed to write test CSV: %v", err)
+			}
+
+			if err := processor.ProcessCSVWithOptions(context.Background(), inputFile, outputFile, tt.opts); err != nil {
+				t.Fatalf("ProcessCSVWithOptions failed: %v", err)
+			}
+
+			outputData, err := os.ReadFile(outputFile)
+			if err != nil {
+				t.Fatalf("Failed to read output file: %v", err)
+			}
+			if string(outputData) != tt.expected {
+				t.Errorf("Output mismatch. Expected:\n%s\nGot:\n%s", tt.expected, outputData)
+			}
+		})
+	}
+}
+
+func TestProcessCSVNormalize(t *testing.T) {
+	processor := NewCSVProcessor()
+
+	testCSV := `name,email,backup
+Jane,Jane.Doe+news@GMail.com,jane@Example.COM
+Bob,bob+work@Example.com,not-an-email
+`
+
+	tests := []struct {
+		name     string
+		opts     ProcessOptions
+		expected string
+	}{
+		{
+			name: "Column",
+			opts: ProcessOptions{Normalize: NormalizeColumn},
+			expected: `name,email,backup,has_email,normalized_email
+Jane,Jane.Doe+news@GMail.com,jane@Example.COM,true,janedoe@gmail.com
+Bob,bob+work@Example.com,not-an-email,true,bob+work@example.com
+`,
+		},
+		{
+			name: "Column with stripped tags",
+			opts: ProcessOptions{Output: OutputColumns, Columns: []string{"email"}, Normalize: NormalizeColumn, StripTags: true},
+			expected: `name,email,backup,email_email_valid,email_normalized_email
+Jane,Jane.Doe+news@GMail.com,jane@Example.COM,true,janedoe@gmail.com
+Bob,bob+work@Example.com,not-an-email,true,bob@example.com
+`,
+		},
+		{
+			name: "In place",
+			opts: ProcessOptions{Normalize: NormalizeInPlace},
+			expected: `name,email,backup,has_email
+Jane,janedoe@gmail.com,jane@example.com,true
+Bob,bob+work@example.com,not-an-email,true
+`,
+		},
+		{
+			name: "In place for target columns",
+			opts: ProcessOptions{Output: OutputColumns, Columns: []string{"backup"}, Normalize: NormalizeInPlace},
+			expected: `name,email,backup,backup_email_valid
+Jane,Jane.Doe+news@GMail.com,jane@example.com,true
+Bob,bob+work@Example.com,not-an-email,false
+`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tempDir := t.TempDir()
+			inputFile := filepath.Join(tempDir, "input.csv")
+			outputFile := filepath.Join(tempDir, "output.csv")
+			if err := os.WriteFile(inputFile, []byte(testCSV), 0644); err != nil {
+				t.Fatalf("Failed to write test CSV: %v", err)
+			}
+
+			if err := processor.ProcessCSVWithOptions(context.Background(), inputFile, outputFile, tt.opts); err != nil {
+				t.Fatalf("ProcessCSVWithOptions failed: %v", err)
+			}
+
+			outputData, err := os.ReadFile(outputFile)
+			if err != nil {
+				t.Fatalf("Failed to read output file: %v", err)
+			}
+			if string(outputData) != tt.expected {
+				t.Errorf("Output mismatch. Expected:\n%s\nGot:\n%s", tt.expected, outputData)
+			}
+		})
+	}
+}
+
+func TestProcessCSVExtract(t *testing.T) {
+	processor := NewCSVProcessor()
+
+	testCSV := `name,notes
+John,Contact: John <john@example.com>
+Ann,mailto:a@example.com; c@example.org
+Bob,no address here
+`
+
+	tests := []struct {
+		name     string
+		opts     ProcessOptions
+		expected string
+	}{
+		{
+			name: "Without extraction",
+			opts: ProcessOptions{},
+			expected: `name,notes,has_email
+John,Contact: John <john@example.com>,false
+Ann,mailto:a@example.com; c@example.org,false
+Bob,no address here,false
+`,
+		},
+		{
+			name: "First address",
+			opts: ProcessOptions{Extract: ExtractFirst, ASCII: true},
+			expected: `name,notes,has_email,extracted_emails,email_ascii
+John,Contact: John <john@example.com>,true,john@example.com,john@example.com
+Ann,mailto:a@example.com; c@example.org,true,a@example.com,a@example.com
+Bob,no address here,false,,
+`,
+		},
+		{
+			name: "All addresses in columns mode",
+			opts: ProcessOptions{Output: OutputColumns, Columns: []string{"notes"}, Reasons: true, Extract: ExtractAll},
+			expected: `name,notes,notes_email_valid,notes_email_reason,notes_extracted_emails
+John,Contact: John <john@example.com>,true,,john@example.com
+Ann,mailto:a@example.com; c@example.org,true,,a@example.com;c@example.org
+Bob,no address here,false,missing_at,
+`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tempDir := t.TempDir()
+			inputFile := filepath.Join(tempDir, "input.csv")
+			outputFile := filepath.Join(tempDir, "output.csv")
+			if err := os.WriteFile(inputFile, []byte(testCSV), 0644); err != nil {
+				t.Fatalf("Failed to write test CSV: %v", err)
+			}
+
+			if err := processor.ProcessCSVWithOptions(context.Background(), inputFile, outputFile, tt.opts); err != nil {
+				t.Fatalf("ProcessCSVWithOptions failed: %v", err)
+			}
+
+			outputData, err := os.ReadFile(outputFile)
+			if err != nil {
+				t.Fatalf("Failed to read output file: %v", err)
+			}
+			if string(outputData) != tt.expected {
+				t.Errorf("Output mismatch. Expected:\n%s\nGot:\n%s", tt.expected, outputData)
+			}
+		})
+	}
+}
+
+func TestProcessCSVDialect(t *testing.T) {
+	processor := NewCSVProcessor()
+	no := false
+
+	tests := []struct {
+		name        string
+		input       string
+		opts        ProcessOptions
+		expected    string
+		rows        int64
+		expectError bool
+	}{
+		{
+			name:  "Semicolons",
+			input: "name;email\nDoe, John;john@example.com\nJane;invalid\n",
+			opts:  ProcessOptions{},
+			expected: `name;email;has_email
+Doe, John;john@example.com;true
+Jane;invalid;false
+`,
+			rows: 2,
+		},
+		{
+			name:     "Tabs in columns mode",
+			input:    "name\temail\nJohn\tjohn@example.com\n",
+			opts:     ProcessOptions{Output: OutputColumns, Columns: []string{"email"}},
+			expected: "name\temail\temail_email_valid\nJohn\tjohn@example.com\ttrue\n",
+			rows:     1,
+		},
+		{
+			name:  "Single quotes",
+			input: "'name','email'\n'Doe, John','john@example.com'\n",
+			opts:  ProcessOptions{},
+			expected: `name,email,has_email
+"Doe, John",john@example.com,true
+`,
+			rows: 1,
+		},
+		{
+			name:  "Headerless",
+			input: "John,john@example.com\nJane,invalid\n",
+			opts:  ProcessOptions{},
+			expected: `John,john@example.com,true
+Jane,invalid,false
+`,
+			rows: 2,
+		},
+		{
+			name:  "Headerless columns mode",
+			input: "John,john@example.com\n",
+			opts:  ProcessOptions{Output: OutputColumns, ColumnIndexes: []int{1}, Reasons: true},
+			expected: `John,john@example.com,true,
+`,
+			rows: 1,
+		},
+		{
+			name:  "Header override",
+			input: "name,email\nJohn,john@example.com\n",
+			opts:  ProcessOptions{HasHeader: &no},
+			expected: `name,email,false
+John,john@example.com,true
+`,
+			rows: 2,
+		},
+		{
+			name:        "Headerless columns by name",
+			input:       "John,john@example.com\n",
+			opts:        ProcessOptions{Columns: []string{"email"}},
+			expectError: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tempDir := t.TempDir()
+			inputFile := filepath.Join(tempDir, "input.csv")
+			outputFile := filepath.Join(tempDir, "output.csv")
+			if err := os.WriteFile(inputFile, []byte(tt.input), 0644); err != nil {
+				t.Fatalf("Failed to write test CSV: %v", err)
+			}
+
+			var progress ProcessingProgress
+			tt.opts.Progress = func(p ProcessingProgress) { progress = p }
+
+			err := processor.ProcessCSVWithOptions(context.Background(), inputFile, outputFile, tt.opts)
+			if tt.expectError {
+				if !errors.Is(err, errColumnsNeedHeader) {
+					t.Errorf("Expected errColumnsNeedHeader, got %v", err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("ProcessCSVWithOptions failed: %v", err)
+			}
+
+			outputData, err := os.ReadFile(outputFile)
+			if err != nil {
+				t.Fatalf("Failed to read output file: %v", err)
+			}
+			if string(outputData) != tt.expected {
+				t.Errorf("Output mismatch. Expected:\n%s\nGot:\n%s", tt.expected, outputData)
+			}
+
+			// Every row of a headerless file is data
+			if progress.RowsProcessed != tt.rows {
+				t.Errorf("Rows processed mismatch. Expected: %d, Got: %d", tt.rows, progress.RowsProcessed)
+			}
+		})
+	}
+}
+
+func TestProcessCSVOutputColumn(t *testing.T) {
+	processor := NewCSVProcessor()
+	no := false
+
+	tests := []struct {
+		name        string
+		input       string
+		opts        ProcessOptions
+		expected    string
+		expectError bool
+	}{
+		{
+			name:     "Custom name",
+			input:    "name,email\nJohn,john@example.com\n",
+			opts:     ProcessOptions{OutputColumn: "valid"},
+			expected: "name,email,valid\nJohn,john@example.com,true\n",
+		},
+		{
+			name:     "Suffix by default",
+			input:    "email,has_email\njohn@example.com,yes\n",
+			opts:     ProcessOptions{},
+			expected: "email,has_email,has_email_2\njohn@example.com,yes,true\n",
+		},
+		{
+			name:     "Overwrite",
+			input:    "email,valid,notes\njohn@example.com,,x\ninvalid,true,y\n",
+			opts:     ProcessOptions{OutputColumn: "valid", OnCollision: CollisionOverwrite},
+			expected: "email,valid,notes\njohn@example.com,true,x\ninvalid,false,y\n",
+		},
+		{
+			name:     "Overwrite in columns mode",
+			input:    "email,email_email_valid\njohn@example.com,\n",
+			opts:     ProcessOptions{Output: OutputColumns, Columns: []string{"email"}, Reasons: true, OnCollision: CollisionOverwrite},
+			expected: "email,email_email_valid,email_email_reason\njohn@example.com,true,\n",
+		},
+		{
+			name:     "Clashing appended columns",
+			input:    "email\njohn@example.com\n",
+			opts:     ProcessOptions{OutputColumn: "email_ascii", ASCII: true, OnCollision: CollisionFail},
+			expected: "email,email_ascii,email_ascii_2\njohn@example.com,true,john@example.com\n",
+		},
+		{
+			name:     "Headerless",
+			input:    "has_email,john@example.com\n",
+			opts:     ProcessOptions{HasHeader: &no, OnCollision: CollisionFail},
+			expected: "has_email,john@example.com,true\n",
+		},
+		{
+			name:        "Fail",
+			input:       "email,has_email\njohn@example.com,yes\n",
+			opts:        ProcessOptions{OnCollision: CollisionFail},
+			expectError: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tempDir := t.TempDir()
+			inputFile := filepath.Join(tempDir, "input.csv")
+			outputFile := filepath.Join(tempDir, "output.csv")
+			if err := os.WriteFile(inputFile, []byte(tt.input), 0644); err != nil {
+				t.Fatalf("Failed to write test CSV: %v", err)
+			}
+
+			err := processor.ProcessCSVWithOptions(context.Background(), inputFile, outputFile, tt.opts)
+			if tt.expectError {
+				var collisionErr *ColumnCollisionError
+				if !errors.As(err, &collisionErr) {
+					t.Errorf("Expected ColumnCollisionError, got %v", err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("ProcessCSVWithOptions failed: %v", err)
+			}
+
+			outputData, err := os.ReadFile(outputFile)
+			if err != nil {
+				t.Fatalf("Failed to read output file: %v", err)
+			}
+			if string(outputData) != tt.expected {
+				t.Errorf("Output mismatch. Expected:\n%s\nGot:\n%s", tt.expected, outputData)
+			}
+		})
+	}
+}
+
+func TestProcessCSVLenient(t *testing.T) {
+	processor := NewCSVProcessor()
+	input := "name,email\nJohn,john@example.com\nJane\nBob,\"bob\"@example.com\nAnn,ann@example.com\n"
+	report := `line,byte_offset,raw,error
+3,33,Jane,"wrong number of fields: expected 2, got 1"
+4,38,"Bob,""bob""@example.com","extraneous or missing "" in quoted-field"
+`
+
+	tests := []struct {
+		name           string
+		input          string
+		opts           ProcessOptions
+		expected       string
+		expectedReport string
+		expectedErr    error
+	}{
+		{
+			name:  "Skip",
+			input: input,
+			opts:  ProcessOptions{Lenient: LenientSkip},
+			expected: `name,email,has_email
+John,john@example.com,true
+Ann,ann@example.com,true
+`,
+			expectedReport: report,
+		},
+		{
+			name:  "Pass through",
+			input: input,
+			opts:  ProcessOptions{Lenient: LenientPass},
+			expected: `name,email,has_email
+John,john@example.com,true
+Jane
+Bob,"bob"@example.com
+Ann,ann@example.com,true
+`,
+			expectedReport: report,
+		},
+		{
+			name:  "Blank lines and CRLF",
+			input: "name;email\r\n\r\nJane\r\nJohn;john@example.com\r\n",
+			opts:  ProcessOptions{Lenient: LenientSkip},
+			expected: `name;email;has_email
+John;john@example.com;true
+`,
+			expectedReport: `line,byte_offset,raw,error
+3,14,Jane,"wrong number of fields: expected 2, got 1"
+`,
+		},
+		{
+			name:  "Pass through CRLF",
+			input: strings.ReplaceAll(input, "\n", "\r\n"),
+			opts:  ProcessOptions{Lenient: LenientPass},
+			expected: "name,email,has_email\r\n" +
+				"John,john@example.com,true\r\n" +
+				"Jane\r\n" +
+				"Bob,\"bob\"@example.com\r\n" +
+				"Ann,ann@example.com,true\r\n",
+			expectedReport: `line,byte_offset,raw,error
+3,35,Jane,"wrong number of fields: expected 2, got 1"
+4,41,"Bob,""bob""@example.com","extraneous or missing "" in quoted-field"
+`,
+		},
+		{
+			name:        "Stray quote in first row",
+			input:       "name,\"email\nJohn,john@example.com\nAnn,ann@example.com\n",
+			opts:        ProcessOptions{Lenient: LenientSkip},
+			expectedErr: errMalformedFirstRow,
+		},
+		{
+			name:        "Strict",
+			input:       input,
+			opts:        ProcessOptions{},
+			expectedErr: csv.ErrFieldCount,
+		},
+		{
+			name:        "Too many errors",
+			input:       input,
+			opts:        ProcessOptions{Lenient: LenientSkip, MaxErrors: 1},
+			expectedErr: errTooManyRowErrors,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tempDir := t.TempDir()
+			inputFile := filepath.Join(tempDir, "input.csv")
+			outputFile := filepath.Join(tempDir, "output.csv")
+			reportFile := filepath.Join(tempDir, "errors.csv")
+			if err := os.WriteFile(inputFile, []byte(tt.input), 0644); err != nil {
+				t.Fatalf("Failed to write test CSV: %v", err)
+			}
+
+			tt.opts.ErrorReportPath = reportFile
+			err := processor.ProcessCSVWithOptions(context.Background(), inputFile, outputFile, tt.opts)
+			if tt.expectedErr != nil {
+				if !errors.Is(err, tt.expectedErr) {
+					t.Errorf("Expected %v, got %v", tt.expectedErr, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("ProcessCSVWithOptions failed: %v", err)
+			}
+
+			outputData, err := os.ReadFile(outputFile)
+			if err != nil {
+				t.Fatalf("Failed to read output file: %v", err)
+			}
+			if string(outputData) != tt.expected {
+				t.Errorf("Output mismatch. Expected:\n%s\nGot:\n%s", tt.expected, outputData)
+			}
+
+			reportData, err := os.ReadFile(reportFile)
+			if err != nil {
+				t.Fatalf("Failed to read error report: %v", err)
+			}
+			if string(reportData) != tt.expectedReport {
+				t.Errorf("Report mismatch. Expected:\n%s\nGot:\n%s", tt.expectedReport, reportData)
+			}
+		})
+	}
+}
+
+func TestProcessCSVEncoding(t *testing.T) {
+	processor := NewCSVProcessor()
+	utf16LE := unicode.UTF16(unicode.LittleEndian, unicode.UseBOM).NewEncoder()
+	windows1252 := charmap.Windows1252.NewEncoder()
+
+	input := "name;email\nJosé Müller;jose@example.com\n"
+	expected := "name;email;has_email\nJosé Müller;jose@example.com;true\n"
+
+	tests := []struct {
+		name     string
+		input    string
+		opts     ProcessOptions
+		expected string
+	}{
+		{"UTF-16LE with BOM", mustEncode(t, utf16LE.String, input), ProcessOptions{}, expected},
+		{"UTF-16LE kept", mustEncode(t, utf16LE.String, input), ProcessOptions{KeepEncoding: true}, mustEncode(t, utf16LE.String, expected)},
+		{"Windows-1252", mustEncode(t, windows1252.String, input), ProcessOptions{}, expected},
+		{"Windows-1252 kept", mustEncode(t, windows1252.String, input), ProcessOptions{KeepEncoding: true}, mustEncode(t, windows1252.String, expected)},
+		{"UTF-8 BOM", "\ufeff" + input, ProcessOptions{}, expected},
+		{"Explicit encoding", mustEncode(t, charmap.ISO8859_15.NewEncoder().String, "name;email\nJosé;€@example.com\n"), ProcessOptions{Encoding: "iso-8859-15"}, "name;email;has_email\nJosé;€@example.com;true\n"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tempDir := t.TempDir()
+			inputFile := filepath.Join(tempDir, "input.csv")
+			outputFile := filepath.Join(tempDir, "output.csv")
+			if err := os.WriteFile(inputFile, []byte(tt.input), 0644); err != nil {
+				t.Fatalf("Failed to write test CSV: %v", err)
+			}
+
+			var progress ProcessingProgress
+			tt.opts.Progress = func(p ProcessingProgress) { progress = p }
+
+			if err := processor.ProcessCSVWithOptions(context.Background(), inputFile, outputFile, tt.opts); err != nil {
+				t.Fatalf("ProcessCSVWithOptions failed: %v", err)
+			}
+
+			outputData, err := os.ReadFile(outputFile)
+			if err != nil {
+				t.Fatalf("Failed to read output file: %v", err)
+			}
+			if string(outputData) != tt.expected {
+				t.Errorf("Output mismatch. Expected:\n%q\nGot:\n%q", tt.expected, outputData)
+			}
+			if progress.BytesRead != progress.TotalBytes {
+				t.Errorf("BytesRead mismatch. Expected: %d, Got: %d", progress.TotalBytes, progress.BytesRead)
+			}
+		})
+	}
+}
+
+func TestProcessCSVDomainPolicy(t *testing.T) {
+	cfg := DefaultConfig()
+	cfg.StorageDir = t.TempDir()
+	cfg.Validation.Policies = map[string]DomainPolicyConfig{
+		"acme": {Allow: []string{".acme.com"}},
+	}
+
+	processor, err := NewCSVProcessorWithConfig(cfg)
+	if err != nil {
+		t.Fatalf("NewCSVProcessorWithConfig failed: %v", err)
+	}
+
+	testCSV := `name,email
+Jane,jane@acme.com
+Bob,bob@rival.com
+Ann,ann@old.acme.com
+Dan,not-an-email
+`
+
+	tests := []struct {
+		name     string
+		opts     ProcessOptions
+		expected string
+	}{
+		{
+			name: "Named policy",
+			opts: ProcessOptions{Policy: "acme"},
+			expected: `name,email,has_email,policy_violation
+Jane,jane@acme.com,true,
+Bob,bob@rival.com,false,not_allowed
+Ann,ann@old.acme.com,true,
+Dan,not-an-email,false,
+`,
+		},
+		{
+			name: "Named and inline policy",
+			opts: ProcessOptions{Output: OutputColumns, Columns: []string{"email"}, Reasons: true, Policy: "acme", DenyDomains: []string{"old.acme.com"}},
+			expected: `name,email,email_email_valid,email_email_reason,email_policy_violation
+Jane,jane@acme.com,true,,
+Bob,bob@rival.com,false,policy_violation,not_allowed
+Ann,ann@old.acme.com,false,policy_violation,denied:old.acme.com
+Dan,not-an-email,false,missing_at,
+`,
+		},
+		{
+			name: "Named and inline allow-lists",
+			opts: ProcessOptions{Policy: "acme", AllowDomains: []string{"rival.com", "old.acme.com"}},
+			expected: `name,email,has_email,policy_violation
+Jane,jane@acme.com,false,not_allowed
+Bob,bob@rival.com,false,not_allowed
+Ann,ann@old.acme.com,true,
+Dan,not-an-email,false,
+`,
+		},
+		{
+			name: "Inline deny-list",
+			opts: ProcessOptions{DenyDomains: []string{"*.com"}},
+			expected: `name,email,has_email,policy_violation
+Jane,jane@acme.com,false,denied:*.com
+Bob,bob@rival.com,false,denied:*.com
+Ann,ann@old.acme.com,false,denied:*.com
+Dan,not-an-email,false,
+`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tempDir := t.TempDir()
+			inputFile := filepath.Join(tempDir, "input.csv")
+			outputFile := filepath.Join(tempDir, "output.csv")
+			if err := os.WriteFile(inputFile, []byte(testCSV), 0644); err != nil {
+				t.Fatalf("Failed to write test CSV: %v", err)
+			}
+
+			if err := processor.ProcessCSVWithOptions(context.Background(), inputFile, outputFile, tt.opts); err != nil {
+				t.Fatalf("ProcessCSVWithOptions failed: %v", err)
+			}
+
+			outputData, err := os.ReadFile(outputFile)
+			if err != nil {
+				t.Fatalf("Failed to read output file: %v", err)
+			}
+			if string(outputData) != tt.expected {
+				t.Errorf("Output mismatch. Expected:\n%s\nGot:\n%s", tt.expected, outputData)
+			}
+		})
+	}
+
+	// Unknown policies are rejected
+	if _, err := processor.ResolvePolicy(ProcessOptions{Policy: "missing"}); err == nil {
+		t.Error("Expected error for unknown policy")
+	}
+}
@@ -0,0 +1,240 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"net"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// defaultDNSTimeout bounds a single domain lookup
+	defaultDNSTimeout = 5 * time.Second
+
+	// defaultDNSCacheTTL is how long a domain that can receive mail is remembered
+	defaultDNSCacheTTL = time.Hour
+
+	// defaultDNSNegativeCacheTTL is how long a domain without a mail server is remembered
+	defaultDNSNegativeCacheTTL = 5 * time.Minute
+
+	// defaultDNSMaxConcurrent is the number of lookups allowed in flight at once
+	defaultDNSMaxConcurrent = 16
+
+	// maxDNSCacheEntries triggers removal of expired entries once the cache grows past it
+	maxDNSCacheEntries = 10000
+)
+
+// DomainStatus is the outcome of looking up a domain's mail servers
+type DomainStatus string
+
+const (
+	// DomainMX means the domain publishes MX records
+	DomainMX DomainStatus = "mx"
+
+	// DomainAddress means the domain has no MX records but has A or AAAA
+	// records, so mail is delivered to the domain itself
+	DomainAddress DomainStatus = "a"
+
+	// DomainNone means the domain does not exist, publishes a null MX or
+	// has no records that could receive mail
+	DomainNone DomainStatus = "none"
+
+	// DomainUnknown means the lookup timed out or failed, so nothing is known
+	DomainUnknown DomainStatus = "unknown"
+)
+
+// DomainCheckerOptions configures a DomainChecker
+type DomainCheckerOptions struct {
+	// Resolver is the host:port of the DNS server to query; the system
+	// resolver is used when empty
+	Resolver string
+
+	Timeout          time.Duration
+	CacheTTL         time.Duration
+	NegativeCacheTTL time.Duration
+	MaxConcurrent    int
+}
+
+// DomainChecker verifies that email domains can receive mail by resolving
+// their MX records, falling back to A/AAAA records
+type DomainChecker struct {
+	resolver         *net.Resolver
+	timeout          time.Duration
+	cacheTTL         time.Duration
+	negativeCacheTTL time.Duration
+
+	// slots bounds the number of concurrent lookups
+	slots chan struct{}
+
+	cache map[string]*domainCacheEntry
+	mu    sync.Mutex
+}
+
+// domainCacheEntry holds the status of one domain; ready is closed once the
+// lookup that fills it has finished
+type domainCacheEntry struct {
+	status  DomainStatus
+	expires time.Time
+	ready   chan struct{}
+}
+
+// NewDomainChecker creates a domain checker, filling in defaults for unset options
+func NewDomainChecker(opts DomainCheckerOptions) *DomainChecker {
+	if opts.Timeout <= 0 {
+		opts.Timeout = defaultDNSTimeout
+	}
+	if opts.CacheTTL <= 0 {
+		opts.CacheTTL = defaultDNSCacheTTL
+	}
+	if opts.NegativeCacheTTL <= 0 {
+		opts.NegativeCacheTTL = defaultDNSNegativeCacheTTL
+	}
+	if opts.MaxConcurrent < 1 {
+		opts.MaxConcurrent = defaultDNSMaxConcurrent
+	}
+
+	return &DomainChecker{
+		resolver:         newResolver(opts.Resolver),
+		timeout:          opts.Timeout,
+		cacheTTL:         opts.CacheTTL,
+		negativeCacheTTL: opts.NegativeCacheTTL,
+		slots:            make(chan struct{}, opts.MaxConcurrent),
+		cache:            make(map[string]*domainCacheEntry),
+	}
+}
+
+// Check reports whether domain can receive mail. Concurrent checks of the
+// same domain share a single lookup.
+func (dc *DomainChecker) Check(ctx context.Context, domain string) DomainStatus {
+	domain = strings.ToLower(strings.TrimSuffix(domain, "."))
+
+	dc.mu.Lock()
+	entry, exists := dc.cache[domain]
+	if exists {
+		select {
+		case <-entry.ready:
+			if time.Now().Before(entry.expires) {
+				dc.mu.Unlock()
+				return entry.status
+			}
+			exists = false
+		default:
+		}
+	}
+	if !exists {
+		entry = &domainCacheEntry{ready: make(chan struct{})}
+		dc.pruneLocked()
+		dc.cache[domain] = entry
+		dc.mu.Unlock()
+
+		dc.fill(ctx, domain, entry)
+		return entry.status
+	}
+	dc.mu.Unlock()
+
+	// Another caller is looking the domain up
+	select {
+	case <-entry.ready:
+		return entry.status
+	case <-ctx.Done():
+		return DomainUnknown
+	}
+}
+
+// fill looks domain up and publishes the result in entry
+func (dc *DomainChecker) fill(ctx context.Context, domain string, entry *domainCacheEntry) {
+	status := dc.lookup(ctx, domain)
+
+	dc.mu.Lock()
+	defer dc.mu.Unlock()
+
+	entry.status = status
+	switch status {
+	case DomainMX, DomainAddress:
+		entry.expires = time.Now().Add(dc.cacheTTL)
+	case DomainNone:
+		entry.expires = time.Now().Add(dc.negativeCacheTTL)
+	default:
+		// Failed lookups are retried by the next caller
+		if dc.cache[domain] == entry {
+			delete(dc.cache, domain)
+		}
+	}
+	close(entry.ready)
+}
+
+// lookup resolves domain's MX records, falling back to A/AAAA records
+func (dc *DomainChecker) lookup(ctx context.Context, domain string) DomainStatus {
+	// Wait for a free lookup slot
+	select {
+	case dc.slots <- struct{}{}:
+		defer func() { <-dc.slots }()
+	case <-ctx.Done():
+		return DomainUnknown
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, dc.timeout)
+	defer cancel()
+
+	records, err := dc.resolver.LookupMX(ctx, domain)
+	if err == nil && len(records) > 0 {
+		// A single "." record is a null MX: the domain accepts no mail
+		if len(records) == 1 && (records[0].Host == "." || records[0].Host == "") {
+			return DomainNone
+		}
+		return DomainMX
+	}
+	if err != nil && !isNotFound(err) {
+		return DomainUnknown
+	}
+
+	addresses, err := dc.resolver.LookupIPAddr(ctx, domain)
+	switch {
+	case err == nil && len(addresses) > 0:
+		return DomainAddress
+	case err == nil || isNotFound(err):
+		return DomainNone
+	default:
+		return DomainUnknown
+	}
+}
+
+// pruneLocked removes expired entries once the cache is large; dc.mu must be held
+func (dc *DomainChecker) pruneLocked() {
+	if len(dc.cache) < maxDNSCacheEntries {
+		return
+	}
+
+	now := time.Now()
+	for domain, entry := range dc.cache {
+		select {
+		case <-entry.ready:
+			if !now.Before(entry.expires) {
+				delete(dc.cache, domain)
+			}
+		default:
+		}
+	}
+}
+
+// isNotFound reports whether err means the name has no records of the requested type
+func isNotFound(err error) bool {
+	var dnsErr *net.DNSError
+	return errors.As(err, &dnsErr) && dnsErr.IsNotFound
+}
+
+// newResolver returns a resolver that queries address, or the system resolver when address is empty
+func newResolver(address string) *net.Resolver {
+	if address == "" {
+		return net.DefaultResolver
+	}
+	return &net.Resolver{
+		PreferGo: true,
+		Dial: func(ctx context.Context, network, _ string) (net.Conn, error) {
+			var dialer net.Dialer
+			return dialer.DialContext(ctx, network, address)
+		},
+	}
+}
@@ -0,0 +1,302 @@
+package main
+
+import (
+	"context"
+	"net"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"golang.org/x/net/dns/dnsmessage"
+)
+
+// stubZone holds the records served for one name by the stub DNS server
+type stubZone struct {
+	mx []string
+	a  []string
+
+	// silent zones never get an answer, simulating a timeout
+	silent bool
+}
+
+// stubDNSServer is a minimal UDP DNS server answering from a fixed set of zones
+type stubDNSServer struct {
+	conn      net.PacketConn
+	zones     map[string]stubZone
+	mxQueries atomic.Int64
+}
+
+// newStubDNSServer starts a stub DNS server on a local port until the test ends
+func newStubDNSServer(t *testing.T, zones map[string]stubZone) *stubDNSServer {
+	t.Helper()
+
+	conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Failed to listen: %v", err)
+	}
+	server := &stubDNSServer{conn: conn, zones: zones}
+	t.Cleanup(func() { conn.Close() })
+
+	go server.serve()
+	return server
+}
+
+// Addr returns the host:port the server listens on
+func (s *stubDNSServer) Addr() string {
+	return s.conn.LocalAddr().String()
+}
+
+func (s *stubDNSServer) serve() {
+	buf := make([]byte, 1500)
+	for {
+		n, addr, err := s.conn.ReadFrom(buf)
+		if err != nil {
+			return
+		}
+		if response := s.answer(buf[:n]); response != nil {
+			s.conn.WriteTo(response, addr)
+		}
+	}
+}
+
+// answer builds the response to a single query, or nil to stay silent
+func (s *stubDNSServer) answer(query []byte) []byte {
+	var parser dnsmessage.Parser
+	header, err := parser.Start(query)
+	if err != nil {
+		return nil
+	}
+	question, err := parser.Question()
+	if err != nil {
+		return nil
+	}
+	if question.Type == dnsmessage.TypeMX {
+		s.mxQueries.Add(1)
+	}
+
+	name := strings.ToLower(strings.TrimSuffix(question.Name.String(), "."))
+	zone, exists := s.zones[name]
+	if zone.silent {
+		return nil
+	}
+
+	responseHeader := dnsmessage.Header{
+		ID:                 header.ID,
+		Response:           true,
+		Authoritative:      true,
+		RecursionAvailable: true,
+	}
+	if !exists {
+		responseHeader.RCode = dnsmessage.RCodeNameError
+	}
+
+	builder := dnsmessage.NewBuilder(nil, responseHeader)
+	builder.EnableCompression()
+	builder.StartQuestions()
+	builder.Question(question)
+	builder.StartAnswers()
+
+	resource := dnsmessage.ResourceHeader{Name: question.Name, Class: dnsmessage.ClassINET, TTL: 300}
+	switch question.Type {
+	case dnsmessage.TypeMX:
+		for i, host := range zone.mx {
+			builder.MXResource(resource, dnsmessage.MXResource{
+				Pref: uint16(10 * (i + 1)),
+				MX:   dnsmessage.MustNewName(strings.TrimSuffix(host, ".") + "."),
+			})
+		}
+	case dnsmessage.TypeA:
+		for _, address := range zone.a {
+			var a dnsmessage.AResource
+			copy(a.A[:], net.ParseIP(address).To4())
+			builder.AResource(resource, a)
+		}
+	}
+
+	response, err := builder.Finish()
+	if err != nil {
+		return nil
+	}
+	return response
+}
+
+// testZones is the set of domains used by the DNS tests
+var testZones = map[string]stubZone{
+	"mx.example":         {mx: []string{"mail.mx.example"}},
+	"address.example":    {a: []string{"192.0.2.1"}},
+	"nomail.example":     {},
+	"nullmx.example":     {mx: []string{"."}, a: []string{"192.0.2.3"}},
+	"slow.example":       {silent: true},
+	"mail.mx.example":    {a: []string{"192.0.2.2"}},
+	"concurrent.example": {mx: []string{"mail.mx.example"}},
+}
+
+func TestDomainCheckerCheck(t *testing.T) {
+	server := newStubDNSServer(t, testZones)
+	checker := NewDomainChecker(DomainCheckerOptions{
+		Resolver: server.Addr(),
+		Timeout:  500 * time.Millisecond,
+	})
+
+	tests := []struct {
+		domain   string
+		expected DomainStatus
+	}{
+		{"mx.example", DomainMX},
+		{"MX.Example.", DomainMX},
+		{"address.example", DomainAddress},
+		{"nomail.example", DomainNone},
+		{"nullmx.example", DomainNone},
+		{"missing.example", DomainNone},
+		{"slow.example", DomainUnknown},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.domain, func(t *testing.T) {
+			status := checker.Check(context.Background(), tt.domain)
+			if status != tt.expected {
+				t.Errorf("Status mismatch. Expected: %s, Got: %s", tt.expected, status)
+			}
+		})
+	}
+}
+
+func TestDomainCheckerCache(t *testing.T) {
+	server := newStubDNSServer(t, testZones)
+	checker := NewDomainChecker(DomainCheckerOptions{
+		Resolver:         server.Addr(),
+		CacheTTL:         time.Hour,
+		NegativeCacheTTL: time.Hour,
+	})
+
+	checker.Check(context.Background(), "mx.example")
+	checker.Check(context.Background(), "missing.example")
+	queries := server.mxQueries.Load()
+
+	// Repeated checks are answered from the cache
+	for i := 0; i < 5; i++ {
+		checker.Check(context.Background(), "mx.example")
+		checker.Check(context.Background(), "missing.example")
+	}
+	if got := server.mxQueries.Load(); got != queries {
+		t.Errorf("Expected cached results, but MX queries went from %d to %d", queries, got)
+	}
+
+	// Expired entries are looked up again
+	checker.mu.Lock()
+	checker.cache["mx.example"].expires = time.Now().Add(-time.Second)
+	checker.mu.Unlock()
+
+	checker.Check(context.Background(), "mx.example")
+	if got := server.mxQueries.Load(); got <= queries {
+		t.Error("Expected expired entry to be looked up again")
+	}
+}
+
+func TestDomainCheckerUnknownNotCached(t *testing.T) {
+	server := newStubDNSServer(t, testZones)
+	checker := NewDomainChecker(DomainCheckerOptions{
+		Resolver: server.Addr(),
+		Timeout:  100 * time.Millisecond,
+	})
+
+	if status := checker.Check(context.Background(), "slow.example"); status != DomainUnknown {
+		t.Fatalf("Expected %s, got %s", DomainUnknown, status)
+	}
+
+	checker.mu.Lock()
+	_, cached := checker.cache["slow.example"]
+	checker.mu.Unlock()
+	if cached {
+		t.Error("Failed lookups should not be cached")
+	}
+}
+
+func TestDomainCheckerConcurrentLookups(t *testing.T) {
+	server := newStubDNSServer(t, testZones)
+	checker := NewDomainChecker(DomainCheckerOptions{
+		Resolver:      server.Addr(),
+		MaxConcurrent: 2,
+	})
+
+	// Concurrent checks of one domain share a single lookup
+	var wg sync.WaitGroup
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if status := checker.Check(context.Background(), "concurrent.example"); status != DomainMX {
+				t.Errorf("Expected %s, got %s", DomainMX, status)
+			}
+		}()
+	}
+	wg.Wait()
+
+	if got := server.mxQueries.Load(); got != 1 {
+		t.Errorf("Expected 1 MX query, got %d", got)
+	}
+}
+
+func TestDomainCheckerCancelled(t *testing.T) {
+	server := newStubDNSServer(t, testZones)
+	checker := NewDomainChecker(DomainCheckerOptions{
+		Resolver: server.Addr(),
+		Timeout:  10 * time.Second,
+	})
+
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+
+	start := time.Now()
+	if status := checker.Check(ctx, "slow.example"); status != DomainUnknown {
+		t.Errorf("Expected %s, got %s", DomainUnknown, status)
+	}
+	if elapsed := time.Since(start); elapsed > 5*time.Second {
+		t.Errorf("Check ignored cancellation and took %v", elapsed)
+	}
+}
+
+func TestEmailValidatorDomainCheck(t *testing.T) {
+	server := newStubDNSServer(t, testZones)
+	validator, err := NewEmailValidatorWithOptions(EmailValidatorOptions{
+		Profile: ProfileStrict,
+		DomainChecker: NewDomainChecker(DomainCheckerOptions{
+			Resolver: server.Addr(),
+			Timeout:  100 * time.Millisecond,
+		}),
+	})
+	if err != nil {
+		t.Fatalf("NewEmailValidatorWithOptions failed: %v", err)
+	}
+
+	tests := []struct {
+		email          string
+		expectedValid  bool
+		expectedReason ValidationReason
+		expectedDomain DomainStatus
+	}{
+		{"user@mx.example", true, "", DomainMX},
+		{"user@address.example", true, "", DomainAddress},
+		{"user@missing.example", false, ReasonNoMailServer, DomainNone},
+		{"user@slow.example", true, "", DomainUnknown},
+		{"user@[192.0.2.1]", true, "", ""},
+		{"not-an-email", false, ReasonMissingAt, ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.email, func(t *testing.T) {
+			result := validator.Validate(tt.email)
+			if result.Valid != tt.expectedValid {
+				t.Errorf("Valid mismatch. Expected: %t, Got: %t", tt.expectedValid, result.Valid)
+			}
+			if result.Reason != tt.expectedReason {
+				t.Errorf("Reason mismatch. Expected: %s, Got: %s", tt.expectedReason, result.Reason)
+			}
+			if result.Domain != tt.expectedDomain {
+				t.Errorf("Domain status mismatch. Expected: %s, Got: %s", tt.expectedDomain, result.Domain)
+			}
+		})
+	}
+}
@@ -0,0 +1,246 @@
+package main
+
+import (
+	"bufio"
+	_ "embed"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"golang.org/x/net/idna"
+)
+
+// defaultDomainListReloadInterval is how often list files are checked for changes
+const defaultDomainListReloadInterval = time.Minute
+
+//go:embed lists/disposable_domains.txt
+var defaultDisposableDomains string
+
+//go:embed lists/free_providers.txt
+var defaultFreeProviders string
+
+// reloadableSet is a set of names made of embedded defaults plus the names
+// in an optional file, which is re-read when it changes
+type reloadableSet struct {
+	defaults  map[string]struct{}
+	path      string
+	interval  time.Duration
+	normalize func(string) (string, error)
+
+	// names is replaced, never modified, so a snapshot may be read without the lock
+	names   map[string]struct{}
+	modTime time.Time
+	mu      sync.RWMutex
+
+	// lastCheck is when the file was last checked for changes, in Unix nanoseconds
+	lastCheck atomic.Int64
+	reloading atomic.Bool
+}
+
+// newReloadableSet creates a set of the names in defaults, one per line,
+// plus those in the file at path when it is not empty. Each name is passed
+// through normalize. The file is checked for changes at most once per
+// interval while the set is in use.
+func newReloadableSet(defaults, path string, interval time.Duration, normalize func(string) (string, error)) (*reloadableSet, error) {
+	names, err := parseList(strings.NewReader(defaults), normalize)
+	if err != nil {
+		return nil, fmt.Errorf("invalid default list: %w", err)
+	}
+	if interval <= 0 {
+		interval = defaultDomainListReloadInterval
+	}
+
+	set := &reloadableSet{
+		defaults:  names,
+		path:      path,
+		interval:  interval,
+		normalize: normalize,
+		names:     names,
+	}
+	if path != "" {
+		if _, err := set.Reload(); err != nil {
+			return nil, err
+		}
+	}
+	return set, nil
+}
+
+// snapshot returns the current names, reloading the file first if it is due
+func (set *reloadableSet) snapshot() map[string]struct{} {
+	set.maybeReload()
+
+	set.mu.RLock()
+	defer set.mu.RUnlock()
+	return set.names
+}
+
+// Len returns the number of names in the set
+func (set *reloadableSet) Len() int {
+	set.mu.RLock()
+	defer set.mu.RUnlock()
+	return len(set.names)
+}
+
+// Reload re-reads the list file if it changed since it was last read,
+// reporting whether the set was replaced. On error the current set is kept.
+func (set *reloadableSet) Reload() (bool, error) {
+	if set.path == "" {
+		return false, nil
+	}
+	set.lastCheck.Store(time.Now().UnixNano())
+
+	info, err := os.Stat(set.path)
+	if err != nil {
+		return false, fmt.Errorf("failed to read list: %w", err)
+	}
+
+	set.mu.RLock()
+	unchanged := info.ModTime().Equal(set.modTime)
+	set.mu.RUnlock()
+	if unchanged {
+		return false, nil
+	}
+
+	file, err := os.Open(set.path)
+	if err != nil {
+		return false, fmt.Errorf("failed to read list: %w", err)
+	}
+	defer file.Close()
+
+	loaded, err := parseList(file, set.normalize)
+	if err != nil {
+		return false, fmt.Errorf("invalid list %s: %w", set.path, err)
+	}
+
+	// File entries add to the embedded defaults
+	names := make(map[string]struct{}, len(set.defaults)+len(loaded))
+	for name := range set.defaults {
+		names[name] = struct{}{}
+	}
+	for name := range loaded {
+		names[name] = struct{}{}
+	}
+
+	set.mu.Lock()
+	set.names = names
+	set.modTime = info.ModTime()
+	set.mu.Unlock()
+	return true, nil
+}
+
+// maybeReload reloads the list file once the reload interval has passed.
+// Only one caller reloads at a time; the others keep using the current set.
+func (set *reloadableSet) maybeReload() {
+	if set.path == "" || time.Since(time.Unix(0, set.lastCheck.Load())) < set.interval {
+		return
+	}
+	if !set.reloading.CompareAndSwap(false, true) {
+		return
+	}
+	defer set.reloading.Store(false)
+
+	changed, err := set.Reload()
+	switch {
+	case err != nil:
+		log.Printf("Keeping previous list: %v", err)
+	case changed:
+		log.Printf("Reloaded list %s (%d entries)", set.path, set.Len())
+	}
+}
+
+// parseList reads one name per line, ignoring blank lines and comments
+// starting with '#'
+func parseList(r io.Reader, normalize func(string) (string, error)) (map[string]struct{}, error) {
+	names := make(map[string]struct{})
+
+	scanner := bufio.NewScanner(r)
+	for line := 1; scanner.Scan(); line++ {
+		text := scanner.Text()
+		if hash := strings.IndexByte(text, '#'); hash >= 0 {
+			text = text[:hash]
+		}
+		text = strings.TrimSpace(text)
+		if text == "" {
+			continue
+		}
+
+		name, err := normalize(text)
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %w", line, err)
+		}
+		names[name] = struct{}{}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return names, nil
+}
+
+// DomainList is a set of email domains, such as disposable or free-mail
+// providers. It holds an embedded default list plus the domains in an
+// optional file, which is re-read when it changes.
+type DomainList struct {
+	*reloadableSet
+}
+
+// NewDomainList creates a list of the domains in defaults, one per line,
+// plus those in the file at path when it is not empty. The file is checked
+// for changes at most once per interval while the list is in use.
+func NewDomainList(defaults, path string, interval time.Duration) (*DomainList, error) {
+	set, err := newReloadableSet(defaults, path, interval, normalizeListDomain)
+	if err != nil {
+		return nil, err
+	}
+	return &DomainList{set}, nil
+}
+
+var (
+	// defaultDisposableList and defaultFreeProviderList are the embedded
+	// lists, shared by validators that are not given their own
+	defaultDisposableList = sync.OnceValue(func() *DomainList {
+		return mustDomainList(defaultDisposableDomains)
+	})
+	defaultFreeProviderList = sync.OnceValue(func() *DomainList {
+		return mustDomainList(defaultFreeProviders)
+	})
+)
+
+// mustDomainList creates a list from embedded defaults, which are known to be valid
+func mustDomainList(defaults string) *DomainList {
+	dl, err := NewDomainList(defaults, "", 0)
+	if err != nil {
+		panic(err)
+	}
+	return dl
+}
+
+// Contains reports whether domain, or a domain it is a subdomain of, is in the list
+func (dl *DomainList) Contains(domain string) bool {
+	domains := dl.snapshot()
+
+	domain = strings.ToLower(strings.TrimSuffix(domain, "."))
+	for {
+		if _, exists := domains[domain]; exists {
+			return true
+		}
+		dot := strings.IndexByte(domain, '.')
+		if dot < 0 {
+			return false
+		}
+		domain = domain[dot+1:]
+	}
+}
+
+// normalizeListDomain converts a listed domain to lower-case ASCII form
+func normalizeListDomain(text string) (string, error) {
+	domain, err := idna.Lookup.ToASCII(strings.TrimSuffix(text, "."))
+	if err != nil || !strings.Contains(domain, ".") {
+		return "", fmt.Errorf("invalid domain %q", text)
+	}
+	return strings.ToLower(domain), nil
+}
@@ -0,0 +1,128 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestDomainListContains(t *testing.T) {
+	list, err := NewDomainList("# test list\nmailinator.com\nExample.ORG.\n例子.广告  # unicode\n", "", 0)
+	if err != nil {
+		t.Fatalf("NewDomainList failed: %v", err)
+	}
+
+	tests := []struct {
+		domain   string
+		expected bool
+	}{
+		{"mailinator.com", true},
+		{"MAILINATOR.COM.", true},
+		{"inbox.mailinator.com", true},
+		{"notmailinator.com", false},
+		{"com", false},
+		{"example.org", true},
+		{"xn--fsqu00a.xn--4rr70v", true},
+		{"gmail.com", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.domain, func(t *testing.T) {
+			if got := list.Contains(tt.domain); got != tt.expected {
+				t.Errorf("Contains mismatch. Expected: %t, Got: %t", tt.expected, got)
+			}
+		})
+	}
+}
+
+func TestDefaultDomainLists(t *testing.T) {
+	if !defaultDisposableList().Contains("mailinator.com") {
+		t.Error("Expected mailinator.com in the default disposable list")
+	}
+	if !defaultFreeProviderList().Contains("gmail.com") {
+		t.Error("Expected gmail.com in the default free provider list")
+	}
+	if defaultDisposableList().Contains("gmail.com") {
+		t.Error("Did not expect gmail.com in the default disposable list")
+	}
+}
+
+func TestParseListErrors(t *testing.T) {
+	tests := []struct {
+		name string
+		list string
+	}{
+		{"Single label", "localhost\n"},
+		{"Invalid characters", "bad_domain!.com\n"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := parseList(strings.NewReader(tt.list), normalizeListDomain); err == nil {
+				t.Error("Expected error")
+			}
+		})
+	}
+}
+
+func TestDomainListFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "disposable.txt")
+	if err := os.WriteFile(path, []byte("throwaway.example\n"), 0644); err != nil {
+		t.Fatalf("Failed to write list: %v", err)
+	}
+
+	list, err := NewDomainList("mailinator.com\n", path, time.Hour)
+	if err != nil {
+		t.Fatalf("NewDomainList failed: %v", err)
+	}
+
+	// File entries add to the defaults
+	if !list.Contains("mailinator.com") || !list.Contains("throwaway.example") {
+		t.Error("Expected both default and file domains")
+	}
+	if list.Len() != 2 {
+		t.Errorf("Len mismatch. Expected: 2, Got: %d", list.Len())
+	}
+
+	// A missing file is an error at startup
+	if _, err := NewDomainList("", filepath.Join(t.TempDir(), "missing.txt"), time.Hour); err == nil {
+		t.Error("Expected error for missing list file")
+	}
+}
+
+func TestDomainListHotReload(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "disposable.txt")
+	if err := os.WriteFile(path, []byte("first.example\n"), 0644); err != nil {
+		t.Fatalf("Failed to write list: %v", err)
+	}
+
+	list, err := NewDomainList("", path, time.Millisecond)
+	if err != nil {
+		t.Fatalf("NewDomainList failed: %v", err)
+	}
+
+	// rewrite replaces the file and moves its modification time forward
+	modTime := time.Now()
+	rewrite := func(content string) {
+		t.Helper()
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatalf("Failed to write list: %v", err)
+		}
+		modTime = modTime.Add(time.Minute)
+		os.Chtimes(path, modTime, modTime)
+		time.Sleep(5 * time.Millisecond)
+	}
+
+	rewrite("second.example\n")
+	if list.Contains("first.example") || !list.Contains("second.example") {
+		t.Error("Expected the list to be reloaded after the file changed")
+	}
+
+	// An invalid file keeps the previous list
+	rewrite("not a domain\n")
+	if !list.Contains("second.example") {
+		t.Error("Expected the previous list to be kept after an invalid reload")
+	}
+}
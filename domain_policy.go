@@ -0,0 +1,149 @@
+package main
+
+import (
+	"fmt"
+	"path"
+	"strings"
+
+	"golang.org/x/net/idna"
+)
+
+// policyNotAllowed is the violation recorded for domains missing from an allow-list
+const policyNotAllowed = "not_allowed"
+
+// DomainPolicy decides which email domains are acceptable for an upload.
+// Patterns are matched against the lower-case ASCII domain and may be
+//
+//   - exact, such as "example.com"
+//   - a suffix, such as ".example.com", matching example.com and its subdomains
+//   - a wildcard, such as "*.example.*", where '*' matches any run of characters
+type DomainPolicy struct {
+	// allow holds allow-lists that a domain must each match, so merged
+	// policies only accept domains that all of them accept
+	allow [][]string
+	deny  []string
+}
+
+// DomainPolicyConfig is a named policy in the config file
+type DomainPolicyConfig struct {
+	Allow []string `json:"allow"`
+	Deny  []string `json:"deny"`
+}
+
+// NewDomainPolicy creates a policy that rejects domains matching a deny
+// pattern and, when allow is not empty, domains matching no allow pattern
+func NewDomainPolicy(allow, deny []string) (*DomainPolicy, error) {
+	policy := &DomainPolicy{}
+	var allowList []string
+	for _, list := range []struct {
+		patterns []string
+		target   *[]string
+	}{{allow, &allowList}, {deny, &policy.deny}} {
+		for _, pattern := range list.patterns {
+			normalized, err := normalizeDomainPattern(pattern)
+			if err != nil {
+				return nil, err
+			}
+			if normalized != "" {
+				*list.target = append(*list.target, normalized)
+			}
+		}
+	}
+	if len(allowList) > 0 {
+		policy.allow = [][]string{allowList}
+	}
+	return policy, nil
+}
+
+// Merge returns a policy that rejects every domain either policy rejects:
+// deny patterns add up, and a domain must match the allow-lists of both.
+// Either policy may be nil.
+func (dp *DomainPolicy) Merge(other *DomainPolicy) *DomainPolicy {
+	if dp == nil {
+		return other
+	}
+	if other == nil {
+		return dp
+	}
+	return &DomainPolicy{
+		allow: append(append([][]string(nil), dp.allow...), other.allow...),
+		deny:  append(append([]string(nil), dp.deny...), other.deny...),
+	}
+}
+
+// Check returns why domain violates the policy: "denied:<pattern>" for a
+// deny match, "not_allowed" when nothing on one of the allow-lists
+// matches, or an empty string when domain is acceptable. A nil policy
+// accepts every domain.
+func (dp *DomainPolicy) Check(domain string) string {
+	if dp == nil {
+		return ""
+	}
+	domain = strings.ToLower(strings.TrimSuffix(domain, "."))
+
+	for _, pattern := range dp.deny {
+		if matchDomainPattern(pattern, domain) {
+			return "denied:" + pattern
+		}
+	}
+	for _, list := range dp.allow {
+		if !matchesAny(list, domain) {
+			return policyNotAllowed
+		}
+	}
+	return ""
+}
+
+// matchesAny reports whether domain matches one of patterns
+func matchesAny(patterns []string, domain string) bool {
+	for _, pattern := range patterns {
+		if matchDomainPattern(pattern, domain) {
+			return true
+		}
+	}
+	return false
+}
+
+// matchDomainPattern reports whether domain matches a normalized pattern
+func matchDomainPattern(pattern, domain string) bool {
+	switch {
+	case strings.ContainsAny(pattern, "*?["):
+		matched, _ := path.Match(pattern, domain)
+		return matched
+	case strings.HasPrefix(pattern, "."):
+		return domain == pattern[1:] || strings.HasSuffix(domain, pattern)
+	default:
+		return domain == pattern
+	}
+}
+
+// normalizeDomainPattern lower-cases a pattern and converts its Unicode
+// labels to punycode, rejecting malformed patterns
+func normalizeDomainPattern(pattern string) (string, error) {
+	pattern = strings.ToLower(strings.TrimSpace(pattern))
+	if pattern == "" {
+		return "", nil
+	}
+
+	labels := strings.Split(pattern, ".")
+	for i, label := range labels {
+		// The leading empty label of a suffix pattern and wildcard labels are kept
+		if label == "" && i == 0 && len(labels) > 1 {
+			continue
+		}
+		if strings.ContainsAny(label, "*?[") {
+			continue
+		}
+		ascii, err := idna.Lookup.ToASCII(label)
+		if err != nil || ascii == "" {
+			return "", fmt.Errorf("invalid domain pattern %q", pattern)
+		}
+		labels[i] = ascii
+	}
+	pattern = strings.Join(labels, ".")
+
+	if _, err := path.Match(pattern, ""); err != nil {
+		return "", fmt.Errorf("invalid domain pattern %q: %w", pattern, err)
+	}
+	return pattern, nil
+}
@@ -0,0 +1,104 @@
+package main
+
+import "testing"
+
+func TestDomainPolicyCheck(t *testing.T) {
+	tests := []struct {
+		name     string
+		allow    []string
+		deny     []string
+		domain   string
+		expected string
+	}{
+		{"No patterns", nil, nil, "example.com", ""},
+		{"Exact allow", []string{"acme.com"}, nil, "acme.com", ""},
+		{"Exact allow is not a suffix", []string{"acme.com"}, nil, "mail.acme.com", policyNotAllowed},
+		{"Not on allow-list", []string{"acme.com"}, nil, "example.com", policyNotAllowed},
+		{"Suffix matches domain", []string{".acme.com"}, nil, "acme.com", ""},
+		{"Suffix matches subdomain", []string{".acme.com"}, nil, "eu.mail.acme.com", ""},
+		{"Suffix needs a label boundary", []string{".acme.com"}, nil, "notacme.com", policyNotAllowed},
+		{"Wildcard", []string{"acme.*"}, nil, "acme.co.uk", ""},
+		{"Exact deny", nil, []string{"rival.com"}, "Rival.COM", "denied:rival.com"},
+		{"Wildcard deny", nil, []string{"*.rival.*"}, "mail.rival.io", "denied:*.rival.*"},
+		{"Deny wins over allow", []string{".acme.com"}, []string{"contractors.acme.com"}, "contractors.acme.com", "denied:contractors.acme.com"},
+		{"Unicode pattern", []string{"bücher.de"}, nil, "xn--bcher-kva.de", ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			policy, err := NewDomainPolicy(tt.allow, tt.deny)
+			if err != nil {
+				t.Fatalf("NewDomainPolicy failed: %v", err)
+			}
+			if got := policy.Check(tt.domain); got != tt.expected {
+				t.Errorf("Violation mismatch. Expected: %q, Got: %q", tt.expected, got)
+			}
+		})
+	}
+}
+
+func TestDomainPolicyNil(t *testing.T) {
+	var policy *DomainPolicy
+	if got := policy.Check("example.com"); got != "" {
+		t.Errorf("Expected nil policy to accept everything, got %q", got)
+	}
+}
+
+func TestDomainPolicyMerge(t *testing.T) {
+	named, _ := NewDomainPolicy([]string{".acme.com"}, nil)
+	inline, _ := NewDomainPolicy(nil, []string{"old.acme.com"})
+	merged := named.Merge(inline)
+
+	if got := merged.Check("new.acme.com"); got != "" {
+		t.Errorf("Expected new.acme.com to be allowed, got %q", got)
+	}
+	if got := merged.Check("old.acme.com"); got != "denied:old.acme.com" {
+		t.Errorf("Expected old.acme.com to be denied, got %q", got)
+	}
+	if named.Check("old.acme.com") != "" {
+		t.Error("Merge should not modify the named policy")
+	}
+}
+
+func TestDomainPolicyMergeAllowLists(t *testing.T) {
+	named, _ := NewDomainPolicy([]string{".acme.com"}, nil)
+	inline, _ := NewDomainPolicy([]string{"gmail.com", "eu.acme.com"}, nil)
+	merged := named.Merge(inline)
+
+	// An inline allow-list narrows the named one instead of widening it
+	tests := []struct {
+		domain   string
+		expected string
+	}{
+		{"eu.acme.com", ""},
+		{"us.acme.com", policyNotAllowed},
+		{"gmail.com", policyNotAllowed},
+		{"rival.com", policyNotAllowed},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.domain, func(t *testing.T) {
+			if got := merged.Check(tt.domain); got != tt.expected {
+				t.Errorf("Check mismatch. Expected: %q, Got: %q", tt.expected, got)
+			}
+		})
+	}
+}
+
+func TestNewDomainPolicyErrors(t *testing.T) {
+	tests := []struct {
+		name    string
+		pattern string
+	}{
+		{"Unclosed class", "acme.[com"},
+		{"Invalid label", "bad_label!.com"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := NewDomainPolicy([]string{tt.pattern}, nil); err == nil {
+				t.Error("Expected error")
+			}
+		})
+	}
+}
@@ -0,0 +1,135 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// ExtractMode selects whether addresses embedded in free text are extracted
+type ExtractMode string
+
+const (
+	// ExtractOff only accepts fields that are exactly an address
+	ExtractOff ExtractMode = ""
+
+	// ExtractFirst outputs the first address found
+	ExtractFirst ExtractMode = "first"
+
+	// ExtractAll outputs every address found, joined by extractedSeparator
+	ExtractAll ExtractMode = "all"
+)
+
+// extractedSeparator joins addresses in the extracted_emails column
+const extractedSeparator = ";"
+
+// ParseExtractMode parses an extract mode name; empty, "none" and "false" turn extraction off
+func ParseExtractMode(name string) (ExtractMode, error) {
+	switch mode := ExtractMode(strings.ToLower(strings.TrimSpace(name))); mode {
+	case ExtractOff, "none", "false":
+		return ExtractOff, nil
+	case ExtractFirst, ExtractAll:
+		return mode, nil
+	default:
+		return "", fmt.Errorf("unknown extract mode %q", name)
+	}
+}
+
+// ExtractedEmail is a valid address found inside a larger piece of text
+type ExtractedEmail struct {
+	Address string
+	Result  ValidationResult
+}
+
+// ExtractEmails finds the valid addresses inside text, such as those in
+// "John <john@example.com>", "mailto:a@example.com" or "a@example.com;
+// b@example.org". Addresses are returned in order of appearance, without
+// duplicates.
+func (ev *EmailValidator) ExtractEmails(ctx context.Context, text string) []ExtractedEmail {
+	var found []ExtractedEmail
+	seen := make(map[string]bool)
+
+	for _, candidate := range extractCandidates(text) {
+		result := ev.ValidateContext(ctx, candidate)
+		if !result.Valid {
+			continue
+		}
+		key := strings.ToLower(result.ASCII)
+		if seen[key] {
+			continue
+		}
+		seen[key] = true
+		found = append(found, ExtractedEmail{Address: candidate, Result: result})
+	}
+	return found
+}
+
+// extractCandidates returns the address-like substrings of text, found by
+// growing each '@' outwards over characters that may appear in an
+// unquoted address
+func extractCandidates(text string) []string {
+	var candidates []string
+	for at := strings.IndexByte(text, '@'); at >= 0; {
+		start := at
+		for start > 0 {
+			r, size := utf8.DecodeLastRuneInString(text[:start])
+			if !isExtractLocalRune(r) {
+				break
+			}
+			start -= size
+		}
+
+		end := at + 1
+		for end < len(text) {
+			r, size := utf8.DecodeRuneInString(text[end:])
+			if !isExtractDomainRune(r) {
+				break
+			}
+			end += size
+		}
+
+		// Sentence punctuation around an address is not part of it
+		candidate := strings.TrimLeft(text[start:at], ".")
+		candidate += "@" + strings.TrimRight(text[at+1:end], ".-")
+		if !strings.HasPrefix(candidate, "@") && !strings.HasSuffix(candidate, "@") {
+			candidates = append(candidates, candidate)
+		}
+
+		next := strings.IndexByte(text[at+1:], '@')
+		if next < 0 {
+			break
+		}
+		at += 1 + next
+	}
+	return candidates
+}
+
+// isExtractLocalRune reports whether r may be part of an extracted local part.
+// Rarer atext characters such as '=' and '/' are left out, as in free text
+// they more often separate an address from what precedes it.
+func isExtractLocalRune(r rune) bool {
+	return unicode.IsLetter(r) || unicode.IsDigit(r) || unicode.IsMark(r) || strings.ContainsRune("._%+-'", r)
+}
+
+// isExtractDomainRune reports whether r may be part of an extracted domain
+func isExtractDomainRune(r rune) bool {
+	return unicode.IsLetter(r) || unicode.IsDigit(r) || unicode.IsMark(r) || r == '.' || r == '-'
+}
+
+// formatExtracted formats extracted addresses for the extracted_emails column
+func formatExtracted(found []ExtractedEmail, mode ExtractMode) string {
+	if len(found) == 0 {
+		return ""
+	}
+	if mode == ExtractFirst {
+		return found[0].Address
+	}
+
+	addresses := make([]string, len(found))
+	for i, email := range found {
+		addresses[i] = email.Address
+	}
+	return strings.Join(addresses, extractedSeparator)
+}
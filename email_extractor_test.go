@@ -0,0 +1,73 @@
+package main
+
+import (
+	"context"
+	"strings"
+	"testing"
+)
+
+func TestExtractEmails(t *testing.T) {
+	validator := NewEmailValidator()
+
+	tests := []struct {
+		name     string
+		text     string
+		expected []string
+	}{
+		{"Plain address", "john@example.com", []string{"john@example.com"}},
+		{"Display name", "Contact: John <john@example.com>", []string{"john@example.com"}},
+		{"Mailto links", "mailto:a@example.com; c@example.org", []string{"a@example.com", "c@example.org"}},
+		{"Mailto query", "mailto:sales@example.com?subject=Hello", []string{"sales@example.com"}},
+		{"Comma list", "a@example.com, b@example.com,c@example.com", []string{"a@example.com", "b@example.com", "c@example.com"}},
+		{"Sentence", "Email jane.doe+cv@example.co.uk.", []string{"jane.doe+cv@example.co.uk"}},
+		{"Parentheses", "Bob (bob@example.com)", []string{"bob@example.com"}},
+		{"Duplicates", "a@example.com a@EXAMPLE.com", []string{"a@example.com"}},
+		{"Internationalized", "Écrire à josé@bücher.de", []string{"josé@bücher.de"}},
+		{"Invalid candidates", "user@localhost or @example.com or someone@", nil},
+		{"No addresses", "call 555-1234", nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got []string
+			for _, email := range validator.ExtractEmails(context.Background(), tt.text) {
+				got = append(got, email.Address)
+			}
+			if strings.Join(got, "|") != strings.Join(tt.expected, "|") {
+				t.Errorf("Extracted mismatch. Expected: %v, Got: %v", tt.expected, got)
+			}
+		})
+	}
+}
+
+func TestParseExtractMode(t *testing.T) {
+	tests := []struct {
+		input       string
+		expected    ExtractMode
+		expectError bool
+	}{
+		{"", ExtractOff, false},
+		{"false", ExtractOff, false},
+		{"first", ExtractFirst, false},
+		{" ALL ", ExtractAll, false},
+		{"some", "", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.input, func(t *testing.T) {
+			mode, err := ParseExtractMode(tt.input)
+			if tt.expectError {
+				if err == nil {
+					t.Error("Expected error, got nil")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("ParseExtractMode failed: %v", err)
+			}
+			if mode != tt.expected {
+				t.Errorf("Mode mismatch. Expected: %s, Got: %s", tt.expected, mode)
+			}
+		})
+	}
+}
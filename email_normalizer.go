@@ -0,0 +1,119 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+
+	"golang.org/x/text/unicode/norm"
+)
+
+// NormalizeMode selects how normalized addresses are written to the processed file
+type NormalizeMode string
+
+const (
+	// NormalizeOff leaves addresses as they are
+	NormalizeOff NormalizeMode = ""
+
+	// NormalizeColumn appends normalized_email, or <col>_normalized_email
+	// in OutputColumns mode
+	NormalizeColumn NormalizeMode = "column"
+
+	// NormalizeInPlace rewrites valid addresses in the target columns
+	NormalizeInPlace NormalizeMode = "in_place"
+)
+
+// ParseNormalizeMode parses a normalize mode name; empty, "none" and "false" turn normalization off
+func ParseNormalizeMode(name string) (NormalizeMode, error) {
+	switch mode := NormalizeMode(strings.ToLower(strings.TrimSpace(name))); mode {
+	case NormalizeOff, "none", "false":
+		return NormalizeOff, nil
+	case NormalizeColumn, NormalizeInPlace:
+		return mode, nil
+	default:
+		return "", fmt.Errorf("unknown normalize mode %q", name)
+	}
+}
+
+// providerRule describes how a mail provider treats equivalent local parts
+type providerRule struct {
+	// domain, if set, replaces the provider's alias domains
+	domain string
+
+	// foldCase means the provider ignores case in local parts
+	foldCase bool
+
+	// ignoreDots means the provider ignores dots in local parts
+	ignoreDots bool
+
+	// plusTags means everything from '+' on is a tag for the same mailbox
+	plusTags bool
+}
+
+// providerRules holds the equivalences documented by popular providers,
+// keyed by lower-case ASCII domain
+var providerRules = map[string]providerRule{
+	"gmail.com":      {foldCase: true, ignoreDots: true, plusTags: true},
+	"googlemail.com": {domain: "gmail.com", foldCase: true, ignoreDots: true, plusTags: true},
+	"outlook.com":    {foldCase: true, plusTags: true},
+	"hotmail.com":    {foldCase: true, plusTags: true},
+	"live.com":       {foldCase: true, plusTags: true},
+	"icloud.com":     {foldCase: true, plusTags: true},
+	"me.com":         {foldCase: true, plusTags: true},
+	"yahoo.com":      {foldCase: true},
+	"protonmail.com": {foldCase: true, plusTags: true},
+	"proton.me":      {foldCase: true, plusTags: true},
+	"fastmail.com":   {foldCase: true, plusTags: true},
+}
+
+// NormalizeOptions selects the optional normalization steps
+type NormalizeOptions struct {
+	// StripTags removes +tag subaddresses from every address, not just at
+	// providers known to deliver them to the same mailbox
+	StripTags bool
+}
+
+// EmailNormalizer turns valid addresses into a canonical form so that
+// different spellings of the same mailbox compare equal
+type EmailNormalizer struct {
+	validator *EmailValidator
+	opts      NormalizeOptions
+}
+
+// NewEmailNormalizer creates a normalizer that accepts the addresses validator does
+func NewEmailNormalizer(validator *EmailValidator, opts NormalizeOptions) *EmailNormalizer {
+	return &EmailNormalizer{validator: validator, opts: opts}
+}
+
+// Normalize returns the canonical form of email: trimmed, with the domain
+// in lower-case punycode and provider rules such as Gmail ignoring dots
+// applied. It returns an empty string when email is not well formed.
+func (en *EmailNormalizer) Normalize(email string) string {
+	address, reason := en.validator.parseAddress(strings.TrimSpace(email))
+	if reason != "" {
+		return ""
+	}
+
+	localPart, domain := norm.NFC.String(address.localPart), address.asciiDomain
+
+	// Quoted local parts are kept exactly as written
+	if strings.HasPrefix(localPart, `"`) {
+		return localPart + "@" + domain
+	}
+
+	rule := providerRules[domain]
+	if rule.domain != "" {
+		domain = rule.domain
+	}
+	if rule.plusTags || en.opts.StripTags {
+		if plus := strings.IndexByte(localPart, '+'); plus > 0 {
+			localPart = localPart[:plus]
+		}
+	}
+	if rule.ignoreDots {
+		localPart = strings.ReplaceAll(localPart, ".", "")
+	}
+	if rule.foldCase {
+		localPart = strings.ToLower(localPart)
+	}
+	return localPart + "@" + domain
+}
@@ -0,0 +1,70 @@
+package main
+
+import "testing"
+
+func TestEmailNormalizerNormalize(t *testing.T) {
+	validator, err := NewEmailValidatorWithProfile(ProfileStrict, "")
+	if err != nil {
+		t.Fatalf("NewEmailValidatorWithProfile failed: %v", err)
+	}
+
+	tests := []struct {
+		name      string
+		email     string
+		stripTags bool
+		expected  string
+	}{
+		{"Trims and lowercases domain", "  John.Smith@Example.COM ", false, "John.Smith@example.com"},
+		{"Keeps tags by default", "john+news@example.com", false, "john+news@example.com"},
+		{"Strips tags", "john+news@example.com", true, "john@example.com"},
+		{"Gmail dots, tags and case", "John.Smith+news@GMail.com", false, "johnsmith@gmail.com"},
+		{"Googlemail alias", "john.smith@googlemail.com", false, "johnsmith@gmail.com"},
+		{"Outlook tags and case", "John+work@Outlook.com", false, "john@outlook.com"},
+		{"Outlook keeps dots", "john.smith@outlook.com", false, "john.smith@outlook.com"},
+		{"Yahoo keeps plus", "John+x@yahoo.com", false, "john+x@yahoo.com"},
+		{"IDN domain", "josé@Bücher.de", false, "josé@xn--bcher-kva.de"},
+		{"Quoted local part", `"John+x"@example.com`, true, `"John+x"@example.com`},
+		{"Invalid address", "not-an-email", false, ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			normalizer := NewEmailNormalizer(validator, NormalizeOptions{StripTags: tt.stripTags})
+			if got := normalizer.Normalize(tt.email); got != tt.expected {
+				t.Errorf("Normalized mismatch. Expected: %s, Got: %s", tt.expected, got)
+			}
+		})
+	}
+}
+
+func TestParseNormalizeMode(t *testing.T) {
+	tests := []struct {
+		input       string
+		expected    NormalizeMode
+		expectError bool
+	}{
+		{"", NormalizeOff, false},
+		{"none", NormalizeOff, false},
+		{"column", NormalizeColumn, false},
+		{" In_Place ", NormalizeInPlace, false},
+		{"rewrite", "", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.input, func(t *testing.T) {
+			mode, err := ParseNormalizeMode(tt.input)
+			if tt.expectError {
+				if err == nil {
+					t.Error("Expected error, got nil")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("ParseNormalizeMode failed: %v", err)
+			}
+			if mode != tt.expected {
+				t.Errorf("Mode mismatch. Expected: %s, Got: %s", tt.expected, mode)
+			}
+		})
+	}
+}
@@ -0,0 +1,184 @@
+package main
+
+import (
+	_ "embed"
+	"strings"
+	"sync"
+)
+
+//go:embed lists/popular_domains.txt
+var defaultPopularDomains string
+
+//go:embed lists/popular_tlds.txt
+var defaultPopularTLDs string
+
+//go:embed lists/root_zone_tlds.txt
+var defaultRootZoneTLDs string
+
+// EmailSuggester proposes corrections for addresses whose domain looks like
+// a misspelling of a popular domain or top-level domain
+type EmailSuggester struct {
+	// domains and tlds are ordered by popularity, so ties go to the more popular one
+	domains   []string
+	domainSet map[string]struct{}
+	tlds      []string
+
+	// tldSet holds the popular and delegated TLDs, which are never corrected
+	tldSet map[string]struct{}
+}
+
+// NewEmailSuggester creates a suggester for the given domains and
+// top-level domains, most popular first. Addresses under a delegated
+// top-level domain keep it even when it is close to a popular one.
+func NewEmailSuggester(domains, tlds, delegated []string) *EmailSuggester {
+	es := &EmailSuggester{
+		domainSet: make(map[string]struct{}, len(domains)),
+		tldSet:    make(map[string]struct{}, len(tlds)+len(delegated)),
+	}
+	for _, domain := range domains {
+		domain = strings.ToLower(domain)
+		es.domains = append(es.domains, domain)
+		es.domainSet[domain] = struct{}{}
+	}
+	for _, tld := range tlds {
+		tld = strings.ToLower(strings.TrimPrefix(tld, "."))
+		es.tlds = append(es.tlds, tld)
+		es.tldSet[tld] = struct{}{}
+	}
+	for _, tld := range delegated {
+		es.tldSet[strings.ToLower(strings.TrimPrefix(tld, "."))] = struct{}{}
+	}
+	return es
+}
+
+// defaultEmailSuggester uses the embedded lists of popular domains and TLDs
+// and of the TLDs in the root zone
+var defaultEmailSuggester = sync.OnceValue(func() *EmailSuggester {
+	return NewEmailSuggester(listLines(defaultPopularDomains), listLines(defaultPopularTLDs), listLines(defaultRootZoneTLDs))
+})
+
+// listLines returns the entries of an embedded list, without blank lines and comments
+func listLines(list string) []string {
+	var entries []string
+	for _, line := range strings.Split(list, "\n") {
+		if hash := strings.IndexByte(line, '#'); hash >= 0 {
+			line = line[:hash]
+		}
+		if line = strings.TrimSpace(line); line != "" {
+			entries = append(entries, line)
+		}
+	}
+	return entries
+}
+
+// Suggest returns a corrected address for email, or an empty string when
+// its domain does not look like a typo
+func (es *EmailSuggester) Suggest(email string) string {
+	email = strings.TrimSpace(email)
+	at := strings.LastIndex(email, "@")
+	if at <= 0 || at == len(email)-1 {
+		return ""
+	}
+	localPart := email[:at]
+	domain := strings.ToLower(strings.TrimSuffix(email[at+1:], "."))
+
+	if suggestion := es.suggestDomain(domain); suggestion != "" && suggestion != domain {
+		return localPart + "@" + suggestion
+	}
+	return ""
+}
+
+// suggestDomain returns the domain that domain was most likely meant to be
+func (es *EmailSuggester) suggestDomain(domain string) string {
+	if _, exists := es.domainSet[domain]; exists {
+		return ""
+	}
+
+	// A close popular domain, such as gmial.com or yahoo.con
+	if closest := closestMatch(domain, es.domains, maxSuggestionDistance(domain)); closest != "" {
+		return closest
+	}
+
+	dot := strings.LastIndexByte(domain, '.')
+	if dot < 0 {
+		// A popular domain without its TLD, such as gmail; short names must match exactly
+		best, bestDistance := "", 2
+		if len(domain) < 5 {
+			bestDistance = 1
+		}
+		for _, candidate := range es.domains {
+			name, _, _ := strings.Cut(candidate, ".")
+			if distance := editDistance(domain, name); distance < bestDistance {
+				best, bestDistance = candidate, distance
+			}
+		}
+		return best
+	}
+
+	// A misspelt top-level domain, such as example.cmo; real ones such as
+	// .pt or .ie are left alone however close they are to a popular one
+	name, tld := domain[:dot], domain[dot+1:]
+	if _, exists := es.tldSet[tld]; exists || name == "" {
+		return ""
+	}
+	if closest := closestMatch(tld, es.tlds, 1); closest != "" {
+		return name + "." + closest
+	}
+	return ""
+}
+
+// maxSuggestionDistance is the number of edits tolerated for domain; short
+// domains allow fewer so distinct real domains such as mx.com are not
+// "corrected" to me.com
+func maxSuggestionDistance(domain string) int {
+	switch {
+	case len(domain) < 7:
+		return 0
+	case len(domain) < 10:
+		return 1
+	default:
+		return 2
+	}
+}
+
+// closestMatch returns the first candidate nearest to s within maxDistance edits
+func closestMatch(s string, candidates []string, maxDistance int) string {
+	best, bestDistance := "", maxDistance+1
+	for _, candidate := range candidates {
+		if distance := editDistance(s, candidate); distance < bestDistance {
+			best, bestDistance = candidate, distance
+		}
+	}
+	return best
+}
+
+// editDistance returns the optimal string alignment distance between a and
+// b: the number of insertions, deletions, substitutions and transpositions
+// of adjacent characters needed to turn one into the other
+func editDistance(a, b string) int {
+	s, t := []rune(a), []rune(b)
+
+	// Three rows of the distance matrix: two back, previous and current
+	twoBack := make([]int, len(t)+1)
+	previous := make([]int, len(t)+1)
+	current := make([]int, len(t)+1)
+	for j := range previous {
+		previous[j] = j
+	}
+
+	for i := 1; i <= len(s); i++ {
+		current[0] = i
+		for j := 1; j <= len(t); j++ {
+			cost := 1
+			if s[i-1] == t[j-1] {
+				cost = 0
+			}
+			current[j] = min(previous[j]+1, current[j-1]+1, previous[j-1]+cost)
+			if i > 1 && j > 1 && s[i-1] == t[j-2] && s[i-2] == t[j-1] {
+				current[j] = min(current[j], twoBack[j-2]+1)
+			}
+		}
+		twoBack, previous, current = previous, current, twoBack
+	}
+	return previous[len(t)]
+}
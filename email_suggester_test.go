@@ -0,0 +1,72 @@
+package main
+
+import "testing"
+
+func TestEditDistance(t *testing.T) {
+	tests := []struct {
+		a, b     string
+		expected int
+	}{
+		{"", "", 0},
+		{"gmail", "gmail", 0},
+		{"", "com", 3},
+		{"gmial", "gmail", 1},
+		{"con", "com", 1},
+		{"gmai", "gmail", 1},
+		{"gmaill", "gmail", 1},
+		{"hotmial", "hotmail", 1},
+		{"yahoo", "gmail", 5},
+		{"bücher", "bucher", 1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.a+"_"+tt.b, func(t *testing.T) {
+			if got := editDistance(tt.a, tt.b); got != tt.expected {
+				t.Errorf("Distance mismatch. Expected: %d, Got: %d", tt.expected, got)
+			}
+			if got := editDistance(tt.b, tt.a); got != tt.expected {
+				t.Errorf("Reverse distance mismatch. Expected: %d, Got: %d", tt.expected, got)
+			}
+		})
+	}
+}
+
+func TestEmailSuggesterSuggest(t *testing.T) {
+	suggester := defaultEmailSuggester()
+
+	tests := []struct {
+		email    string
+		expected string
+	}{
+		{"jane@gmial.com", "jane@gmail.com"},
+		{"bob@yahoo.con", "bob@yahoo.com"},
+		{"Bob@Hotmail.co", "Bob@hotmail.com"},
+		{"sam@outlok.com", "sam@outlook.com"},
+		{"amy@gmail", "amy@gmail.com"},
+		{"lee@company.cmo", "lee@company.com"},
+		{"lee@company.nte", "lee@company.net"},
+		{"jane@gmail.com", ""},
+		{"jane@mail.com", ""},
+		{"jane@company.example", ""},
+		{"jane@company.de", ""},
+		{"a@company.pt", ""},
+		{"jan@firma.cz", ""},
+		{"ion@x.ro", ""},
+		{"sean@company.ie", ""},
+		{"ana@company.mx", ""},
+		{"nikos@abc.gr", ""},
+		{"sam@x.ai", ""},
+		{"lee@company.xn--p1ai", ""},
+		{"jane@mx.com", ""},
+		{"not-an-email", ""},
+		{"@gmial.com", ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.email, func(t *testing.T) {
+			if got := suggester.Suggest(tt.email); got != tt.expected {
+				t.Errorf("Suggestion mismatch. Expected: %q, Got: %q", tt.expected, got)
+			}
+		})
+	}
+}
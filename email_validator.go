@@ -1,33 +1,291 @@
 package main
 
 import (
+	"context"
+	"fmt"
+	"net"
 	"regexp"
 	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"golang.org/x/net/idna"
+	"golang.org/x/text/unicode/norm"
+)
+
+const (
+	// maxLocalPartLength is the RFC 5321 limit on the local part, in octets
+	maxLocalPartLength = 64
+
+	// maxAddressLength is the RFC 5321 limit on a whole address, in octets
+	maxAddressLength = 254
+
+	// maxLabelLength is the RFC 1035 limit on a single domain label, in octets
+	maxLabelLength = 63
+)
+
+// ValidationProfile selects how strictly addresses are checked
+type ValidationProfile string
+
+const (
+	// ProfileStrict accepts any RFC 5322 addr-spec, including quoted local
+	// parts and IP-literal domains
+	ProfileStrict ValidationProfile = "strict"
+
+	// ProfilePractical accepts HTML5-style dot-atom addresses with a
+	// multi-label domain ending in an alphabetic top-level domain
+	ProfilePractical ValidationProfile = "practical"
+
+	// ProfilePermissive only rejects addresses that cannot possibly be delivered
+	ProfilePermissive ValidationProfile = "permissive"
+)
+
+// defaultValidationProfile is the profile used when none is configured
+const defaultValidationProfile = ProfilePractical
+
+// ParseValidationProfile parses a profile name, defaulting to the practical profile when empty
+func ParseValidationProfile(name string) (ValidationProfile, error) {
+	switch profile := ValidationProfile(strings.ToLower(strings.TrimSpace(name))); profile {
+	case "":
+		return defaultValidationProfile, nil
+	case ProfileStrict, ProfilePractical, ProfilePermissive:
+		return profile, nil
+	default:
+		return "", fmt.Errorf("unknown validation profile %q", name)
+	}
+}
+
+// ValidationReason explains why an address failed validation
+type ValidationReason string
+
+const (
+	ReasonEmpty            ValidationReason = "empty"
+	ReasonMissingAt        ValidationReason = "missing_at"
+	ReasonMultipleAt       ValidationReason = "multiple_at"
+	ReasonMissingLocalPart ValidationReason = "missing_local_part"
+	ReasonMissingDomain    ValidationReason = "missing_domain"
+	ReasonInvalidLocalPart ValidationReason = "invalid_local_part"
+	ReasonInvalidDomain    ValidationReason = "invalid_domain"
+	ReasonBadTLD           ValidationReason = "bad_tld"
+	ReasonLocalPartTooLong ValidationReason = "local_part_too_long"
+	ReasonAddressTooLong   ValidationReason = "address_too_long"
+	ReasonLabelTooLong     ValidationReason = "label_too_long"
+	ReasonPatternMismatch  ValidationReason = "pattern_mismatch"
+	ReasonNoMailServer     ValidationReason = "no_mail_server"
+	ReasonPolicyViolation  ValidationReason = "policy_violation"
 )
 
+// ValidationResult is the outcome of validating a single address
+type ValidationResult struct {
+	Valid  bool             `json:"valid"`
+	Reason ValidationReason `json:"reason,omitempty"`
+
+	// ASCII is the valid address with its domain converted to lower-case
+	// IDNA A-labels (punycode). Unicode local parts are kept in NFC form
+	// as they have no ASCII encoding.
+	ASCII string `json:"ascii,omitempty"`
+
+	// Domain is the outcome of the DNS check, when one is configured
+	Domain DomainStatus `json:"domain_status,omitempty"`
+
+	// Disposable and FreeProvider report whether a valid address belongs
+	// to a throwaway or free-mail domain
+	Disposable   bool `json:"disposable,omitempty"`
+	FreeProvider bool `json:"free_provider,omitempty"`
+
+	// RoleAccount reports whether a valid address belongs to a function,
+	// such as info@ or noreply@, rather than a person
+	RoleAccount bool `json:"role_account,omitempty"`
+
+	// PolicyViolation explains why a well-formed address was rejected by
+	// the domain policy; see DomainPolicy.Check
+	PolicyViolation string `json:"policy_violation,omitempty"`
+}
+
+// EmailValidatorOptions configures an EmailValidator
+type EmailValidatorOptions struct {
+	Profile ValidationProfile
+
+	// Pattern, if not empty, is an extra regular expression well-formed addresses must match
+	Pattern string
+
+	// SMTPUTF8 accepts internationalized addresses: Unicode local parts
+	// (RFC 6531) and Unicode domains (IDNA 2008)
+	SMTPUTF8 bool
+
+	// DomainChecker, if set, rejects addresses whose domain cannot receive mail
+	DomainChecker *DomainChecker
+
+	// Disposable and FreeProviders classify valid addresses by domain; the
+	// embedded default lists are used when nil
+	Disposable    *DomainList
+	FreeProviders *DomainList
+
+	// RoleAccounts classifies valid addresses by local part; the embedded
+	// default list is used when nil
+	RoleAccounts *RoleAccountList
+
+	// Suggester proposes corrections for mistyped domains; the embedded
+	// popular domains and TLDs are used when nil
+	Suggester *EmailSuggester
+}
+
 // EmailValidator handles email validation logic
 type EmailValidator struct {
+	profile  ValidationProfile
+	smtputf8 bool
+	domains  *DomainChecker
+
+	disposable    *DomainList
+	freeProviders *DomainList
+	roleAccounts  *RoleAccountList
+	suggester     *EmailSuggester
+
+	// policy, if set, restricts which domains are accepted
+	policy *DomainPolicy
+
+	// emailRegex, if set, is an extra pattern well-formed addresses must match
 	emailRegex *regexp.Regexp
 }
 
-// NewEmailValidator creates a new email validator
+// NewEmailValidator creates a new email validator using the practical
+// profile with internationalized addresses enabled
 func NewEmailValidator() *EmailValidator {
-	// More strict email regex pattern that allows + and % in local part
-	emailPattern := `^[a-zA-Z0-9]([a-zA-Z0-9._%+-]*[a-zA-Z0-9])?@[a-zA-Z0-9]([a-zA-Z0-9.-]*[a-zA-Z0-9])?\.[a-zA-Z]{2,}$`
-	emailRegex := regexp.MustCompile(emailPattern)
-
 	return &EmailValidator{
-		emailRegex: emailRegex,
+		profile:       defaultValidationProfile,
+		smtputf8:      true,
+		disposable:    defaultDisposableList(),
+		freeProviders: defaultFreeProviderList(),
+		roleAccounts:  defaultRoleAccountList(),
+		suggester:     defaultEmailSuggester(),
+	}
+}
+
+// NewEmailValidatorWithPattern creates a practical-profile email validator
+// that also requires addresses to match pattern
+func NewEmailValidatorWithPattern(pattern string) (*EmailValidator, error) {
+	return NewEmailValidatorWithProfile(defaultValidationProfile, pattern)
+}
+
+// NewEmailValidatorWithProfile creates an email validator for profile with
+// internationalized addresses enabled. When pattern is not empty, addresses
+// must also match it.
+func NewEmailValidatorWithProfile(profile ValidationProfile, pattern string) (*EmailValidator, error) {
+	return NewEmailValidatorWithOptions(EmailValidatorOptions{
+		Profile:  profile,
+		Pattern:  pattern,
+		SMTPUTF8: true,
+	})
+}
+
+// NewEmailValidatorWithOptions creates an email validator configured by opts
+func NewEmailValidatorWithOptions(opts EmailValidatorOptions) (*EmailValidator, error) {
+	profile, err := ParseValidationProfile(string(opts.Profile))
+	if err != nil {
+		return nil, err
 	}
+
+	validator := &EmailValidator{
+		profile:       profile,
+		smtputf8:      opts.SMTPUTF8,
+		domains:       opts.DomainChecker,
+		disposable:    opts.Disposable,
+		freeProviders: opts.FreeProviders,
+		roleAccounts:  opts.RoleAccounts,
+		suggester:     opts.Suggester,
+	}
+	if validator.disposable == nil {
+		validator.disposable = defaultDisposableList()
+	}
+	if validator.freeProviders == nil {
+		validator.freeProviders = defaultFreeProviderList()
+	}
+	if validator.roleAccounts == nil {
+		validator.roleAccounts = defaultRoleAccountList()
+	}
+	if validator.suggester == nil {
+		validator.suggester = defaultEmailSuggester()
+	}
+	if opts.Pattern != "" {
+		validator.emailRegex, err = regexp.Compile(opts.Pattern)
+		if err != nil {
+			return nil, fmt.Errorf("invalid email pattern: %w", err)
+		}
+	}
+	return validator, nil
+}
+
+// WithPolicy returns a copy of the validator that also rejects addresses
+// whose domain violates policy
+func (ev *EmailValidator) WithPolicy(policy *DomainPolicy) *EmailValidator {
+	scoped := *ev
+	scoped.policy = policy
+	return &scoped
 }
 
 // IsValidEmail checks if a string is a valid email address
 func (ev *EmailValidator) IsValidEmail(email string) bool {
+	return ev.Validate(email).Valid
+}
+
+// Validate checks a single address and explains why it is invalid
+func (ev *EmailValidator) Validate(email string) ValidationResult {
+	return ev.ValidateContext(context.Background(), email)
+}
+
+// ValidateContext is like Validate, but stops waiting for DNS lookups once ctx is done
+func (ev *EmailValidator) ValidateContext(ctx context.Context, email string) ValidationResult {
 	email = strings.TrimSpace(email)
 	if email == "" {
-		return false
+		return ValidationResult{Reason: ReasonEmpty}
+	}
+
+	address, reason := ev.parseAddress(email)
+	if reason != "" {
+		return ValidationResult{Reason: reason}
 	}
-	return ev.emailRegex.MatchString(email)
+
+	// Well formed, but rejected by a custom pattern
+	if ev.emailRegex != nil && !ev.emailRegex.MatchString(email) {
+		return ValidationResult{Reason: ReasonPatternMismatch}
+	}
+
+	// Well formed, but not acceptable for this upload
+	if violation := ev.policy.Check(address.asciiDomain); violation != "" {
+		return ValidationResult{Reason: ReasonPolicyViolation, PolicyViolation: violation}
+	}
+
+	result := ValidationResult{
+		Valid:        true,
+		ASCII:        address.ascii(),
+		Disposable:   ev.disposable.Contains(address.asciiDomain),
+		FreeProvider: ev.freeProviders.Contains(address.asciiDomain),
+		RoleAccount:  ev.roleAccounts.Contains(address.localPart),
+	}
+
+	// Lookup failures are not held against the address
+	if ev.domains != nil && !strings.HasPrefix(address.asciiDomain, "[") {
+		result.Domain = ev.domains.Check(ctx, address.asciiDomain)
+		if result.Domain == DomainNone {
+			return ValidationResult{Reason: ReasonNoMailServer, Domain: result.Domain}
+		}
+	}
+
+	return result
+}
+
+// Suggest returns a corrected address when email's domain looks like a typo
+// of a popular domain or TLD, such as jane@gmial.com or bob@yahoo.con. Only
+// suggestions that are themselves well formed are returned.
+func (ev *EmailValidator) Suggest(email string) string {
+	suggestion := ev.suggester.Suggest(email)
+	if suggestion == "" {
+		return ""
+	}
+	if _, reason := ev.parseAddress(suggestion); reason != "" {
+		return ""
+	}
+	return suggestion
 }
 
 // HasValidEmail checks if any field in a row contains a valid email
@@ -39,3 +297,290 @@ func (ev *EmailValidator) HasValidEmail(fields []string) bool {
 	}
 	return false
 }
+
+// parsedAddress is an address split into its validated parts
+type parsedAddress struct {
+	localPart string
+
+	// asciiDomain is the domain in lower-case A-label form
+	asciiDomain string
+}
+
+// ascii returns the address with its NFC local part and ASCII domain
+func (a parsedAddress) ascii() string {
+	return norm.NFC.String(a.localPart) + "@" + a.asciiDomain
+}
+
+// parseAddress checks email against the validator's profile and returns the
+// reason it is invalid, or an empty reason when it is valid
+func (ev *EmailValidator) parseAddress(email string) (parsedAddress, ValidationReason) {
+	localPart, domain, reason := splitAddress(email, ev.profile)
+	if reason != "" {
+		return parsedAddress{}, reason
+	}
+
+	switch {
+	case localPart == "":
+		return parsedAddress{}, ReasonMissingLocalPart
+	case domain == "":
+		return parsedAddress{}, ReasonMissingDomain
+	case len(localPart) > maxLocalPartLength:
+		return parsedAddress{}, ReasonLocalPartTooLong
+	}
+
+	if !validLocalPart(localPart, ev.profile, ev.smtputf8) {
+		return parsedAddress{}, ReasonInvalidLocalPart
+	}
+
+	asciiDomain, reason := checkDomain(domain, ev.profile, ev.smtputf8)
+	if reason != "" {
+		return parsedAddress{}, reason
+	}
+
+	// The length limit applies to the address as sent over SMTP
+	if len(localPart)+1+len(asciiDomain) > maxAddressLength {
+		return parsedAddress{}, ReasonAddressTooLong
+	}
+
+	return parsedAddress{localPart: localPart, asciiDomain: asciiDomain}, ""
+}
+
+// splitAddress separates the local part from the domain at the @ that is
+// not inside a quoted local part
+func splitAddress(email string, profile ValidationProfile) (string, string, ValidationReason) {
+	// Quoted local parts may themselves contain @
+	if strings.HasPrefix(email, `"`) && profile != ProfilePractical {
+		end := quotedStringEnd(email)
+		if end < 0 {
+			return "", "", ReasonInvalidLocalPart
+		}
+		rest := email[end:]
+		switch {
+		case rest == "":
+			return "", "", ReasonMissingAt
+		case rest[0] != '@':
+			return "", "", ReasonInvalidLocalPart
+		case strings.Contains(rest[1:], "@"):
+			return "", "", ReasonMultipleAt
+		}
+		return email[:end], rest[1:], ""
+	}
+
+	switch strings.Count(email, "@") {
+	case 0:
+		return "", "", ReasonMissingAt
+	case 1:
+		localPart, domain, _ := strings.Cut(email, "@")
+		return localPart, domain, ""
+	default:
+		return "", "", ReasonMultipleAt
+	}
+}
+
+// quotedStringEnd returns the index just past the closing quote of the
+// quoted string at the start of s, or -1 if it is not terminated
+func quotedStringEnd(s string) int {
+	for i := 1; i < len(s); i++ {
+		switch s[i] {
+		case '\\':
+			i++
+		case '"':
+			return i + 1
+		}
+	}
+	return -1
+}
+
+// validLocalPart reports whether localPart is acceptable under profile,
+// allowing UTF-8 characters only when smtputf8 is set
+func validLocalPart(localPart string, profile ValidationProfile, smtputf8 bool) bool {
+	if !smtputf8 && !isASCII(localPart) {
+		return false
+	}
+
+	if strings.HasPrefix(localPart, `"`) && profile != ProfilePractical {
+		return validQuotedString(localPart)
+	}
+
+	switch profile {
+	case ProfilePermissive:
+		// Anything printable, including dots in odd places
+		for _, r := range localPart {
+			if unicode.IsSpace(r) || unicode.IsControl(r) || r == utf8.RuneError {
+				return false
+			}
+		}
+		return true
+	default:
+		return validDotAtom(localPart, smtputf8)
+	}
+}
+
+// validDotAtom reports whether s is an RFC 5322 dot-atom, optionally
+// allowing RFC 6531 UTF-8 characters
+func validDotAtom(s string, allowUTF8 bool) bool {
+	for _, atom := range strings.Split(s, ".") {
+		if atom == "" {
+			return false
+		}
+		for _, r := range atom {
+			if !isAtext(r, allowUTF8) {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+// isAtext reports whether r may appear unquoted in a local part
+func isAtext(r rune, allowUTF8 bool) bool {
+	switch {
+	case r >= utf8.RuneSelf:
+		return allowUTF8 && r != utf8.RuneError && !unicode.IsSpace(r) && !unicode.IsControl(r)
+	case 'a' <= r && r <= 'z', 'A' <= r && r <= 'Z', '0' <= r && r <= '9':
+		return true
+	default:
+		return strings.ContainsRune("!#$%&'*+-/=?^_`{|}~", r)
+	}
+}
+
+// validQuotedString reports whether s is an RFC 5322 quoted-string
+func validQuotedString(s string) bool {
+	if len(s) < 2 || s[0] != '"' || s[len(s)-1] != '"' {
+		return false
+	}
+
+	content := s[1 : len(s)-1]
+	for i := 0; i < len(content); i++ {
+		c := content[i]
+		switch {
+		case c == '\\':
+			// quoted-pair escapes a single visible character or space
+			i++
+			if i >= len(content) || content[i] < ' ' || content[i] == 0x7f {
+				return false
+			}
+		case c == '"':
+			return false
+		case c < ' ' && c != '\t', c == 0x7f:
+			return false
+		}
+	}
+	return utf8.ValidString(content)
+}
+
+// checkDomain validates the domain of an address under profile and
+// returns it in lower-case A-label form
+func checkDomain(domain string, profile ValidationProfile, smtputf8 bool) (string, ValidationReason) {
+	if strings.HasPrefix(domain, "[") {
+		if profile == ProfilePractical || !validDomainLiteral(domain) {
+			return "", ReasonInvalidDomain
+		}
+		return domain, ""
+	}
+
+	// Internationalized domains are checked and converted with IDNA 2008
+	if !isASCII(domain) || hasACELabel(domain) {
+		if !smtputf8 && !isASCII(domain) {
+			return "", ReasonInvalidDomain
+		}
+		ascii, err := idna.Lookup.ToASCII(domain)
+		if err != nil {
+			return "", ReasonInvalidDomain
+		}
+		domain = ascii
+	}
+	domain = strings.ToLower(domain)
+
+	labels := strings.Split(domain, ".")
+	for _, label := range labels {
+		if label == "" {
+			return "", ReasonInvalidDomain
+		}
+		if len(label) > maxLabelLength {
+			return "", ReasonLabelTooLong
+		}
+		if !validLabel(label, profile) {
+			return "", ReasonInvalidDomain
+		}
+	}
+
+	// Practical addresses need a real-looking top-level domain
+	if profile == ProfilePractical {
+		tld := labels[len(labels)-1]
+		if len(labels) < 2 || !validTLD(tld) {
+			return "", ReasonBadTLD
+		}
+	}
+	return domain, ""
+}
+
+// validLabel reports whether an ASCII label is a usable domain label under profile
+func validLabel(label string, profile ValidationProfile) bool {
+	if profile != ProfilePermissive && (strings.HasPrefix(label, "-") || strings.HasSuffix(label, "-")) {
+		return false
+	}
+
+	for _, r := range label {
+		switch {
+		case 'a' <= r && r <= 'z', 'A' <= r && r <= 'Z', '0' <= r && r <= '9', r == '-':
+		case r == '_' && profile == ProfilePermissive:
+		default:
+			return false
+		}
+	}
+	return true
+}
+
+// isASCII reports whether s contains only ASCII characters
+func isASCII(s string) bool {
+	for i := 0; i < len(s); i++ {
+		if s[i] >= utf8.RuneSelf {
+			return false
+		}
+	}
+	return true
+}
+
+// hasACELabel reports whether domain contains an IDNA A-label such as xn--bcher-kva
+func hasACELabel(domain string) bool {
+	for _, label := range strings.Split(domain, ".") {
+		if len(label) >= 4 && strings.EqualFold(label[:4], "xn--") {
+			return true
+		}
+	}
+	return false
+}
+
+// validTLD reports whether tld is alphabetic and at least two characters
+// long, or an IDNA A-label
+func validTLD(tld string) bool {
+	if len(tld) < 2 {
+		return false
+	}
+	if strings.HasPrefix(strings.ToLower(tld), "xn--") {
+		return true
+	}
+	for _, r := range tld {
+		if !('a' <= r && r <= 'z' || 'A' <= r && r <= 'Z') {
+			return false
+		}
+	}
+	return true
+}
+
+// validDomainLiteral reports whether domain is an IPv4 literal such as
+// [192.0.2.1] or an IPv6 literal such as [IPv6:2001:db8::1]
+func validDomainLiteral(domain string) bool {
+	if !strings.HasSuffix(domain, "]") {
+		return false
+	}
+	literal := domain[1 : len(domain)-1]
+
+	if len(literal) > 5 && strings.EqualFold(literal[:5], "IPv6:") {
+		ip := net.ParseIP(literal[5:])
+		return ip != nil && ip.To4() == nil
+	}
+	ip := net.ParseIP(literal)
+	return ip != nil && ip.To4() != nil && !strings.Contains(literal, ":")
+}
@@ -1,6 +1,7 @@
 package main
 
 import (
+	"strings"
 	"testing"
 )
 
@@ -9,8 +10,8 @@ func TestNewEmailValidator(t *testing.T) {
 	if validator == nil {
 		t.Fatal("NewEmailValidator() returned nil")
 	}
-	if validator.emailRegex == nil {
-		t.Fatal("Email regex is nil")
+	if validator.profile != ProfilePractical {
+		t.Errorf("Profile mismatch. Expected: %s, Got: %s", ProfilePractical, validator.profile)
 	}
 }
 
@@ -48,7 +49,7 @@ func TestIsValidEmail(t *testing.T) {
 		{"Email with no TLD", "test@example", false},
 		{"Email with leading dot", ".test@example.com", false},
 		{"Email with trailing dot", "test.@example.com", false},
-		{"Email with consecutive dots", "test..test@example.com", true}, // Current regex allows this
+		{"Email with consecutive dots", "test..test@example.com", false},
 		{"Email with @ in local part", "te@st@example.com", false},
 		{"Email with @ in domain", "test@ex@ample.com", false},
 	}
@@ -120,3 +121,331 @@ func TestEmailValidatorConcurrency(t *testing.T) {
 		<-done
 	}
 }
+
+func TestNewEmailValidatorWithPattern(t *testing.T) {
+	// Only accept addresses at example.com
+	validator, err := NewEmailValidatorWithPattern(`^[^@\s]+@example\.com$`)
+	if err != nil {
+		t.Fatalf("NewEmailValidatorWithPattern failed: %v", err)
+	}
+	if !validator.IsValidEmail("john@example.com") {
+		t.Error("Expected john@example.com to be valid")
+	}
+	if validator.IsValidEmail("john@example.org") {
+		t.Error("Expected john@example.org to be invalid")
+	}
+
+	if _, err := NewEmailValidatorWithPattern("("); err == nil {
+		t.Error("Expected error for invalid pattern")
+	}
+}
+
+func TestValidate(t *testing.T) {
+	validator := NewEmailValidator()
+
+	tests := []struct {
+		name           string
+		email          string
+		expectedValid  bool
+		expectedReason ValidationReason
+	}{
+		{"Valid email", "test@example.com", true, ""},
+		{"Empty", "   ", false, ReasonEmpty},
+		{"Missing @", "testexample.com", false, ReasonMissingAt},
+		{"Multiple @", "test@@example.com", false, ReasonMultipleAt},
+		{"Missing local part", "@example.com", false, ReasonMissingLocalPart},
+		{"Missing domain", "test@", false, ReasonMissingDomain},
+		{"Invalid local part", ".test@example.com", false, ReasonInvalidLocalPart},
+		{"Invalid domain", "test@example!.com", false, ReasonInvalidDomain},
+		{"No TLD", "test@example", false, ReasonBadTLD},
+		{"Short TLD", "test@example.c", false, ReasonBadTLD},
+		{"Numeric TLD", "test@example.123", false, ReasonBadTLD},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result := validator.Validate(tt.email)
+			if result.Valid != tt.expectedValid {
+				t.Errorf("Valid mismatch for %q. Expected: %t, Got: %t", tt.email, tt.expectedValid, result.Valid)
+			}
+			if result.Reason != tt.expectedReason {
+				t.Errorf("Reason mismatch for %q. Expected: %s, Got: %s", tt.email, tt.expectedReason, result.Reason)
+			}
+		})
+	}
+}
+
+func TestValidatePatternMismatch(t *testing.T) {
+	validator, err := NewEmailValidatorWithPattern(`^[^@\s]+@example\.com$`)
+	if err != nil {
+		t.Fatalf("NewEmailValidatorWithPattern failed: %v", err)
+	}
+
+	result := validator.Validate("john@example.org")
+	if result.Valid || result.Reason != ReasonPatternMismatch {
+		t.Errorf("Expected %s, got %+v", ReasonPatternMismatch, result)
+	}
+}
+
+func TestValidationProfiles(t *testing.T) {
+	tests := []struct {
+		name       string
+		email      string
+		strict     ValidationReason
+		practical  ValidationReason
+		permissive ValidationReason
+	}{
+		{"Simple address", "user@example.com", "", "", ""},
+		{"Single-character labels", "a@a.b.io", "", "", ""},
+		{"All atext characters", "!#$%&'*+-/=?^_`{|}~@example.com", "", "", ""},
+		{"Quoted local part", `"john doe"@example.com`, "", ReasonInvalidLocalPart, ""},
+		{"Quoted local part with @", `"john@home"@example.com`, "", ReasonMultipleAt, ""},
+		{"Quoted local part with escape", `"john\"doe"@example.com`, "", ReasonInvalidLocalPart, ""},
+		{"Unterminated quoted local part", `"john@example.com`, ReasonInvalidLocalPart, ReasonInvalidLocalPart, ReasonInvalidLocalPart},
+		{"IPv4 literal", "user@[192.0.2.1]", "", ReasonInvalidDomain, ""},
+		{"IPv6 literal", "user@[IPv6:2001:db8::1]", "", ReasonInvalidDomain, ""},
+		{"Bad IP literal", "user@[300.0.0.1]", ReasonInvalidDomain, ReasonInvalidDomain, ReasonInvalidDomain},
+		{"Single-label domain", "user@localhost", "", ReasonBadTLD, ""},
+		{"Punycode TLD", "user@example.xn--p1ai", "", "", ""},
+		{"Consecutive dots", "test..test@example.com", ReasonInvalidLocalPart, ReasonInvalidLocalPart, ""},
+		{"Leading dot", ".test@example.com", ReasonInvalidLocalPart, ReasonInvalidLocalPart, ""},
+		{"Hyphen at label start", "user@-example.com", ReasonInvalidDomain, ReasonInvalidDomain, ""},
+		{"Underscore in domain", "user@my_host.example.com", ReasonInvalidDomain, ReasonInvalidDomain, ""},
+		{"UTF-8 local part", "josé@example.com", "", "", ""},
+		{"UTF-8 domain", "user@bücher.de", "", "", ""},
+		{"Space in local part", "john doe@example.com", ReasonInvalidLocalPart, ReasonInvalidLocalPart, ReasonInvalidLocalPart},
+		{"Local part of 64 octets", strings.Repeat("a", 64) + "@example.com", "", "", ""},
+		{"Local part of 65 octets", strings.Repeat("a", 65) + "@example.com", ReasonLocalPartTooLong, ReasonLocalPartTooLong, ReasonLocalPartTooLong},
+		{"Label of 63 characters", "user@" + strings.Repeat("a", 63) + ".com", "", "", ""},
+		{"Label of 64 characters", "user@" + strings.Repeat("a", 64) + ".com", ReasonLabelTooLong, ReasonLabelTooLong, ReasonLabelTooLong},
+		{"Address of 254 octets", "user@" + strings.Repeat(strings.Repeat("a", 61)+".", 3) + strings.Repeat("a", 63), "", "", ""},
+		{"Address of 255 octets", "users@" + strings.Repeat(strings.Repeat("a", 61)+".", 3) + strings.Repeat("a", 63), ReasonAddressTooLong, ReasonAddressTooLong, ReasonAddressTooLong},
+	}
+
+	profiles := []ValidationProfile{ProfileStrict, ProfilePractical, ProfilePermissive}
+	for _, tt := range tests {
+		expected := []ValidationReason{tt.strict, tt.practical, tt.permissive}
+		for i, profile := range profiles {
+			t.Run(tt.name+"/"+string(profile), func(t *testing.T) {
+				validator, err := NewEmailValidatorWithProfile(profile, "")
+				if err != nil {
+					t.Fatalf("NewEmailValidatorWithProfile failed: %v", err)
+				}
+
+				result := validator.Validate(tt.email)
+				if result.Reason != expected[i] {
+					t.Errorf("Reason mismatch for %q. Expected: %q, Got: %q", tt.email, expected[i], result.Reason)
+				}
+				if result.Valid != (expected[i] == "") {
+					t.Errorf("Valid mismatch for %q. Expected: %t, Got: %t", tt.email, expected[i] == "", result.Valid)
+				}
+			})
+		}
+	}
+}
+
+func TestParseValidationProfile(t *testing.T) {
+	tests := []struct {
+		name        string
+		input       string
+		expected    ValidationProfile
+		expectError bool
+	}{
+		{"Empty defaults to practical", "", ProfilePractical, false},
+		{"Strict", "strict", ProfileStrict, false},
+		{"Mixed case", "Permissive", ProfilePermissive, false},
+		{"Unknown", "lenient", "", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			profile, err := ParseValidationProfile(tt.input)
+			if (err != nil) != tt.expectError {
+				t.Fatalf("Error mismatch. Expected error: %t, Got: %v", tt.expectError, err)
+			}
+			if profile != tt.expected {
+				t.Errorf("Profile mismatch. Expected: %s, Got: %s", tt.expected, profile)
+			}
+		})
+	}
+}
+
+func TestValidateInternationalized(t *testing.T) {
+	validator := NewEmailValidator()
+
+	tests := []struct {
+		name           string
+		email          string
+		expectedReason ValidationReason
+		expectedASCII  string
+	}{
+		{"ASCII address", "John@Example.COM", "", "John@example.com"},
+		{"Unicode domain", "josé@bücher.de", "", "josé@xn--bcher-kva.de"},
+		{"Unicode address", "用户@例子.广告", "", "用户@xn--fsqu00a.xn--4rr70v"},
+		{"Mixed-case Unicode domain", "user@BÜCHER.de", "", "user@xn--bcher-kva.de"},
+		{"A-label domain", "user@xn--bcher-kva.de", "", "user@xn--bcher-kva.de"},
+		{"Decomposed local part", "jose\u0301@example.com", "", "jos\u00e9@example.com"},
+		{"Invalid A-label", "user@xn--a.de", ReasonInvalidDomain, ""},
+		{"Disallowed code point", "user@exa\u2028mple.com", ReasonInvalidDomain, ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result := validator.Validate(tt.email)
+			if result.Reason != tt.expectedReason {
+				t.Errorf("Reason mismatch for %q. Expected: %q, Got: %q", tt.email, tt.expectedReason, result.Reason)
+			}
+			if result.ASCII != tt.expectedASCII {
+				t.Errorf("ASCII mismatch for %q. Expected: %s, Got: %s", tt.email, tt.expectedASCII, result.ASCII)
+			}
+		})
+	}
+}
+
+func TestValidateWithoutSMTPUTF8(t *testing.T) {
+	validator, err := NewEmailValidatorWithOptions(EmailValidatorOptions{Profile: ProfileStrict})
+	if err != nil {
+		t.Fatalf("NewEmailValidatorWithOptions failed: %v", err)
+	}
+
+	tests := []struct {
+		name           string
+		email          string
+		expectedReason ValidationReason
+	}{
+		{"ASCII address", "user@example.com", ""},
+		{"A-label domain", "user@xn--bcher-kva.de", ""},
+		{"Unicode local part", "josé@example.com", ReasonInvalidLocalPart},
+		{"Unicode domain", "user@bücher.de", ReasonInvalidDomain},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result := validator.Validate(tt.email)
+			if result.Reason != tt.expectedReason {
+				t.Errorf("Reason mismatch for %q. Expected: %q, Got: %q", tt.email, tt.expectedReason, result.Reason)
+			}
+		})
+	}
+}
+
+func TestValidateProviderFlags(t *testing.T) {
+	disposable, err := NewDomainList("throwaway.example\n", "", 0)
+	if err != nil {
+		t.Fatalf("NewDomainList failed: %v", err)
+	}
+	validator, err := NewEmailValidatorWithOptions(EmailValidatorOptions{Disposable: disposable, SMTPUTF8: true})
+	if err != nil {
+		t.Fatalf("NewEmailValidatorWithOptions failed: %v", err)
+	}
+
+	tests := []struct {
+		email                string
+		expectedDisposable   bool
+		expectedFreeProvider bool
+	}{
+		{"user@throwaway.example", true, false},
+		{"user@mail.throwaway.example", true, false},
+		{"user@GMail.com", false, true},
+		{"user@company.example", false, false},
+		// Only the configured disposable list is used, not the default one
+		{"user@mailinator.com", false, false},
+		{"not-an-email", false, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.email, func(t *testing.T) {
+			result := validator.Validate(tt.email)
+			if result.Disposable != tt.expectedDisposable {
+				t.Errorf("Disposable mismatch. Expected: %t, Got: %t", tt.expectedDisposable, result.Disposable)
+			}
+			if result.FreeProvider != tt.expectedFreeProvider {
+				t.Errorf("FreeProvider mismatch. Expected: %t, Got: %t", tt.expectedFreeProvider, result.FreeProvider)
+			}
+		})
+	}
+}
+
+func TestValidateRoleAccount(t *testing.T) {
+	validator := NewEmailValidator()
+
+	tests := []struct {
+		email    string
+		expected bool
+	}{
+		{"info@company.example", true},
+		{"No-Reply@company.example", true},
+		{"jane.doe@company.example", false},
+		{"info", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.email, func(t *testing.T) {
+			if result := validator.Validate(tt.email); result.RoleAccount != tt.expected {
+				t.Errorf("RoleAccount mismatch. Expected: %t, Got: %t", tt.expected, result.RoleAccount)
+			}
+		})
+	}
+}
+
+func TestValidatorSuggest(t *testing.T) {
+	validator := NewEmailValidator()
+
+	tests := []struct {
+		email    string
+		expected string
+	}{
+		{"jane@gmial.com", "jane@gmail.com"},
+		{"bob@yahoo.con", "bob@yahoo.com"},
+		{"jane@gmail.com", ""},
+		// Suggestions are only made for well-formed corrections
+		{"ja ne@gmial.com", ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.email, func(t *testing.T) {
+			if got := validator.Suggest(tt.email); got != tt.expected {
+				t.Errorf("Suggestion mismatch. Expected: %q, Got: %q", tt.expected, got)
+			}
+		})
+	}
+}
+
+func TestValidateWithPolicy(t *testing.T) {
+	policy, err := NewDomainPolicy([]string{".acme.com"}, []string{"old.acme.com"})
+	if err != nil {
+		t.Fatalf("NewDomainPolicy failed: %v", err)
+	}
+	base := NewEmailValidator()
+	validator := base.WithPolicy(policy)
+
+	tests := []struct {
+		email             string
+		expectedReason    ValidationReason
+		expectedViolation string
+	}{
+		{"jane@acme.com", "", ""},
+		{"jane@eu.acme.com", "", ""},
+		{"jane@old.acme.com", ReasonPolicyViolation, "denied:old.acme.com"},
+		{"jane@example.com", ReasonPolicyViolation, policyNotAllowed},
+		// Malformed addresses keep their own reason
+		{"jane@@acme.com", ReasonMultipleAt, ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.email, func(t *testing.T) {
+			result := validator.Validate(tt.email)
+			if result.Reason != tt.expectedReason {
+				t.Errorf("Reason mismatch. Expected: %q, Got: %q", tt.expectedReason, result.Reason)
+			}
+			if result.PolicyViolation != tt.expectedViolation {
+				t.Errorf("Violation mismatch. Expected: %q, Got: %q", tt.expectedViolation, result.PolicyViolation)
+			}
+		})
+	}
+
+	// The original validator is not affected
+	if !base.IsValidEmail("jane@example.com") {
+		t.Error("WithPolicy should not change the original validator")
+	}
+}
@@ -5,4 +5,6 @@ go 1.21
 require (
 	github.com/google/uuid v1.4.0
 	github.com/gorilla/mux v1.8.1
+	golang.org/x/net v0.35.0
+	golang.org/x/text v0.22.0
 )
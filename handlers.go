@@ -1,30 +1,99 @@
 package main
 
 import (
+	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"net/http"
+	"net/url"
 	"os"
 	"path/filepath"
+	"strconv"
 	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
 
 	"github.com/google/uuid"
 	"github.com/gorilla/mux"
 )
 
+const (
+	// defaultQueueSize is the maximum number of jobs waiting for a worker
+	defaultQueueSize = 100
+
+	// queueRetryAfter is the Retry-After hint, in seconds, sent when the queue is full
+	queueRetryAfter = 30
+
+	// cancelledJobError is recorded on jobs cancelled by a client
+	cancelledJobError = "Job was cancelled"
+
+	// shutdownJobError is recorded on jobs stopped because the server shut down
+	shutdownJobError = "Job interrupted by server shutdown"
+
+	// shutdownGracePeriod is how long interrupted jobs get to clean up after the shutdown deadline
+	shutdownGracePeriod = 5 * time.Second
+
+	// maxFormFieldBytes caps the combined size of the non-file form fields in an upload
+	maxFormFieldBytes = 64 << 10
+
+	// defaultMaxValidateBatch is the most addresses accepted by one validate request
+	defaultMaxValidateBatch = 100
+
+	// maxValidateBodyBytes caps the size of a validate request body
+	maxValidateBodyBytes = 1 << 20
+)
+
+var (
+	// errJobNotFound is returned when a job ID is unknown
+	errJobNotFound = errors.New("job not found")
+
+	// errJobFinished is returned when cancelling a job that has already finished
+	errJobFinished = errors.New("job has already finished")
+)
+
 // App represents the main application
 type App struct {
-	jobStore     *JobStore
+	config       *Config
+	jobStore     JobStore
 	csvProcessor *CSVProcessor
+	queue        *JobQueue
+
+	// running holds cancel functions for jobs currently being processed
+	running map[string]context.CancelFunc
+	mu      sync.Mutex
+
+	// draining is set once the server starts shutting down
+	draining atomic.Bool
 }
 
-// NewApp creates a new application instance
+// NewApp creates a new application instance with the default configuration,
+// backed by an in-memory job store
 func NewApp() *App {
-	return &App{
-		jobStore:     NewJobStore(),
-		csvProcessor: NewCSVProcessor(),
+	return newApp(DefaultConfig(), NewJobStore(), NewCSVProcessor())
+}
+
+// NewAppWithConfig creates a new application instance using cfg and the given job store
+func NewAppWithConfig(cfg *Config, store JobStore) (*App, error) {
+	csvProcessor, err := NewCSVProcessorWithConfig(cfg)
+	if err != nil {
+		return nil, err
 	}
+	return newApp(cfg, store, csvProcessor), nil
+}
+
+// newApp wires an application together and starts its workers
+func newApp(cfg *Config, store JobStore, csvProcessor *CSVProcessor) *App {
+	app := &App{
+		config:       cfg,
+		jobStore:     store,
+		csvProcessor: csvProcessor,
+		running:      make(map[string]context.CancelFunc),
+	}
+	app.queue = NewJobQueue(cfg.Workers, cfg.QueueSize, app.processTask)
+	return app
 }
 
 // UploadHandler handles file upload requests
@@ -32,50 +101,445 @@ func (app *App) UploadHandler(w http.ResponseWriter, r *http.Request) {
 	// Set content type
 	w.Header().Set("Content-Type", "application/json")
 
-	// Parse multipart form
-	err := r.ParseMultipartForm(10 << 20) // 10 MB max file size
+	// Refuse new work while shutting down
+	if app.draining.Load() {
+		app.sendShuttingDownResponse(w)
+		return
+	}
+
+	// Fail fast before reading the body if no worker can take the job
+	if app.queue.IsFull() {
+		app.sendQueueFullResponse(w)
+		return
+	}
+
+	// Stream the upload straight to storage, rejecting bodies over the configured limit
+	if app.config.MaxUploadSize > 0 {
+		r.Body = http.MaxBytesReader(w, r.Body, app.config.MaxUploadSize)
+	}
+
+	// Generate unique job ID
+	jobID := uuid.New().String()
+
+	upload, err := app.receiveUpload(r, jobID)
 	if err != nil {
-		app.sendErrorResponse(w, http.StatusBadRequest, "Failed to parse multipart form")
+		var uploadErr *uploadError
+		if errors.As(err, &uploadErr) {
+			app.sendErrorResponse(w, uploadErr.status, uploadErr.message)
+			return
+		}
+		app.sendErrorResponse(w, http.StatusInternalServerError, "Failed to save uploaded file")
 		return
 	}
+	uploadPath := upload.Path
 
-	// Get the file from form data
-	file, handler, err := r.FormFile("file")
+	// Options may be given as query parameters or form fields
+	opts, err := parseProcessOptions(upload.Fields, r.URL.Query())
 	if err != nil {
-		app.sendErrorResponse(w, http.StatusBadRequest, "No file provided")
+		os.Remove(uploadPath)
+		app.sendErrorResponse(w, http.StatusBadRequest, fmt.Sprintf("Invalid processing options: %v", err))
+		return
+	}
+
+	// Uploads may lower the malformed row threshold but not raise it
+	if limit := app.config.MaxRowErrors; limit > 0 && (opts.MaxErrors == 0 || opts.MaxErrors > limit) {
+		opts.MaxErrors = limit
+	}
+
+	if opts.VerifyMailbox && !app.csvProcessor.CanVerifyMailbox() {
+		os.Remove(uploadPath)
+		app.sendErrorResponse(w, http.StatusBadRequest, "Mailbox verification is not enabled on this server")
 		return
 	}
-	defer file.Close()
 
-	// Validate file type
-	contentType := handler.Header.Get("Content-Type")
-	if !strings.Contains(contentType, "text/csv") && !strings.HasSuffix(strings.ToLower(handler.Filename), ".csv") {
-		app.sendErrorResponse(w, http.StatusBadRequest, "File must be a CSV file")
+	if _, err := app.csvProcessor.ResolvePolicy(opts); err != nil {
+		os.Remove(uploadPath)
+		app.sendErrorResponse(w, http.StatusBadRequest, fmt.Sprintf("Invalid processing options: %v", err))
 		return
 	}
 
-	// Read file data
-	fileData, err := io.ReadAll(file)
+	// Detect the file's encoding and dialect once, so the job reports and uses the same ones
+	encoding, err := app.csvProcessor.DetectEncoding(uploadPath, opts)
 	if err != nil {
-		app.sendErrorResponse(w, http.StatusInternalServerError, "Failed to read file")
+		os.Remove(uploadPath)
+		app.sendErrorResponse(w, http.StatusInternalServerError, "Failed to read uploaded file")
 		return
 	}
+	opts.Encoding = encoding.Name
+	dialect, err := app.csvProcessor.DetectDialect(uploadPath, opts)
+	if err != nil {
+		os.Remove(uploadPath)
+		app.sendErrorResponse(w, http.StatusInternalServerError, "Failed to read uploaded file")
+		return
+	}
+	opts.Delimiter, opts.Quote, opts.HasHeader = dialect.Delimiter, dialect.Quote, &dialect.HasHeader
 
-	// Generate unique job ID
-	jobID := uuid.New().String()
+	// Reject unknown target columns now rather than failing the job later
+	if len(opts.Columns) > 0 || len(opts.ColumnIndexes) > 0 || opts.OnCollision == CollisionFail {
+		if err := app.checkColumns(uploadPath, opts, encoding, dialect); err != nil {
+			os.Remove(uploadPath)
+			var columnErr *ColumnNotFoundError
+			var collisionErr *ColumnCollisionError
+			switch {
+			case errors.As(err, &columnErr):
+				app.sendColumnNotFoundResponse(w, columnErr)
+			case errors.As(err, &collisionErr):
+				app.sendErrorResponse(w, http.StatusBadRequest, fmt.Sprintf("Invalid processing options: %v", err))
+			case errors.Is(err, errColumnsNeedHeader):
+				app.sendErrorResponse(w, http.StatusBadRequest, fmt.Sprintf("Invalid processing options: %v", err))
+			default:
+				app.sendErrorResponse(w, http.StatusBadRequest, fmt.Sprintf("Failed to read CSV header: %v", err))
+			}
+			return
+		}
+	}
 
 	// Create job
 	app.jobStore.CreateJob(jobID)
+	app.jobStore.RecordUpload(jobID, UploadInfo{
+		FileName: upload.FileName,
+		Size:     upload.Size,
+		SHA256:   upload.SHA256,
+		Encoding: &encoding,
+		Dialect:  &dialect,
+	})
 
-	// Process file asynchronously
-	go app.processFileAsync(jobID, fileData, handler.Filename)
+	// Queue file for processing; the client never learns the ID of a job
+	// that could not be queued, so nothing of it is kept
+	if err := app.queue.Enqueue(processingTask{JobID: jobID, UploadPath: uploadPath, Options: opts}); err != nil {
+		os.Remove(uploadPath)
+		app.jobStore.DeleteJob(jobID)
+		if errors.Is(err, ErrQueueClosed) {
+			app.sendShuttingDownResponse(w)
+			return
+		}
+		app.sendQueueFullResponse(w)
+		return
+	}
 
 	// Send response with job ID
-	response := UploadResponse{ID: jobID}
+	response := UploadResponse{ID: jobID, Size: upload.Size, SHA256: upload.SHA256}
 	w.WriteHeader(http.StatusOK)
 	json.NewEncoder(w).Encode(response)
 }
 
+// ValidateHandler validates addresses synchronously. The body is either a
+// JSON string, answered with a single result, or a JSON array of strings,
+// answered with a result per address in the same order. The upload options
+// that affect validation, such as policy and strip_tags, are read from the
+// query string.
+func (app *App) ValidateHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	opts, err := parseProcessOptions(r.URL.Query())
+	if err != nil {
+		app.sendErrorResponse(w, http.StatusBadRequest, fmt.Sprintf("Invalid processing options: %v", err))
+		return
+	}
+	policy, err := app.csvProcessor.ResolvePolicy(opts)
+	if err != nil {
+		app.sendErrorResponse(w, http.StatusBadRequest, fmt.Sprintf("Invalid processing options: %v", err))
+		return
+	}
+
+	r.Body = http.MaxBytesReader(w, r.Body, maxValidateBodyBytes)
+	var body json.RawMessage
+	decoder := json.NewDecoder(r.Body)
+	err = decoder.Decode(&body)
+	if err == nil {
+		// The body must hold a single value and nothing after it
+		var trailing json.RawMessage
+		if trailingErr := decoder.Decode(&trailing); trailingErr != io.EOF {
+			err = trailingErr
+			if err == nil {
+				err = errors.New("unexpected data after JSON value")
+			}
+		}
+	}
+	if err != nil {
+		var tooLarge *http.MaxBytesError
+		if errors.As(err, &tooLarge) {
+			app.sendErrorResponse(w, http.StatusRequestEntityTooLarge, "Request body is too large")
+			return
+		}
+		app.sendErrorResponse(w, http.StatusBadRequest, "Request body must be a JSON string or array of strings")
+		return
+	}
+	if string(body) == "null" {
+		app.sendErrorResponse(w, http.StatusBadRequest, "Request body must be a JSON string or array of strings")
+		return
+	}
+
+	var emails []string
+	batch := len(body) > 0 && body[0] == '['
+	if batch {
+		err = json.Unmarshal(body, &emails)
+	} else {
+		emails = make([]string, 1)
+		err = json.Unmarshal(body, &emails[0])
+	}
+	if err != nil {
+		app.sendErrorResponse(w, http.StatusBadRequest, "Request body must be a JSON string or array of strings")
+		return
+	}
+	if len(emails) > app.config.MaxValidateBatch {
+		app.sendErrorResponse(w, http.StatusBadRequest, fmt.Sprintf("At most %d addresses may be validated per request", app.config.MaxValidateBatch))
+		return
+	}
+
+	validator := app.csvProcessor.validator
+	if policy != nil {
+		validator = validator.WithPolicy(policy)
+	}
+	normalizer := NewEmailNormalizer(validator, NormalizeOptions{StripTags: opts.StripTags})
+
+	results := make([]EmailValidationResult, len(emails))
+	for i, email := range emails {
+		result := validator.ValidateContext(r.Context(), email)
+		results[i] = EmailValidationResult{
+			Email:            email,
+			ValidationResult: result,
+			Suggestion:       validator.Suggest(email),
+		}
+		if result.Valid {
+			results[i].Normalized = normalizer.Normalize(email)
+		}
+	}
+
+	w.WriteHeader(http.StatusOK)
+	if batch {
+		json.NewEncoder(w).Encode(ValidateResponse{Results: results})
+		return
+	}
+	json.NewEncoder(w).Encode(results[0])
+}
+
+// uploadError is a client error detected while receiving an upload
+type uploadError struct {
+	status  int
+	message string
+}
+
+func (e *uploadError) Error() string {
+	return e.message
+}
+
+// receivedUpload is an uploaded CSV file that has been written to storage,
+// together with the other form fields sent alongside it
+type receivedUpload struct {
+	*StoredFile
+	FileName string
+	Fields   url.Values
+}
+
+// receiveUpload reads the multipart body part by part and streams the "file"
+// part to storage without buffering it in memory
+func (app *App) receiveUpload(r *http.Request, jobID string) (*receivedUpload, error) {
+	reader, err := r.MultipartReader()
+	if err != nil {
+		return nil, &uploadError{http.StatusBadRequest, "Failed to parse multipart form"}
+	}
+
+	var upload *receivedUpload
+	fields := url.Values{}
+	fieldBytes := 0
+	for {
+		part, err := reader.NextPart()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			if upload != nil {
+				os.Remove(upload.Path)
+			}
+			return nil, app.classifyUploadError(err, http.StatusBadRequest, "Failed to parse multipart form")
+		}
+
+		// Plain form fields carry processing options
+		if part.FileName() == "" && part.FormName() != "" {
+			value, err := io.ReadAll(io.LimitReader(part, int64(maxFormFieldBytes-fieldBytes+1)))
+			part.Close()
+			if err != nil {
+				if upload != nil {
+					os.Remove(upload.Path)
+				}
+				return nil, app.classifyUploadError(err, http.StatusBadRequest, "Failed to parse multipart form")
+			}
+			fieldBytes += len(value)
+			if fieldBytes > maxFormFieldBytes {
+				if upload != nil {
+					os.Remove(upload.Path)
+				}
+				return nil, &uploadError{http.StatusBadRequest, "Form fields are too large"}
+			}
+			fields.Add(part.FormName(), string(value))
+			continue
+		}
+
+		// Only the first file part is stored; other parts are skipped
+		if part.FormName() != "file" || upload != nil {
+			io.Copy(io.Discard, part)
+			part.Close()
+			continue
+		}
+
+		// Validate file type
+		filename := part.FileName()
+		contentType := part.Header.Get("Content-Type")
+		if !strings.Contains(contentType, "text/csv") && !strings.HasSuffix(strings.ToLower(filename), ".csv") {
+			part.Close()
+			return nil, &uploadError{http.StatusBadRequest, "File must be a CSV file"}
+		}
+
+		stored, err := app.csvProcessor.SaveUploadedFile(part, fmt.Sprintf("%s%s_%s", uploadFilePrefix, jobID, filename))
+		part.Close()
+		if err != nil {
+			return nil, app.classifyUploadError(err, http.StatusInternalServerError, "Failed to save uploaded file")
+		}
+		upload = &receivedUpload{StoredFile: stored, FileName: filename}
+	}
+
+	if upload == nil {
+		return nil, &uploadError{http.StatusBadRequest, "No file provided"}
+	}
+	upload.Fields = fields
+	return upload, nil
+}
+
+// parseProcessOptions reads processing options from the given parameter
+// sets; values in earlier sets take precedence
+func parseProcessOptions(params ...url.Values) (ProcessOptions, error) {
+	get := func(name string) string {
+		for _, values := range params {
+			if value := values.Get(name); value != "" {
+				return value
+			}
+		}
+		return ""
+	}
+
+	var opts ProcessOptions
+	output, err := ParseOutputMode(get("output"))
+	if err != nil {
+		return opts, err
+	}
+	opts.Output = output
+
+	if opts.Normalize, err = ParseNormalizeMode(get("normalize")); err != nil {
+		return opts, err
+	}
+	if opts.Extract, err = ParseExtractMode(get("extract")); err != nil {
+		return opts, err
+	}
+
+	if opts.Delimiter, err = ParseDelimiter(get("delimiter")); err != nil {
+		return opts, err
+	}
+	if opts.Quote, err = ParseQuote(get("quote")); err != nil {
+		return opts, err
+	}
+	if opts.Encoding, err = ParseEncoding(get("encoding")); err != nil {
+		return opts, err
+	}
+	if opts.OnCollision, err = ParseCollisionMode(get("on_collision")); err != nil {
+		return opts, err
+	}
+	if opts.Lenient, err = ParseLenientMode(get("lenient")); err != nil {
+		return opts, err
+	}
+	if value := get("max_errors"); value != "" {
+		if opts.MaxErrors, err = strconv.Atoi(value); err != nil || opts.MaxErrors < 1 {
+			return opts, fmt.Errorf("max_errors must be a positive integer, got %q", value)
+		}
+	}
+	opts.OutputColumn = strings.TrimSpace(get("output_column"))
+	if value := get("has_header"); value != "" {
+		hasHeader, err := strconv.ParseBool(value)
+		if err != nil {
+			return opts, fmt.Errorf("has_header must be true or false, got %q", value)
+		}
+		opts.HasHeader = &hasHeader
+	}
+
+	opts.Policy = get("policy")
+	opts.AllowDomains = getList(params, "allow_domains")
+	opts.DenyDomains = getList(params, "deny_domains")
+
+	// Column lists may be repeated or comma separated
+	opts.Columns = getList(params, "columns")
+	for _, value := range getList(params, "column_index") {
+		index, err := strconv.Atoi(value)
+		if err != nil || index < 0 {
+			return opts, fmt.Errorf("column_index must be a non-negative integer, got %q", value)
+		}
+		opts.ColumnIndexes = append(opts.ColumnIndexes, index)
+	}
+
+	flags := map[string]*bool{
+		"reasons":               &opts.Reasons,
+		"ascii":                 &opts.ASCII,
+		"providers":             &opts.Providers,
+		"role_accounts":         &opts.RoleAccounts,
+		"exclude_role_accounts": &opts.ExcludeRoleAccounts,
+		"suggest":               &opts.Suggestions,
+		"strip_tags":            &opts.StripTags,
+		"verify_mailbox":        &opts.VerifyMailbox,
+		"keep_encoding":         &opts.KeepEncoding,
+	}
+	for name, target := range flags {
+		if value := get(name); value != "" {
+			parsed, err := strconv.ParseBool(value)
+			if err != nil {
+				return opts, fmt.Errorf("%s must be true or false, got %q", name, value)
+			}
+			*target = parsed
+		}
+	}
+
+	return opts, nil
+}
+
+// getList returns the comma separated values of name from the first
+// parameter set that has any
+func getList(params []url.Values, name string) []string {
+	for _, values := range params {
+		if len(values[name]) == 0 {
+			continue
+		}
+
+		var list []string
+		for _, value := range values[name] {
+			for _, item := range strings.Split(value, ",") {
+				if item = strings.TrimSpace(item); item != "" {
+					list = append(list, item)
+				}
+			}
+		}
+		return list
+	}
+	return nil
+}
+
+// checkColumns verifies that the columns requested in opts exist in the
+// first row of the uploaded file and that the output columns can be added
+func (app *App) checkColumns(uploadPath string, opts ProcessOptions, encoding TextEncoding, dialect CSVDialect) error {
+	first, err := app.csvProcessor.ReadHeader(uploadPath, encoding, dialect)
+	if err != nil {
+		return err
+	}
+	_, _, _, err = planColumns(first, dialect.HasHeader, opts, app.csvProcessor.extraColumns(opts, nil))
+	return err
+}
+
+// classifyUploadError maps errors raised while reading the body to a client-facing error
+func (app *App) classifyUploadError(err error, status int, message string) error {
+	var maxBytesErr *http.MaxBytesError
+	if errors.As(err, &maxBytesErr) {
+		return &uploadError{http.StatusRequestEntityTooLarge, fmt.Sprintf("File exceeds maximum upload size of %d bytes", maxBytesErr.Limit)}
+	}
+	return &uploadError{status, message}
+}
+
 // DownloadHandler handles file download requests
 func (app *App) DownloadHandler(w http.ResponseWriter, r *http.Request) {
 	vars := mux.Vars(r)
@@ -90,12 +554,18 @@ func (app *App) DownloadHandler(w http.ResponseWriter, r *http.Request) {
 
 	// Check job status
 	switch job.Status {
-	case JobStatusProcessing:
+	case JobStatusQueued, JobStatusProcessing:
 		w.WriteHeader(http.StatusLocked) // 423
 		return
 	case JobStatusFailed:
 		app.sendErrorResponse(w, http.StatusInternalServerError, job.Error)
 		return
+	case JobStatusCancelled:
+		app.sendErrorResponse(w, http.StatusGone, cancelledJobError)
+		return
+	case JobStatusExpired:
+		app.sendErrorResponse(w, http.StatusGone, expiredJobError)
+		return
 	case JobStatusCompleted:
 		// Serve the processed file
 		app.serveFile(w, job.FilePath)
@@ -106,20 +576,127 @@ func (app *App) DownloadHandler(w http.ResponseWriter, r *http.Request) {
 	}
 }
 
-// processFileAsync processes the uploaded file asynchronously
-func (app *App) processFileAsync(jobID string, fileData []byte, filename string) {
-	// Save uploaded file
-	uploadPath, err := app.csvProcessor.SaveUploadedFile(fileData, fmt.Sprintf("upload_%s_%s", jobID, filename))
-	if err != nil {
-		app.jobStore.UpdateJobStatus(jobID, JobStatusFailed, "", fmt.Sprintf("Failed to save uploaded file: %v", err))
+// ErrorReportHandler serves the malformed row report of a lenient job
+func (app *App) ErrorReportHandler(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	jobID := vars["id"]
+
+	job, exists := app.jobStore.GetJob(jobID)
+	if !exists {
+		app.sendErrorResponse(w, http.StatusBadRequest, "Invalid job ID")
+		return
+	}
+
+	switch job.Status {
+	case JobStatusQueued, JobStatusProcessing:
+		w.WriteHeader(http.StatusLocked) // 423
+	case JobStatusCancelled:
+		app.sendErrorResponse(w, http.StatusGone, cancelledJobError)
+	case JobStatusExpired:
+		app.sendErrorResponse(w, http.StatusGone, expiredJobError)
+	default:
+		// Failed jobs keep their report, as it shows why too many rows were malformed
+		reportPath := app.csvProcessor.GetErrorReportPath(jobID)
+		if _, err := os.Stat(reportPath); err != nil {
+			app.sendErrorResponse(w, http.StatusNotFound, "No error report for this job")
+			return
+		}
+		app.serveFile(w, reportPath)
+	}
+}
+
+// JobStatusHandler returns the current state and progress of a job
+func (app *App) JobStatusHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	vars := mux.Vars(r)
+	jobID := vars["id"]
+
+	job, exists := app.jobStore.GetJob(jobID)
+	if !exists {
+		app.sendErrorResponse(w, http.StatusBadRequest, "Invalid job ID")
 		return
 	}
 
-	// Generate processed file path
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(job)
+}
+
+// CancelJobHandler cancels a queued or running job
+func (app *App) CancelJobHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	vars := mux.Vars(r)
+	jobID := vars["id"]
+
+	job, err := app.cancelJob(jobID)
+	switch {
+	case errors.Is(err, errJobNotFound):
+		app.sendErrorResponse(w, http.StatusBadRequest, "Invalid job ID")
+		return
+	case errors.Is(err, errJobFinished):
+		app.sendErrorResponse(w, http.StatusConflict, fmt.Sprintf("Job has already finished with status %s", job.Status))
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(job)
+}
+
+// QueueStatusHandler reports the current depth and capacity of the job queue
+func (app *App) QueueStatusHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(app.queue.Stats())
+}
+
+// processTask is run by queue workers for each queued upload
+func (app *App) processTask(task processingTask) {
+	app.processFile(task.JobID, task.UploadPath, task.Options)
+}
+
+// processFile processes a saved upload and records the outcome on the job
+func (app *App) processFile(jobID string, uploadPath string, opts ProcessOptions) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	// Generate processed file and error report paths
 	processedPath := app.csvProcessor.GetProcessedFilePath(jobID)
+	reportPath := app.csvProcessor.GetErrorReportPath(jobID)
+
+	// Claim the job unless it was cancelled while waiting in the queue
+	app.mu.Lock()
+	job, exists := app.jobStore.GetJob(jobID)
+	if !exists || job.Status != JobStatusQueued {
+		app.mu.Unlock()
+		os.Remove(uploadPath)
+		return
+	}
+	app.running[jobID] = cancel
+	app.jobStore.StartJob(jobID)
+	app.mu.Unlock()
 
 	// Process CSV file
-	err = app.csvProcessor.ProcessCSV(uploadPath, processedPath)
+	opts.Progress = func(progress ProcessingProgress) {
+		app.jobStore.UpdateJobProgress(jobID, progress)
+	}
+	if opts.Lenient != LenientOff {
+		opts.ErrorReportPath = reportPath
+	}
+	err := app.csvProcessor.ProcessCSVWithOptions(ctx, uploadPath, processedPath, opts)
+
+	app.mu.Lock()
+	defer app.mu.Unlock()
+	delete(app.running, jobID)
+
+	// A cancelled or interrupted job keeps its status and leaves no files behind
+	if ctx.Err() != nil {
+		os.Remove(processedPath)
+		os.Remove(reportPath)
+		os.Remove(uploadPath)
+		return
+	}
+
 	if err != nil {
 		app.jobStore.UpdateJobStatus(jobID, JobStatusFailed, "", fmt.Sprintf("Failed to process CSV: %v", err))
 		return
@@ -129,6 +706,74 @@ func (app *App) processFileAsync(jobID string, fileData []byte, filename string)
 	app.jobStore.UpdateJobStatus(jobID, JobStatusCompleted, processedPath, "")
 }
 
+// StopAcceptingUploads makes new uploads fail with 503 while the server shuts down
+func (app *App) StopAcceptingUploads() {
+	app.draining.Store(true)
+}
+
+// Shutdown stops accepting uploads, fails jobs that have not started and
+// waits for running jobs until ctx is done. Jobs still running at the
+// deadline are interrupted and marked as failed.
+func (app *App) Shutdown(ctx context.Context) error {
+	app.StopAcceptingUploads()
+
+	err := app.queue.Shutdown(ctx, func(task processingTask) {
+		os.Remove(task.UploadPath)
+		app.mu.Lock()
+		app.failInterruptedJob(task.JobID)
+		app.mu.Unlock()
+	})
+	if err == nil {
+		return nil
+	}
+
+	// Deadline passed: interrupt whatever is still running
+	app.mu.Lock()
+	for jobID, cancel := range app.running {
+		app.failInterruptedJob(jobID)
+		cancel()
+	}
+	app.mu.Unlock()
+
+	// Give interrupted jobs a moment to remove their partial output
+	graceCtx, cancel := context.WithTimeout(context.Background(), shutdownGracePeriod)
+	defer cancel()
+	app.queue.Wait(graceCtx)
+
+	return err
+}
+
+// failInterruptedJob marks a job stopped by shutdown as failed, leaving jobs
+// that already finished, such as cancelled ones, as they are. app.mu must be held.
+func (app *App) failInterruptedJob(jobID string) {
+	if job, exists := app.jobStore.GetJob(jobID); exists && !job.IsFinished() {
+		app.jobStore.UpdateJobStatus(jobID, JobStatusFailed, "", shutdownJobError)
+	}
+}
+
+// cancelJob marks a queued or running job as cancelled and stops its processing
+func (app *App) cancelJob(jobID string) (*ProcessingJob, error) {
+	app.mu.Lock()
+	defer app.mu.Unlock()
+
+	job, exists := app.jobStore.GetJob(jobID)
+	if !exists {
+		return nil, errJobNotFound
+	}
+	if job.IsFinished() {
+		return job, errJobFinished
+	}
+
+	// Running jobs stop at the next row; queued jobs are skipped by the worker
+	if cancel, running := app.running[jobID]; running {
+		cancel()
+	}
+	app.jobStore.UpdateJobStatus(jobID, JobStatusCancelled, "", cancelledJobError)
+
+	job, _ = app.jobStore.GetJob(jobID)
+	return job, nil
+}
+
 // serveFile serves a file as a blob
 func (app *App) serveFile(w http.ResponseWriter, filePath string) {
 	// Set appropriate headers
@@ -151,6 +796,34 @@ func (app *App) serveFile(w http.ResponseWriter, filePath string) {
 	}
 }
 
+// sendQueueFullResponse tells the client to retry once the queue has drained
+func (app *App) sendQueueFullResponse(w http.ResponseWriter) {
+	w.Header().Set("Retry-After", strconv.Itoa(queueRetryAfter))
+	app.sendErrorResponse(w, http.StatusServiceUnavailable, "Server is busy, please retry later")
+}
+
+// sendShuttingDownResponse tells the client the server is going away
+func (app *App) sendShuttingDownResponse(w http.ResponseWriter) {
+	w.Header().Set("Retry-After", strconv.Itoa(queueRetryAfter))
+	app.sendErrorResponse(w, http.StatusServiceUnavailable, "Server is shutting down")
+}
+
+// sendColumnNotFoundResponse reports an unknown target column along with the headers that do exist
+func (app *App) sendColumnNotFoundResponse(w http.ResponseWriter, err *ColumnNotFoundError) {
+	message := fmt.Sprintf("Column index %d does not exist", err.Index)
+	if err.Name != "" {
+		message = fmt.Sprintf("Column %q does not exist", err.Name)
+	}
+
+	headers := err.Headers
+	if headers == nil {
+		headers = []string{}
+	}
+
+	w.WriteHeader(http.StatusBadRequest)
+	json.NewEncoder(w).Encode(ErrorResponse{Error: message, AvailableColumns: headers})
+}
+
 // sendErrorResponse sends an error response
 func (app *App) sendErrorResponse(w http.ResponseWriter, statusCode int, message string) {
 	w.WriteHeader(statusCode)
@@ -2,11 +2,15 @@ package main
 
 import (
 	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
 	"encoding/json"
 	"fmt"
 	"mime/multipart"
 	"net/http"
 	"net/http/httptest"
+	"net/url"
 	"os"
 	"path/filepath"
 	"strings"
@@ -14,6 +18,7 @@ import (
 	"time"
 
 	"github.com/gorilla/mux"
+	"golang.org/x/text/encoding/unicode"
 )
 
 func TestNewApp(t *testing.T) {
@@ -29,8 +34,20 @@ func TestNewApp(t *testing.T) {
 	}
 }
 
+// newTestApp creates an app that keeps its files in a temporary directory
+func newTestApp(t *testing.T) *App {
+	t.Helper()
+	cfg := DefaultConfig()
+	cfg.StorageDir = t.TempDir()
+	app, err := NewAppWithConfig(cfg, NewJobStore())
+	if err != nil {
+		t.Fatalf("NewAppWithConfig failed: %v", err)
+	}
+	return app
+}
+
 func TestUploadHandler(t *testing.T) {
-	app := NewApp()
+	app := newTestApp(t)
 
 	tests := []struct {
 		name           string
@@ -127,7 +144,7 @@ func TestUploadHandler(t *testing.T) {
 }
 
 func TestDownloadHandler(t *testing.T) {
-	app := NewApp()
+	app := newTestApp(t)
 
 	// Create a test job
 	jobID := "test-job-123"
@@ -150,6 +167,15 @@ func TestDownloadHandler(t *testing.T) {
 		expectedStatus int
 		expectFile     bool
 	}{
+		{
+			name:           "Job queued",
+			jobID:          jobID,
+			jobStatus:      JobStatusQueued,
+			filePath:       "",
+			errorMsg:       "",
+			expectedStatus: http.StatusLocked, // 423
+			expectFile:     false,
+		},
 		{
 			name:           "Job still processing",
 			jobID:          jobID,
@@ -177,6 +203,24 @@ func TestDownloadHandler(t *testing.T) {
 			expectedStatus: http.StatusInternalServerError,
 			expectFile:     false,
 		},
+		{
+			name:           "Job cancelled",
+			jobID:          jobID,
+			jobStatus:      JobStatusCancelled,
+			filePath:       "",
+			errorMsg:       "",
+			expectedStatus: http.StatusGone,
+			expectFile:     false,
+		},
+		{
+			name:           "Job expired",
+			jobID:          jobID,
+			jobStatus:      JobStatusExpired,
+			filePath:       "",
+			errorMsg:       "",
+			expectedStatus: http.StatusGone,
+			expectFile:     false,
+		},
 		{
 			name:           "Invalid job ID",
 			jobID:          "invalid-job-id",
@@ -219,7 +263,7 @@ func TestDownloadHandler(t *testing.T) {
 				if !strings.Contains(contentDisposition, "attachment") {
 					t.Errorf("Expected Content-Disposition with attachment, got %s", contentDisposition)
 				}
-			} else if tt.expectedStatus == http.StatusInternalServerError || tt.expectedStatus == http.StatusBadRequest {
+			} else if tt.expectedStatus == http.StatusInternalServerError || tt.expectedStatus == http.StatusBadRequest || tt.expectedStatus == http.StatusGone {
 				// Should have error response
 				var response map[string]interface{}
 				err := json.Unmarshal(w.Body.Bytes(), &response)
@@ -234,30 +278,23 @@ func TestDownloadHandler(t *testing.T) {
 	}
 }
 
-func TestProcessFileAsync(t *testing.T) {
-	app := NewApp()
-
-	// Create temporary directory for testing
-	tempDir := t.TempDir()
-	originalDir, _ := os.Getwd()
-	os.Chdir(tempDir)
-	defer os.Chdir(originalDir)
-
-	// Create uploads directory
-	os.MkdirAll("uploads", 0755)
+func TestProcessFile(t *testing.T) {
+	app := newTestApp(t)
 
 	jobID := "test-async-job"
 	fileData := []byte("name,email\nJohn Doe,john@example.com")
 	filename := "test.csv"
 
-	// Create job
+	// Save upload and create job
+	stored, err := app.csvProcessor.SaveUploadedFile(bytes.NewReader(fileData), filename)
+	if err != nil {
+		t.Fatalf("SaveUploadedFile failed: %v", err)
+	}
+	uploadPath := stored.Path
 	app.jobStore.CreateJob(jobID)
 
-	// Process file asynchronously
-	app.processFileAsync(jobID, fileData, filename)
-
-	// Wait a bit for processing to complete
-	time.Sleep(100 * time.Millisecond)
+	// Process file
+	app.processFile(jobID, uploadPath, ProcessOptions{})
 
 	// Check job status
 	job, exists := app.jobStore.GetJob(jobID)
@@ -273,14 +310,82 @@ func TestProcessFileAsync(t *testing.T) {
 		t.Error("Job should have file path")
 	}
 
+	if job.RowsProcessed != 1 || job.RowsWithEmail != 1 {
+		t.Errorf("Expected 1 row processed with email, got %d processed and %d with email", job.RowsProcessed, job.RowsWithEmail)
+	}
+
 	// Verify processed file exists
 	if _, err := os.Stat(job.FilePath); os.IsNotExist(err) {
 		t.Error("Processed file should exist")
 	}
 }
 
+func TestErrorReportHandler(t *testing.T) {
+	cfg := DefaultConfig()
+	cfg.StorageDir = t.TempDir()
+
+	app, err := NewAppWithConfig(cfg, NewJobStore())
+	if err != nil {
+		t.Fatalf("NewAppWithConfig failed: %v", err)
+	}
+
+	fileData := []byte("name,email\nJohn,john@example.com\nJane\nBob,\"bob\"@example.com\n")
+	run := func(jobID string, opts ProcessOptions) {
+		stored, err := app.csvProcessor.SaveUploadedFile(bytes.NewReader(fileData), "test.csv")
+		if err != nil {
+			t.Fatalf("SaveUploadedFile failed: %v", err)
+		}
+		app.jobStore.CreateJob(jobID)
+		app.processFile(jobID, stored.Path, opts)
+	}
+	run("lenient", ProcessOptions{Lenient: LenientSkip})
+	run("too-many-errors", ProcessOptions{Lenient: LenientSkip, MaxErrors: 1})
+	run("strict", ProcessOptions{})
+	app.jobStore.CreateJob("queued")
+
+	tests := []struct {
+		name           string
+		jobID          string
+		expectedJob    JobStatus
+		expectedStatus int
+		expectedErrors int64
+	}{
+		{"Lenient job", "lenient", JobStatusCompleted, http.StatusOK, 2},
+		{"Threshold exceeded", "too-many-errors", JobStatusFailed, http.StatusOK, 2},
+		{"Strict job", "strict", JobStatusFailed, http.StatusNotFound, 0},
+		{"Job queued", "queued", JobStatusQueued, http.StatusLocked, 0},
+		{"Invalid job ID", "invalid-job-id", "", http.StatusBadRequest, 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if job, exists := app.jobStore.GetJob(tt.jobID); exists {
+				if job.Status != tt.expectedJob {
+					t.Errorf("Job status mismatch. Expected: %s, Got: %s (%s)", tt.expectedJob, job.Status, job.Error)
+				}
+				if job.RowErrors != tt.expectedErrors {
+					t.Errorf("Row errors mismatch. Expected: %d, Got: %d", tt.expectedErrors, job.RowErrors)
+				}
+			}
+
+			req := httptest.NewRequest("GET", fmt.Sprintf("/API/errors/%s", tt.jobID), nil)
+			req = mux.SetURLVars(req, map[string]string{"id": tt.jobID})
+			w := httptest.NewRecorder()
+
+			app.ErrorReportHandler(w, req)
+
+			if w.Code != tt.expectedStatus {
+				t.Fatalf("Expected status %d, got %d: %s", tt.expectedStatus, w.Code, w.Body.String())
+			}
+			if tt.expectedStatus == http.StatusOK && !strings.HasPrefix(w.Body.String(), "line,byte_offset,raw,error\n3,33,Jane,") {
+				t.Errorf("Unexpected error report:\n%s", w.Body.String())
+			}
+		})
+	}
+}
+
 func TestServeFile(t *testing.T) {
-	app := NewApp()
+	app := newTestApp(t)
 
 	// Create temporary file
 	tempFile := filepath.Join(t.TempDir(), "test.csv")
@@ -320,7 +425,7 @@ func TestServeFile(t *testing.T) {
 }
 
 func TestServeFileError(t *testing.T) {
-	app := NewApp()
+	app := newTestApp(t)
 
 	// Create response recorder
 	w := httptest.NewRecorder()
@@ -345,7 +450,7 @@ func TestServeFileError(t *testing.T) {
 }
 
 func TestSendErrorResponse(t *testing.T) {
-	app := NewApp()
+	app := newTestApp(t)
 
 	// Create response recorder
 	w := httptest.NewRecorder()
@@ -371,7 +476,7 @@ func TestSendErrorResponse(t *testing.T) {
 }
 
 func TestUploadHandlerLargeFile(t *testing.T) {
-	app := NewApp()
+	app := newTestApp(t)
 
 	// Create a large CSV content (but still under 10MB limit)
 	largeContent := "name,email\n"
@@ -414,7 +519,7 @@ func TestUploadHandlerLargeFile(t *testing.T) {
 }
 
 func TestUploadHandlerInvalidMultipart(t *testing.T) {
-	app := NewApp()
+	app := newTestApp(t)
 
 	// Create request with invalid multipart data
 	req := httptest.NewRequest("POST", "/API/upload", strings.NewReader("invalid multipart data"))
@@ -441,7 +546,7 @@ func TestUploadHandlerInvalidMultipart(t *testing.T) {
 }
 
 func TestHandlersConcurrency(t *testing.T) {
-	app := NewApp()
+	app := newTestApp(t)
 
 	// Test concurrent uploads
 	done := make(chan bool, 5)
@@ -481,3 +586,961 @@ func TestHandlersConcurrency(t *testing.T) {
 		<-done
 	}
 }
+
+func TestJobStatusHandler(t *testing.T) {
+	app := newTestApp(t)
+
+	jobID := "test-status-job"
+	app.jobStore.CreateJob(jobID)
+	app.jobStore.StartJob(jobID)
+	app.jobStore.UpdateJobProgress(jobID, ProcessingProgress{
+		RowsProcessed: 4,
+		RowsWithEmail: 3,
+		BytesRead:     50,
+		TotalBytes:    100,
+	})
+
+	tests := []struct {
+		name           string
+		jobID          string
+		expectedStatus int
+	}{
+		{"Existing job", jobID, http.StatusOK},
+		{"Invalid job ID", "invalid-job-id", http.StatusBadRequest},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest("GET", fmt.Sprintf("/API/jobs/%s", tt.jobID), nil)
+			req = mux.SetURLVars(req, map[string]string{"id": tt.jobID})
+			w := httptest.NewRecorder()
+
+			app.JobStatusHandler(w, req)
+
+			if w.Code != tt.expectedStatus {
+				t.Errorf("Expected status %d, got %d", tt.expectedStatus, w.Code)
+			}
+
+			var response map[string]interface{}
+			if err := json.Unmarshal(w.Body.Bytes(), &response); err != nil {
+				t.Fatalf("Failed to unmarshal response: %v", err)
+			}
+
+			if tt.expectedStatus != http.StatusOK {
+				if _, exists := response["error"]; !exists {
+					t.Error("Expected error in response")
+				}
+				return
+			}
+
+			if response["status"] != string(JobStatusProcessing) {
+				t.Errorf("Expected status processing, got %v", response["status"])
+			}
+			if response["rows_processed"] != float64(4) {
+				t.Errorf("Expected rows_processed 4, got %v", response["rows_processed"])
+			}
+			if response["rows_with_email"] != float64(3) {
+				t.Errorf("Expected rows_with_email 3, got %v", response["rows_with_email"])
+			}
+			if response["percent_complete"] != float64(50) {
+				t.Errorf("Expected percent_complete 50, got %v", response["percent_complete"])
+			}
+			if _, exists := response["started_at"]; !exists {
+				t.Error("Expected started_at in response")
+			}
+		})
+	}
+}
+
+func TestUploadHandlerQueueFull(t *testing.T) {
+	app := newTestApp(t)
+
+	// Replace the queue with one whose only worker is blocked
+	release := make(chan struct{})
+	defer close(release)
+	app.queue = NewJobQueue(1, 1, func(task processingTask) {
+		<-release
+	})
+	app.queue.Enqueue(processingTask{JobID: "blocking-job"})
+	app.queue.Enqueue(processingTask{JobID: "waiting-job"})
+
+	var body bytes.Buffer
+	writer := multipart.NewWriter(&body)
+	part, err := writer.CreateFormFile("file", "test.csv")
+	if err != nil {
+		t.Fatalf("Failed to create form file: %v", err)
+	}
+	part.Write([]byte("name,email\nJohn Doe,john@example.com"))
+	writer.Close()
+
+	req := httptest.NewRequest("POST", "/API/upload", &body)
+	req.Header.Set("Content-Type", writer.FormDataContentType())
+	w := httptest.NewRecorder()
+
+	app.UploadHandler(w, req)
+
+	if w.Code != http.StatusServiceUnavailable {
+		t.Errorf("Expected status 503, got %d", w.Code)
+	}
+	if w.Header().Get("Retry-After") == "" {
+		t.Error("Expected Retry-After header")
+	}
+
+	var response map[string]interface{}
+	if err := json.Unmarshal(w.Body.Bytes(), &response); err != nil {
+		t.Fatalf("Failed to unmarshal response: %v", err)
+	}
+	if _, exists := response["error"]; !exists {
+		t.Error("Expected error in response")
+	}
+}
+
+func TestUploadHandlerEnqueueFails(t *testing.T) {
+	app := newTestApp(t)
+
+	// A queue that closes after the upload passed the early checks
+	app.queue = NewJobQueue(1, 1, func(task processingTask) {})
+	app.queue.Shutdown(context.Background(), func(task processingTask) {})
+
+	var body bytes.Buffer
+	writer := multipart.NewWriter(&body)
+	part, err := writer.CreateFormFile("file", "test.csv")
+	if err != nil {
+		t.Fatalf("Failed to create form file: %v", err)
+	}
+	part.Write([]byte("name,email\nJohn Doe,john@example.com"))
+	writer.Close()
+
+	req := httptest.NewRequest("POST", "/API/upload", &body)
+	req.Header.Set("Content-Type", writer.FormDataContentType())
+	w := httptest.NewRecorder()
+
+	app.UploadHandler(w, req)
+
+	if w.Code != http.StatusServiceUnavailable {
+		t.Errorf("Expected status 503, got %d", w.Code)
+	}
+
+	// The client gets no job ID, so neither the job nor its upload is kept
+	if jobs := app.jobStore.ListJobs(); len(jobs) != 0 {
+		t.Errorf("Expected no jobs after a rejected upload, got %d", len(jobs))
+	}
+	entries, err := os.ReadDir(app.config.StorageDir)
+	if err != nil {
+		t.Fatalf("Failed to read storage directory: %v", err)
+	}
+	for _, entry := range entries {
+		t.Errorf("Expected no files after a rejected upload, found %s", entry.Name())
+	}
+}
+
+func TestQueueStatusHandler(t *testing.T) {
+	app := newTestApp(t)
+
+	req := httptest.NewRequest("GET", "/API/queue", nil)
+	w := httptest.NewRecorder()
+
+	app.QueueStatusHandler(w, req)
+
+	if w.Code != http.StatusOK {
+		t.Errorf("Expected status 200, got %d", w.Code)
+	}
+
+	var stats QueueStats
+	if err := json.Unmarshal(w.Body.Bytes(), &stats); err != nil {
+		t.Fatalf("Failed to unmarshal response: %v", err)
+	}
+	if stats.Capacity != defaultQueueSize {
+		t.Errorf("Capacity mismatch. Expected: %d, Got: %d", defaultQueueSize, stats.Capacity)
+	}
+	if stats.Workers < 1 {
+		t.Errorf("Expected at least one worker, got %d", stats.Workers)
+	}
+}
+
+func TestCancelJobHandler(t *testing.T) {
+	app := newTestApp(t)
+
+	app.jobStore.CreateJob("queued-job")
+	app.jobStore.CreateJob("completed-job")
+	app.jobStore.UpdateJobStatus("completed-job", JobStatusCompleted, "/path/file.csv", "")
+
+	// Simulate a job picked up by a worker
+	app.jobStore.CreateJob("running-job")
+	app.jobStore.StartJob("running-job")
+	ctx, cancel := context.WithCancel(context.Background())
+	app.running["running-job"] = cancel
+
+	tests := []struct {
+		name           string
+		jobID          string
+		expectedStatus int
+	}{
+		{"Cancel queued job", "queued-job", http.StatusOK},
+		{"Cancel running job", "running-job", http.StatusOK},
+		{"Cancel completed job", "completed-job", http.StatusConflict},
+		{"Invalid job ID", "invalid-job-id", http.StatusBadRequest},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest("DELETE", fmt.Sprintf("/API/jobs/%s", tt.jobID), nil)
+			req = mux.SetURLVars(req, map[string]string{"id": tt.jobID})
+			w := httptest.NewRecorder()
+
+			app.CancelJobHandler(w, req)
+
+			if w.Code != tt.expectedStatus {
+				t.Errorf("Expected status %d, got %d", tt.expectedStatus, w.Code)
+			}
+
+			var response map[string]interface{}
+			if err := json.Unmarshal(w.Body.Bytes(), &response); err != nil {
+				t.Fatalf("Failed to unmarshal response: %v", err)
+			}
+
+			if tt.expectedStatus == http.StatusOK {
+				if response["status"] != string(JobStatusCancelled) {
+					t.Errorf("Expected status cancelled, got %v", response["status"])
+				}
+			} else if _, exists := response["error"]; !exists {
+				t.Error("Expected error in response")
+			}
+		})
+	}
+
+	if ctx.Err() == nil {
+		t.Error("Running job context should be cancelled")
+	}
+}
+
+func TestProcessFileCancelledWhileQueued(t *testing.T) {
+	app := newTestApp(t)
+
+	tempDir := t.TempDir()
+	uploadPath := filepath.Join(tempDir, "upload.csv")
+	if err := os.WriteFile(uploadPath, []byte("name,email\nJohn Doe,john@example.com"), 0644); err != nil {
+		t.Fatalf("Failed to write upload: %v", err)
+	}
+
+	jobID := "cancelled-job"
+	app.jobStore.CreateJob(jobID)
+	if _, err := app.cancelJob(jobID); err != nil {
+		t.Fatalf("cancelJob failed: %v", err)
+	}
+
+	// The worker should skip the job and clean up its upload
+	app.processFile(jobID, uploadPath, ProcessOptions{})
+
+	job, _ := app.jobStore.GetJob(jobID)
+	if job.Status != JobStatusCancelled {
+		t.Errorf("Expected job status cancelled, got %s", job.Status)
+	}
+	if job.StartedAt != nil {
+		t.Error("Cancelled job should never start")
+	}
+	if _, err := os.Stat(uploadPath); !os.IsNotExist(err) {
+		t.Error("Upload of cancelled job should be removed")
+	}
+}
+
+func TestNewAppWithConfig(t *testing.T) {
+	cfg := DefaultConfig()
+	cfg.Workers = 3
+	cfg.QueueSize = 7
+
+	app, err := NewAppWithConfig(cfg, NewJobStore())
+	if err != nil {
+		t.Fatalf("NewAppWithConfig failed: %v", err)
+	}
+
+	stats := app.queue.Stats()
+	if stats.Workers != 3 || stats.Capacity != 7 {
+		t.Errorf("Queue mismatch. Expected 3 workers and capacity 7, got %d and %d", stats.Workers, stats.Capacity)
+	}
+
+	cfg.Validation.EmailPattern = "("
+	if _, err := NewAppWithConfig(cfg, NewJobStore()); err == nil {
+		t.Error("Expected error for invalid configuration")
+	}
+}
+
+func TestUploadHandlerTooLarge(t *testing.T) {
+	cfg := DefaultConfig()
+	cfg.StorageDir = t.TempDir()
+	cfg.MaxUploadSize = 1024
+
+	app, err := NewAppWithConfig(cfg, NewJobStore())
+	if err != nil {
+		t.Fatalf("NewAppWithConfig failed: %v", err)
+	}
+
+	var body bytes.Buffer
+	writer := multipart.NewWriter(&body)
+	part, err := writer.CreateFormFile("file", "large.csv")
+	if err != nil {
+		t.Fatalf("Failed to create form file: %v", err)
+	}
+	part.Write([]byte("name,email\n" + strings.Repeat("User,user@example.com\n", 100)))
+	writer.Close()
+
+	req := httptest.NewRequest("POST", "/API/upload", &body)
+	req.Header.Set("Content-Type", writer.FormDataContentType())
+	w := httptest.NewRecorder()
+
+	app.UploadHandler(w, req)
+
+	if w.Code != http.StatusRequestEntityTooLarge {
+		t.Errorf("Expected status 413, got %d", w.Code)
+	}
+}
+
+func TestAppShutdown(t *testing.T) {
+	app := newTestApp(t)
+
+	tempDir := t.TempDir()
+	uploadPath := filepath.Join(tempDir, "upload.csv")
+	if err := os.WriteFile(uploadPath, []byte("name,email\nJohn Doe,john@example.com"), 0644); err != nil {
+		t.Fatalf("Failed to write upload: %v", err)
+	}
+
+	// A worker that is busy with a job that only stops when cancelled
+	started := make(chan struct{}, 1)
+	app.queue = NewJobQueue(1, 5, func(task processingTask) {
+		ctx, cancel := context.WithCancel(context.Background())
+		app.mu.Lock()
+		app.running[task.JobID] = cancel
+		app.jobStore.StartJob(task.JobID)
+		app.mu.Unlock()
+		started <- struct{}{}
+		<-ctx.Done()
+	})
+
+	app.jobStore.CreateJob("running-job")
+	app.queue.Enqueue(processingTask{JobID: "running-job"})
+	<-started
+
+	app.jobStore.CreateJob("waiting-job")
+	app.queue.Enqueue(processingTask{JobID: "waiting-job", UploadPath: uploadPath})
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	if err := app.Shutdown(ctx); err == nil {
+		t.Error("Expected deadline error while a job is still running")
+	}
+
+	for _, jobID := range []string{"running-job", "waiting-job"} {
+		job, _ := app.jobStore.GetJob(jobID)
+		if job.Status != JobStatusFailed || job.Error != shutdownJobError {
+			t.Errorf("Job %s: expected failed with %q, got %s with %q", jobID, shutdownJobError, job.Status, job.Error)
+		}
+	}
+	if _, err := os.Stat(uploadPath); !os.IsNotExist(err) {
+		t.Error("Upload of job that never started should be removed")
+	}
+
+	// Uploads are refused once shutdown has begun
+	var body bytes.Buffer
+	writer := multipart.NewWriter(&body)
+	part, _ := writer.CreateFormFile("file", "test.csv")
+	part.Write([]byte("name,email\nJohn Doe,john@example.com"))
+	writer.Close()
+
+	req := httptest.NewRequest("POST", "/API/upload", &body)
+	req.Header.Set("Content-Type", writer.FormDataContentType())
+	w := httptest.NewRecorder()
+	app.UploadHandler(w, req)
+
+	if w.Code != http.StatusServiceUnavailable {
+		t.Errorf("Expected status 503 during shutdown, got %d", w.Code)
+	}
+}
+
+func TestAppShutdownIdle(t *testing.T) {
+	app := newTestApp(t)
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	if err := app.Shutdown(ctx); err != nil {
+		t.Errorf("Shutdown of idle app failed: %v", err)
+	}
+}
+
+func TestAppShutdownKeepsCancelledJobs(t *testing.T) {
+	app := newTestApp(t)
+
+	// A worker that is busy until shutdown gives up on it
+	started := make(chan struct{}, 1)
+	app.queue = NewJobQueue(1, 5, func(task processingTask) {
+		ctx, cancel := context.WithCancel(context.Background())
+		app.mu.Lock()
+		app.running[task.JobID] = cancel
+		app.jobStore.StartJob(task.JobID)
+		app.mu.Unlock()
+		started <- struct{}{}
+		<-ctx.Done()
+	})
+
+	app.jobStore.CreateJob("running-job")
+	app.queue.Enqueue(processingTask{JobID: "running-job"})
+	<-started
+
+	// Both jobs are cancelled before shutdown, one while still queued
+	app.jobStore.CreateJob("queued-job")
+	app.queue.Enqueue(processingTask{JobID: "queued-job"})
+	for _, jobID := range []string{"queued-job", "running-job"} {
+		if _, err := app.cancelJob(jobID); err != nil {
+			t.Fatalf("cancelJob(%s) failed: %v", jobID, err)
+		}
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	app.Shutdown(ctx)
+
+	for _, jobID := range []string{"queued-job", "running-job"} {
+		job, _ := app.jobStore.GetJob(jobID)
+		if job.Status != JobStatusCancelled || job.Error != cancelledJobError {
+			t.Errorf("Job %s: expected cancelled with %q, got %s with %q", jobID, cancelledJobError, job.Status, job.Error)
+		}
+	}
+}
+
+func TestUploadHandlerRecordsUpload(t *testing.T) {
+	cfg := DefaultConfig()
+	cfg.StorageDir = t.TempDir()
+
+	app, err := NewAppWithConfig(cfg, NewJobStore())
+	if err != nil {
+		t.Fatalf("NewAppWithConfig failed: %v", err)
+	}
+
+	content := "name,email\nJohn,john@example.com\n"
+	sum := sha256.Sum256([]byte(content))
+	expectedHash := hex.EncodeToString(sum[:])
+
+	// Fields around the file part are skipped
+	var body bytes.Buffer
+	writer := multipart.NewWriter(&body)
+	writer.WriteField("comment", "before")
+	part, err := writer.CreateFormFile("file", "contacts.csv")
+	if err != nil {
+		t.Fatalf("Failed to create form file: %v", err)
+	}
+	part.Write([]byte(content))
+	writer.WriteField("comment", "after")
+	writer.Close()
+
+	req := httptest.NewRequest("POST", "/API/upload", &body)
+	req.Header.Set("Content-Type", writer.FormDataContentType())
+	w := httptest.NewRecorder()
+
+	app.UploadHandler(w, req)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d: %s", w.Code, w.Body.String())
+	}
+
+	var response UploadResponse
+	if err := json.NewDecoder(w.Body).Decode(&response); err != nil {
+		t.Fatalf("Failed to decode response: %v", err)
+	}
+	if response.Size != int64(len(content)) {
+		t.Errorf("Size mismatch. Expected: %d, Got: %d", len(content), response.Size)
+	}
+	if response.SHA256 != expectedHash {
+		t.Errorf("SHA256 mismatch. Expected: %s, Got: %s", expectedHash, response.SHA256)
+	}
+
+	job, exists := app.jobStore.GetJob(response.ID)
+	if !exists {
+		t.Fatal("Job was not created")
+	}
+	if job.Upload == nil {
+		t.Fatal("Upload metadata was not recorded")
+	}
+	if job.Upload.FileName != "contacts.csv" {
+		t.Errorf("FileName mismatch. Expected: contacts.csv, Got: %s", job.Upload.FileName)
+	}
+	if job.Upload.SHA256 != expectedHash {
+		t.Errorf("Recorded SHA256 mismatch. Expected: %s, Got: %s", expectedHash, job.Upload.SHA256)
+	}
+	if job.Upload.Dialect == nil || *job.Upload.Dialect != defaultDialect {
+		t.Errorf("Dialect mismatch. Expected: %+v, Got: %+v", defaultDialect, job.Upload.Dialect)
+	}
+	if job.Upload.Encoding == nil || job.Upload.Encoding.Name != "utf-8" {
+		t.Errorf("Encoding mismatch. Expected: utf-8, Got: %+v", job.Upload.Encoding)
+	}
+}
+
+func TestUploadHandlerEncoding(t *testing.T) {
+	cfg := DefaultConfig()
+	cfg.StorageDir = t.TempDir()
+
+	app, err := NewAppWithConfig(cfg, NewJobStore())
+	if err != nil {
+		t.Fatalf("NewAppWithConfig failed: %v", err)
+	}
+
+	// A UTF-16 file whose header can only be read once it is decoded
+	encoder := unicode.UTF16(unicode.LittleEndian, unicode.UseBOM).NewEncoder()
+	content, err := encoder.String("name;email\nJosé;jose@example.com\n")
+	if err != nil {
+		t.Fatalf("Failed to encode test data: %v", err)
+	}
+
+	var body bytes.Buffer
+	writer := multipart.NewWriter(&body)
+	part, err := writer.CreateFormFile("file", "export.csv")
+	if err != nil {
+		t.Fatalf("Failed to create form file: %v", err)
+	}
+	part.Write([]byte(content))
+	writer.WriteField("columns", "email")
+	writer.Close()
+
+	req := httptest.NewRequest("POST", "/API/upload", &body)
+	req.Header.Set("Content-Type", writer.FormDataContentType())
+	w := httptest.NewRecorder()
+
+	app.UploadHandler(w, req)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d: %s", w.Code, w.Body.String())
+	}
+
+	var response UploadResponse
+	if err := json.NewDecoder(w.Body).Decode(&response); err != nil {
+		t.Fatalf("Failed to decode response: %v", err)
+	}
+	job, exists := app.jobStore.GetJob(response.ID)
+	if !exists || job.Upload == nil {
+		t.Fatal("Upload metadata was not recorded")
+	}
+
+	expected := TextEncoding{Name: "utf-16le", BOM: true}
+	if job.Upload.Encoding == nil || *job.Upload.Encoding != expected {
+		t.Errorf("Encoding mismatch. Expected: %+v, Got: %+v", expected, job.Upload.Encoding)
+	}
+	if job.Upload.Dialect == nil || job.Upload.Dialect.Delimiter != ';' {
+		t.Errorf("Expected the decoded file's semicolon delimiter, got %+v", job.Upload.Dialect)
+	}
+}
+
+func TestUploadHandlerDialect(t *testing.T) {
+	cfg := DefaultConfig()
+	cfg.StorageDir = t.TempDir()
+
+	app, err := NewAppWithConfig(cfg, NewJobStore())
+	if err != nil {
+		t.Fatalf("NewAppWithConfig failed: %v", err)
+	}
+
+	tests := []struct {
+		name     string
+		content  string
+		fields   map[string]string
+		status   int
+		expected CSVDialect
+	}{
+		{"Detected", "name;email\nJohn;john@example.com\n", nil, http.StatusOK, CSVDialect{';', '"', true}},
+		{"Headerless", "John|john@example.com\n", map[string]string{"column_index": "1"}, http.StatusOK, CSVDialect{'|', '"', false}},
+		{"Overrides", "name;email\n", map[string]string{"delimiter": "tab", "quote": "single", "has_header": "false"}, http.StatusOK, CSVDialect{'\t', '\'', false}},
+		{"Stray double quote", "name,email\na,a@example.com\nb,\"x\"y,q\nc,c,d\n", nil, http.StatusOK, CSVDialect{',', '"', true}},
+		{"Headerless columns by name", "John,john@example.com\n", map[string]string{"columns": "email"}, http.StatusBadRequest, CSVDialect{}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var body bytes.Buffer
+			writer := multipart.NewWriter(&body)
+			part, err := writer.CreateFormFile("file", "test.csv")
+			if err != nil {
+				t.Fatalf("Failed to create form file: %v", err)
+			}
+			part.Write([]byte(tt.content))
+			for name, value := range tt.fields {
+				writer.WriteField(name, value)
+			}
+			writer.Close()
+
+			req := httptest.NewRequest("POST", "/API/upload", &body)
+			req.Header.Set("Content-Type", writer.FormDataContentType())
+			w := httptest.NewRecorder()
+
+			app.UploadHandler(w, req)
+
+			if w.Code != tt.status {
+				t.Fatalf("Expected status %d, got %d: %s", tt.status, w.Code, w.Body.String())
+			}
+			if tt.status != http.StatusOK {
+				return
+			}
+
+			var response UploadResponse
+			if err := json.NewDecoder(w.Body).Decode(&response); err != nil {
+				t.Fatalf("Failed to decode response: %v", err)
+			}
+			job, exists := app.jobStore.GetJob(response.ID)
+			if !exists || job.Upload == nil || job.Upload.Dialect == nil {
+				t.Fatal("Dialect was not recorded")
+			}
+			if *job.Upload.Dialect != tt.expected {
+				t.Errorf("Dialect mismatch. Expected: %+v, Got: %+v", tt.expected, *job.Upload.Dialect)
+			}
+		})
+	}
+}
+
+func TestUploadHandlerOutputColumn(t *testing.T) {
+	cfg := DefaultConfig()
+	cfg.StorageDir = t.TempDir()
+
+	app, err := NewAppWithConfig(cfg, NewJobStore())
+	if err != nil {
+		t.Fatalf("NewAppWithConfig failed: %v", err)
+	}
+
+	tests := []struct {
+		name    string
+		content string
+		fields  map[string]string
+		status  int
+	}{
+		{"Collision suffixed", "email,has_email\njohn@example.com,yes\n", nil, http.StatusOK},
+		{"Collision rejected", "email,has_email\njohn@example.com,yes\n", map[string]string{"on_collision": "fail"}, http.StatusBadRequest},
+		{"Custom name rejected", "email,valid\njohn@example.com,yes\n", map[string]string{"output_column": "valid", "on_collision": "fail"}, http.StatusBadRequest},
+		{"Custom name accepted", "email,has_email\njohn@example.com,yes\n", map[string]string{"output_column": "valid", "on_collision": "fail"}, http.StatusOK},
+		{"Headerless", "has_email,john@example.com\n", map[string]string{"has_header": "false", "on_collision": "fail"}, http.StatusOK},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var body bytes.Buffer
+			writer := multipart.NewWriter(&body)
+			part, err := writer.CreateFormFile("file", "test.csv")
+			if err != nil {
+				t.Fatalf("Failed to create form file: %v", err)
+			}
+			part.Write([]byte(tt.content))
+			for name, value := range tt.fields {
+				writer.WriteField(name, value)
+			}
+			writer.Close()
+
+			req := httptest.NewRequest("POST", "/API/upload", &body)
+			req.Header.Set("Content-Type", writer.FormDataContentType())
+			w := httptest.NewRecorder()
+
+			app.UploadHandler(w, req)
+
+			if w.Code != tt.status {
+				t.Fatalf("Expected status %d, got %d: %s", tt.status, w.Code, w.Body.String())
+			}
+			if tt.status != http.StatusOK && !strings.Contains(w.Body.String(), "already exists") {
+				t.Errorf("Expected collision error, got: %s", w.Body.String())
+			}
+		})
+	}
+}
+
+func TestParseProcessOptions(t *testing.T) {
+	tests := []struct {
+		name            string
+		form            url.Values
+		query           url.Values
+		expectedOutput  OutputMode
+		expectedColumns []string
+		expectedReasons bool
+		expectError     bool
+	}{
+		{"Defaults", url.Values{}, url.Values{}, OutputHasEmail, nil, false, false},
+		{"Form fields", url.Values{"output": {"columns"}, "columns": {"email, backup"}, "reasons": {"true"}}, url.Values{}, OutputColumns, []string{"email", "backup"}, true, false},
+		{"Query parameters", url.Values{}, url.Values{"output": {"columns"}, "columns": {"email", "backup"}}, OutputColumns, []string{"email", "backup"}, false, false},
+		{"Form overrides query", url.Values{"columns": {"email"}}, url.Values{"columns": {"backup"}}, OutputHasEmail, []string{"email"}, false, false},
+		{"Column indexes", url.Values{"column_index": {"2,0"}}, url.Values{}, OutputHasEmail, nil, false, false},
+		{"Negative column index", url.Values{"column_index": {"-1"}}, url.Values{}, "", nil, false, true},
+		{"Non-numeric column index", url.Values{"column_index": {"email"}}, url.Values{}, "", nil, false, true},
+		{"Unknown output", url.Values{"output": {"xml"}}, url.Values{}, "", nil, false, true},
+		{"Invalid reasons", url.Values{"reasons": {"maybe"}}, url.Values{}, "", nil, false, true},
+		{"Unknown normalize mode", url.Values{"normalize": {"rewrite"}}, url.Values{}, "", nil, false, true},
+		{"Unknown collision mode", url.Values{"on_collision": {"replace"}}, url.Values{}, "", nil, false, true},
+		{"Unknown lenient mode", url.Values{"lenient": {"ignore"}}, url.Values{}, "", nil, false, true},
+		{"Zero max errors", url.Values{"max_errors": {"0"}}, url.Values{}, "", nil, false, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			opts, err := parseProcessOptions(tt.form, tt.query)
+			if tt.expectError {
+				if err == nil {
+					t.Error("Expected error, got nil")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("parseProcessOptions failed: %v", err)
+			}
+			if opts.Output != tt.expectedOutput {
+				t.Errorf("Output mismatch. Expected: %s, Got: %s", tt.expectedOutput, opts.Output)
+			}
+			if strings.Join(opts.Columns, "|") != strings.Join(tt.expectedColumns, "|") {
+				t.Errorf("Columns mismatch. Expected: %v, Got: %v", tt.expectedColumns, opts.Columns)
+			}
+			if opts.Reasons != tt.expectedReasons {
+				t.Errorf("Reasons mismatch. Expected: %t, Got: %t", tt.expectedReasons, opts.Reasons)
+			}
+		})
+	}
+}
+
+func TestUploadHandlerInvalidOptions(t *testing.T) {
+	cfg := DefaultConfig()
+	cfg.StorageDir = t.TempDir()
+
+	app, err := NewAppWithConfig(cfg, NewJobStore())
+	if err != nil {
+		t.Fatalf("NewAppWithConfig failed: %v", err)
+	}
+
+	tests := []struct {
+		name  string
+		field string
+		value string
+	}{
+		{"Unknown output", "output", "xml"},
+		{"Mailbox verification disabled", "verify_mailbox", "true"},
+		{"Unknown policy", "policy", "missing"},
+		{"Invalid domain pattern", "allow_domains", "acme.[com"},
+		{"Invalid delimiter", "delimiter", "::"},
+		{"Invalid quote", "quote", "`"},
+		{"Invalid has_header", "has_header", "sometimes"},
+		{"Unknown encoding", "encoding", "ebcdic"},
+		{"Unknown collision mode", "on_collision", "replace"},
+		{"Invalid max errors", "max_errors", "many"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var body bytes.Buffer
+			writer := multipart.NewWriter(&body)
+			part, err := writer.CreateFormFile("file", "test.csv")
+			if err != nil {
+				t.Fatalf("Failed to create form file: %v", err)
+			}
+			part.Write([]byte("name,email\nJohn,john@example.com\n"))
+			writer.WriteField(tt.field, tt.value)
+			writer.Close()
+
+			req := httptest.NewRequest("POST", "/API/upload", &body)
+			req.Header.Set("Content-Type", writer.FormDataContentType())
+			w := httptest.NewRecorder()
+
+			app.UploadHandler(w, req)
+
+			if w.Code != http.StatusBadRequest {
+				t.Errorf("Expected status 400, got %d", w.Code)
+			}
+
+			// The rejected upload is not kept
+			entries, _ := os.ReadDir(cfg.StorageDir)
+			if len(entries) != 0 {
+				t.Errorf("Expected no stored files, found %d", len(entries))
+			}
+		})
+	}
+}
+
+func TestUploadHandlerUnknownColumn(t *testing.T) {
+	cfg := DefaultConfig()
+	cfg.StorageDir = t.TempDir()
+
+	app, err := NewAppWithConfig(cfg, NewJobStore())
+	if err != nil {
+		t.Fatalf("NewAppWithConfig failed: %v", err)
+	}
+
+	tests := []struct {
+		name            string
+		query           string
+		expectedStatus  int
+		expectedMessage string
+	}{
+		{"Known column", "columns=email", http.StatusOK, ""},
+		{"Unknown column", "columns=email,contact_email", http.StatusBadRequest, `Column "contact_email" does not exist`},
+		{"Known index", "column_index=1", http.StatusOK, ""},
+		{"Index out of range", "column_index=2", http.StatusBadRequest, "Column index 2 does not exist"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var body bytes.Buffer
+			writer := multipart.NewWriter(&body)
+			part, err := writer.CreateFormFile("file", "test.csv")
+			if err != nil {
+				t.Fatalf("Failed to create form file: %v", err)
+			}
+			part.Write([]byte("name,email\nJohn,john@example.com\n"))
+			writer.Close()
+
+			req := httptest.NewRequest("POST", "/API/upload?"+tt.query, &body)
+			req.Header.Set("Content-Type", writer.FormDataContentType())
+			w := httptest.NewRecorder()
+
+			app.UploadHandler(w, req)
+
+			if w.Code != tt.expectedStatus {
+				t.Fatalf("Expected status %d, got %d", tt.expectedStatus, w.Code)
+			}
+			if tt.expectedStatus == http.StatusOK {
+				return
+			}
+
+			var response ErrorResponse
+			if err := json.NewDecoder(w.Body).Decode(&response); err != nil {
+				t.Fatalf("Failed to decode response: %v", err)
+			}
+			if response.Error != tt.expectedMessage {
+				t.Errorf("Error mismatch. Expected: %s, Got: %s", tt.expectedMessage, response.Error)
+			}
+			if strings.Join(response.AvailableColumns, ",") != "name,email" {
+				t.Errorf("Available columns mismatch. Expected: name,email, Got: %v", response.AvailableColumns)
+			}
+		})
+	}
+}
+
+func TestValidateHandler(t *testing.T) {
+	cfg := DefaultConfig()
+	cfg.StorageDir = t.TempDir()
+	cfg.Validation.Policies = map[string]DomainPolicyConfig{
+		"acme": {Allow: []string{".acme.com"}},
+	}
+
+	app, err := NewAppWithConfig(cfg, NewJobStore())
+	if err != nil {
+		t.Fatalf("NewAppWithConfig failed: %v", err)
+	}
+
+	t.Run("Single address", func(t *testing.T) {
+		req := httptest.NewRequest("POST", "/API/validate", strings.NewReader(`"John.Doe+news@GoogleMail.com"`))
+		w := httptest.NewRecorder()
+
+		app.ValidateHandler(w, req)
+
+		if w.Code != http.StatusOK {
+			t.Fatalf("Expected status 200, got %d: %s", w.Code, w.Body.String())
+		}
+		var result EmailValidationResult
+		if err := json.NewDecoder(w.Body).Decode(&result); err != nil {
+			t.Fatalf("Failed to decode response: %v", err)
+		}
+		if !result.Valid || result.Email != "John.Doe+news@GoogleMail.com" {
+			t.Errorf("Expected a valid result for the address, got %+v", result)
+		}
+		if result.Normalized != "johndoe@gmail.com" {
+			t.Errorf("Normalized mismatch. Expected: johndoe@gmail.com, Got: %s", result.Normalized)
+		}
+		if !result.FreeProvider {
+			t.Error("Expected the address to be flagged as a free provider")
+		}
+	})
+
+	t.Run("Batch", func(t *testing.T) {
+		body := `["jane@example.com", "not-an-email", "bob@gmial.com"]`
+		req := httptest.NewRequest("POST", "/API/validate", strings.NewReader(body))
+		w := httptest.NewRecorder()
+
+		app.ValidateHandler(w, req)
+
+		if w.Code != http.StatusOK {
+			t.Fatalf("Expected status 200, got %d: %s", w.Code, w.Body.String())
+		}
+		var response ValidateResponse
+		if err := json.NewDecoder(w.Body).Decode(&response); err != nil {
+			t.Fatalf("Failed to decode response: %v", err)
+		}
+
+		expected := []struct {
+			email      string
+			valid      bool
+			reason     ValidationReason
+			suggestion string
+		}{
+			{"jane@example.com", true, "", ""},
+			{"not-an-email", false, ReasonMissingAt, ""},
+			{"bob@gmial.com", true, "", "bob@gmail.com"},
+		}
+		if len(response.Results) != len(expected) {
+			t.Fatalf("Expected %d results, got %d", len(expected), len(response.Results))
+		}
+		for i, want := range expected {
+			got := response.Results[i]
+			if got.Email != want.email || got.Valid != want.valid || got.Reason != want.reason || got.Suggestion != want.suggestion {
+				t.Errorf("Result %d mismatch. Expected: %+v, Got: %+v", i, want, got)
+			}
+		}
+	})
+
+	t.Run("Policy", func(t *testing.T) {
+		req := httptest.NewRequest("POST", "/API/validate?policy=acme", strings.NewReader(`["a@acme.com", "b@example.com"]`))
+		w := httptest.NewRecorder()
+
+		app.ValidateHandler(w, req)
+
+		var response ValidateResponse
+		if err := json.NewDecoder(w.Body).Decode(&response); err != nil {
+			t.Fatalf("Failed to decode response: %v", err)
+		}
+		if len(response.Results) != 2 || !response.Results[0].Valid || response.Results[1].Valid {
+			t.Fatalf("Expected only the acme.com address to be valid, got %+v", response.Results)
+		}
+		if violation := response.Results[1].PolicyViolation; violation != policyNotAllowed {
+			t.Errorf("Policy violation mismatch. Expected: %s, Got: %s", policyNotAllowed, violation)
+		}
+	})
+}
+
+func TestValidateHandlerInvalidRequest(t *testing.T) {
+	cfg := DefaultConfig()
+	cfg.StorageDir = t.TempDir()
+	cfg.MaxValidateBatch = 2
+
+	app, err := NewAppWithConfig(cfg, NewJobStore())
+	if err != nil {
+		t.Fatalf("NewAppWithConfig failed: %v", err)
+	}
+
+	tests := []struct {
+		name   string
+		query  string
+		body   string
+		status int
+	}{
+		{"Empty body", "", "", http.StatusBadRequest},
+		{"Malformed JSON", "", `["a@example.com"`, http.StatusBadRequest},
+		{"Object", "", `{"email": "a@example.com"}`, http.StatusBadRequest},
+		{"Array of numbers", "", `[1, 2]`, http.StatusBadRequest},
+		{"Null", "", `null`, http.StatusBadRequest},
+		{"Trailing value", "", `"x@example.com" "y@example.com"`, http.StatusBadRequest},
+		{"Trailing garbage", "", `["a@example.com"] x`, http.StatusBadRequest},
+		{"Trailing whitespace", "", "\"a@example.com\"\n", http.StatusOK},
+		{"Over the batch cap", "", `["a@example.com", "b@example.com", "c@example.com"]`, http.StatusBadRequest},
+		{"Unknown policy", "?policy=missing", `"a@example.com"`, http.StatusBadRequest},
+		{"Too large", "", `"` + strings.Repeat("a", maxValidateBodyBytes) + `"`, http.StatusRequestEntityTooLarge},
+		{"At the batch cap", "", `["a@example.com", "b@example.com"]`, http.StatusOK},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest("POST", "/API/validate"+tt.query, strings.NewReader(tt.body))
+			w := httptest.NewRecorder()
+
+			app.ValidateHandler(w, req)
+
+			if w.Code != tt.status {
+				t.Errorf("Expected status %d, got %d: %s", tt.status, w.Code, w.Body.String())
+			}
+		})
+	}
+}
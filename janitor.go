@@ -0,0 +1,227 @@
+package main
+
+import (
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// defaultUploadTTL is how long raw uploads are kept
+	defaultUploadTTL = 24 * time.Hour
+
+	// defaultProcessedTTL is how long processed files stay downloadable
+	defaultProcessedTTL = 24 * time.Hour
+
+	// defaultJobTTL is how long finished job records are kept
+	defaultJobTTL = 7 * 24 * time.Hour
+
+	// defaultJanitorInterval is how often the janitor sweeps for expired data
+	defaultJanitorInterval = 10 * time.Minute
+
+	// expiredJobError is recorded on jobs whose processed file has been removed
+	expiredJobError = "Processed file has expired"
+
+	uploadFilePrefix      = "upload_"
+	processedFilePrefix   = "processed_"
+	errorReportFilePrefix = "errors_"
+)
+
+// RetentionPolicy controls how long uploads, processed files and job
+// records are kept. A zero TTL keeps the corresponding data forever.
+type RetentionPolicy struct {
+	UploadTTL    time.Duration
+	ProcessedTTL time.Duration
+	JobTTL       time.Duration
+}
+
+// DefaultRetentionPolicy returns the retention policy used when none is configured
+func DefaultRetentionPolicy() RetentionPolicy {
+	return RetentionPolicy{
+		UploadTTL:    defaultUploadTTL,
+		ProcessedTTL: defaultProcessedTTL,
+		JobTTL:       defaultJobTTL,
+	}
+}
+
+// SweepResult summarises the work done by a single janitor sweep
+type SweepResult struct {
+	FilesDeleted int
+	JobsExpired  int
+	JobsDeleted  int
+}
+
+// Janitor periodically deletes expired files and job records
+type Janitor struct {
+	store  JobStore
+	dir    string
+	policy RetentionPolicy
+	stop   chan struct{}
+	done   chan struct{}
+	once   sync.Once
+}
+
+// NewJanitor creates a janitor for the jobs in store and the files in dir
+func NewJanitor(store JobStore, dir string, policy RetentionPolicy) *Janitor {
+	return &Janitor{
+		store:  store,
+		dir:    dir,
+		policy: policy,
+		stop:   make(chan struct{}),
+		done:   make(chan struct{}),
+	}
+}
+
+// Start runs a sweep every interval in the background until Stop is called
+func (j *Janitor) Start(interval time.Duration) {
+	go func() {
+		defer close(j.done)
+
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ticker.C:
+				result := j.Sweep(time.Now())
+				if result.FilesDeleted > 0 || result.JobsDeleted > 0 {
+					log.Printf("Janitor removed %d files, expired %d jobs and deleted %d job records",
+						result.FilesDeleted, result.JobsExpired, result.JobsDeleted)
+				}
+			case <-j.stop:
+				return
+			}
+		}
+	}()
+}
+
+// Stop halts the background sweeps and waits for a running sweep to finish
+func (j *Janitor) Stop() {
+	j.once.Do(func() {
+		close(j.stop)
+		<-j.done
+	})
+}
+
+// Sweep deletes everything that has outlived the retention policy as of now
+func (j *Janitor) Sweep(now time.Time) SweepResult {
+	var result SweepResult
+
+	// Expire processed files and forget old job records
+	jobs := make(map[string]*ProcessingJob)
+	for _, job := range j.store.ListJobs() {
+		if !job.IsFinished() {
+			jobs[job.ID] = job
+			continue
+		}
+
+		finishedAt := job.CreatedAt
+		if job.FinishedAt != nil {
+			finishedAt = *job.FinishedAt
+		}
+		age := now.Sub(finishedAt)
+
+		if expired(age, j.policy.JobTTL) {
+			if job.Status == JobStatusCompleted && j.removeFile(job.FilePath) {
+				result.FilesDeleted++
+			}
+			j.store.DeleteJob(job.ID)
+			result.JobsDeleted++
+			continue
+		}
+
+		if job.Status == JobStatusCompleted && expired(age, j.policy.ProcessedTTL) {
+			if j.removeFile(job.FilePath) {
+				result.FilesDeleted++
+			}
+			j.store.UpdateJobStatus(job.ID, JobStatusExpired, "", expiredJobError)
+			result.JobsExpired++
+			job.Status = JobStatusExpired
+		}
+		jobs[job.ID] = job
+	}
+
+	// Remove stale files, including ones no job record refers to any more
+	entries, err := os.ReadDir(j.dir)
+	if err != nil {
+		if !os.IsNotExist(err) {
+			log.Printf("Janitor failed to read %s: %v", j.dir, err)
+		}
+		return result
+	}
+
+	for _, entry := range entries {
+		if !entry.Type().IsRegular() {
+			continue
+		}
+		name := entry.Name()
+
+		var ttl time.Duration
+		switch {
+		case strings.HasPrefix(name, uploadFilePrefix):
+			ttl = j.policy.UploadTTL
+		case strings.HasPrefix(name, processedFilePrefix), strings.HasPrefix(name, errorReportFilePrefix):
+			ttl = j.policy.ProcessedTTL
+		default:
+			continue
+		}
+
+		// Never touch files that belong to a job still being processed,
+		// and leave processed files of live jobs to the pass above
+		if job, exists := jobs[jobIDFromFileName(name)]; exists {
+			if !job.IsFinished() || (job.Status == JobStatusCompleted && strings.HasPrefix(name, processedFilePrefix)) {
+				continue
+			}
+		}
+
+		info, err := entry.Info()
+		if err != nil || !expired(now.Sub(info.ModTime()), ttl) {
+			continue
+		}
+		if j.removeFile(filepath.Join(j.dir, name)) {
+			result.FilesDeleted++
+		}
+	}
+
+	return result
+}
+
+// removeFile deletes path and reports whether a file was removed
+func (j *Janitor) removeFile(path string) bool {
+	if path == "" {
+		return false
+	}
+	if err := os.Remove(path); err != nil {
+		if !os.IsNotExist(err) {
+			log.Printf("Janitor failed to remove %s: %v", path, err)
+		}
+		return false
+	}
+	return true
+}
+
+// expired reports whether age has reached a non-zero ttl
+func expired(age, ttl time.Duration) bool {
+	return ttl > 0 && age >= ttl
+}
+
+// jobIDFromFileName extracts the job ID from upload_<id>_<name> and processed_<id>.csv file names
+func jobIDFromFileName(name string) string {
+	switch {
+	case strings.HasPrefix(name, uploadFilePrefix):
+		rest := strings.TrimPrefix(name, uploadFilePrefix)
+		if i := strings.Index(rest, "_"); i >= 0 {
+			return rest[:i]
+		}
+		return rest
+	case strings.HasPrefix(name, processedFilePrefix):
+		return strings.TrimSuffix(strings.TrimPrefix(name, processedFilePrefix), filepath.Ext(name))
+	case strings.HasPrefix(name, errorReportFilePrefix):
+		return strings.TrimSuffix(strings.TrimPrefix(name, errorReportFilePrefix), filepath.Ext(name))
+	default:
+		return ""
+	}
+}
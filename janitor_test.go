@@ -0,0 +1,194 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// writeAgedFile creates a file in dir whose modification time is age in the past
+func writeAgedFile(t *testing.T, dir, name string, age time.Duration) string {
+	t.Helper()
+
+	path := filepath.Join(dir, name)
+	if err := os.WriteFile(path, []byte("name,email\n"), 0644); err != nil {
+		t.Fatalf("Failed to write %s: %v", name, err)
+	}
+	modTime := time.Now().Add(-age)
+	if err := os.Chtimes(path, modTime, modTime); err != nil {
+		t.Fatalf("Failed to set times on %s: %v", name, err)
+	}
+	return path
+}
+
+func fileExists(path string) bool {
+	_, err := os.Stat(path)
+	return err == nil
+}
+
+func TestDefaultRetentionPolicy(t *testing.T) {
+	policy := DefaultRetentionPolicy()
+	if policy.UploadTTL != defaultUploadTTL {
+		t.Errorf("UploadTTL mismatch. Expected: %v, Got: %v", defaultUploadTTL, policy.UploadTTL)
+	}
+	if policy.ProcessedTTL != defaultProcessedTTL {
+		t.Errorf("ProcessedTTL mismatch. Expected: %v, Got: %v", defaultProcessedTTL, policy.ProcessedTTL)
+	}
+	if policy.JobTTL != defaultJobTTL {
+		t.Errorf("JobTTL mismatch. Expected: %v, Got: %v", defaultJobTTL, policy.JobTTL)
+	}
+}
+
+func TestJanitorSweep(t *testing.T) {
+	dir := t.TempDir()
+	store := NewJobStore()
+	policy := RetentionPolicy{
+		UploadTTL:    time.Hour,
+		ProcessedTTL: 2 * time.Hour,
+		JobTTL:       24 * time.Hour,
+	}
+	janitor := NewJanitor(store, dir, policy)
+
+	// Completed job whose processed file is past its TTL
+	oldProcessed := writeAgedFile(t, dir, "processed_old-job.csv", 3*time.Hour)
+	oldUpload := writeAgedFile(t, dir, "upload_old-job_data.csv", 3*time.Hour)
+	finishedAt := time.Now().Add(-3 * time.Hour)
+	store.put(ProcessingJob{
+		ID:         "old-job",
+		Status:     JobStatusCompleted,
+		CreatedAt:  finishedAt,
+		FinishedAt: &finishedAt,
+		FilePath:   oldProcessed,
+	})
+
+	// Recently completed job that must be kept
+	freshProcessed := writeAgedFile(t, dir, "processed_fresh-job.csv", 0)
+	store.CreateJob("fresh-job")
+	store.UpdateJobStatus("fresh-job", JobStatusCompleted, freshProcessed, "")
+
+	// Queued job with an old upload must not lose its input
+	queuedUpload := writeAgedFile(t, dir, "upload_queued-job_data.csv", 3*time.Hour)
+	store.CreateJob("queued-job")
+
+	// Orphaned files with no job record
+	orphanProcessed := writeAgedFile(t, dir, "processed_orphan.csv", 3*time.Hour)
+	journal := writeAgedFile(t, dir, "jobs.journal", 3*time.Hour)
+
+	result := janitor.Sweep(time.Now())
+
+	if result.JobsExpired != 1 {
+		t.Errorf("JobsExpired mismatch. Expected: 1, Got: %d", result.JobsExpired)
+	}
+	if result.JobsDeleted != 0 {
+		t.Errorf("JobsDeleted mismatch. Expected: 0, Got: %d", result.JobsDeleted)
+	}
+
+	tests := []struct {
+		path   string
+		exists bool
+	}{
+		{oldProcessed, false},
+		{oldUpload, false},
+		{freshProcessed, true},
+		{queuedUpload, true},
+		{orphanProcessed, false},
+		{journal, true},
+	}
+	for _, tt := range tests {
+		if fileExists(tt.path) != tt.exists {
+			t.Errorf("File %s existence mismatch. Expected: %t", filepath.Base(tt.path), tt.exists)
+		}
+	}
+
+	job, _ := store.GetJob("old-job")
+	if job.Status != JobStatusExpired {
+		t.Errorf("Job status mismatch. Expected: %s, Got: %s", JobStatusExpired, job.Status)
+	}
+	job, _ = store.GetJob("queued-job")
+	if job.Status != JobStatusQueued {
+		t.Errorf("Job status mismatch. Expected: %s, Got: %s", JobStatusQueued, job.Status)
+	}
+}
+
+func TestJanitorSweepDeletesOldJobs(t *testing.T) {
+	dir := t.TempDir()
+	store := NewJobStore()
+	janitor := NewJanitor(store, dir, DefaultRetentionPolicy())
+
+	processed := writeAgedFile(t, dir, "processed_done-job.csv", 0)
+	store.CreateJob("done-job")
+	store.UpdateJobStatus("done-job", JobStatusCompleted, processed, "")
+	store.CreateJob("failed-job")
+	store.UpdateJobStatus("failed-job", JobStatusFailed, "", "Processing failed")
+	store.CreateJob("running-job")
+	store.StartJob("running-job")
+
+	result := janitor.Sweep(time.Now().Add(defaultJobTTL + time.Minute))
+
+	if result.JobsDeleted != 2 {
+		t.Errorf("JobsDeleted mismatch. Expected: 2, Got: %d", result.JobsDeleted)
+	}
+	if _, exists := store.GetJob("done-job"); exists {
+		t.Error("Completed job should be deleted")
+	}
+	if _, exists := store.GetJob("failed-job"); exists {
+		t.Error("Failed job should be deleted")
+	}
+	if _, exists := store.GetJob("running-job"); !exists {
+		t.Error("Running job should be kept")
+	}
+	if fileExists(processed) {
+		t.Error("Processed file of deleted job should be removed")
+	}
+}
+
+func TestJanitorZeroTTLKeepsData(t *testing.T) {
+	dir := t.TempDir()
+	store := NewJobStore()
+	janitor := NewJanitor(store, dir, RetentionPolicy{})
+
+	processed := writeAgedFile(t, dir, "processed_job.csv", 1000*time.Hour)
+	store.CreateJob("job")
+	store.UpdateJobStatus("job", JobStatusCompleted, processed, "")
+
+	result := janitor.Sweep(time.Now().Add(1000 * time.Hour))
+
+	if result != (SweepResult{}) {
+		t.Errorf("Expected no work with zero TTLs, got %+v", result)
+	}
+	if !fileExists(processed) {
+		t.Error("Processed file should be kept")
+	}
+}
+
+func TestJanitorStartStop(t *testing.T) {
+	janitor := NewJanitor(NewJobStore(), t.TempDir(), DefaultRetentionPolicy())
+	janitor.Start(time.Millisecond)
+	time.Sleep(5 * time.Millisecond)
+
+	// Stop must be safe to call more than once
+	janitor.Stop()
+	janitor.Stop()
+}
+
+func TestJobIDFromFileName(t *testing.T) {
+	tests := []struct {
+		name     string
+		expected string
+	}{
+		{"upload_5e255eb2-66db-4b21-a3a8-02e9e4c1d0c3_sample.csv", "5e255eb2-66db-4b21-a3a8-02e9e4c1d0c3"},
+		{"upload_job-1_my_file.csv", "job-1"},
+		{"processed_5e255eb2-66db-4b21-a3a8-02e9e4c1d0c3.csv", "5e255eb2-66db-4b21-a3a8-02e9e4c1d0c3"},
+		{"errors_5e255eb2-66db-4b21-a3a8-02e9e4c1d0c3.csv", "5e255eb2-66db-4b21-a3a8-02e9e4c1d0c3"},
+		{"jobs.journal", ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := jobIDFromFileName(tt.name); got != tt.expected {
+				t.Errorf("jobIDFromFileName(%q) = %q, expected %q", tt.name, got, tt.expected)
+			}
+		})
+	}
+}
@@ -0,0 +1,306 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+)
+
+// interruptedJobError is recorded on jobs that were still running when the server stopped
+const interruptedJobError = "Job interrupted by server restart"
+
+const (
+	// minCompactEntries is the journal length below which it is never compacted
+	minCompactEntries = 1000
+
+	// compactRatio is how many journal entries per stored job trigger a compaction
+	compactRatio = 4
+)
+
+// journalEntry is a single line of the job journal
+type journalEntry struct {
+	ProcessingJob
+	Deleted bool `json:"deleted,omitempty"`
+}
+
+// FileJobStore is a durable job store backed by an append-only journal.
+// Every status change appends a JSON snapshot of the job; on startup the
+// journal is replayed, compacted and any unfinished jobs are marked as
+// failed. While running, the journal is compacted again whenever
+// superseded snapshots and tombstones make it compactRatio times longer
+// than the number of jobs. Progress updates are kept in memory only and
+// reach the journal with the next status change.
+type FileJobStore struct {
+	*MemoryJobStore
+	path    string
+	journal *os.File
+	mu      sync.Mutex
+
+	// entries is the number of lines in the journal and compactAt the
+	// number at which it is next checked for compaction
+	entries   int
+	compactAt int
+}
+
+// NewFileJobStore opens (or creates) the journal at path and reloads its jobs
+func NewFileJobStore(path string) (*FileJobStore, error) {
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return nil, fmt.Errorf("failed to create job store directory: %w", err)
+	}
+
+	store := &FileJobStore{
+		MemoryJobStore: NewJobStore(),
+		path:           path,
+	}
+
+	if err := store.replay(); err != nil {
+		return nil, err
+	}
+
+	// Jobs that were waiting or mid-processing cannot be resumed
+	for _, job := range store.list() {
+		if job.Status == JobStatusQueued || job.Status == JobStatusProcessing {
+			job.Status = JobStatusFailed
+			job.Error = interruptedJobError
+			now := time.Now()
+			job.FinishedAt = &now
+			store.put(job)
+		}
+	}
+
+	if err := store.compact(); err != nil {
+		return nil, err
+	}
+	if err := store.openJournal(); err != nil {
+		return nil, err
+	}
+
+	return store, nil
+}
+
+// CreateJob creates a new processing job and records it in the journal
+func (fs *FileJobStore) CreateJob(id string) *ProcessingJob {
+	fs.mu.Lock()
+	defer fs.mu.Unlock()
+
+	job := fs.MemoryJobStore.CreateJob(id)
+	fs.append(*job)
+	return job
+}
+
+// RecordUpload attaches upload details to a job and records it in the journal
+func (fs *FileJobStore) RecordUpload(id string, upload UploadInfo) {
+	fs.mu.Lock()
+	defer fs.mu.Unlock()
+
+	fs.MemoryJobStore.RecordUpload(id, upload)
+	if job, exists := fs.MemoryJobStore.GetJob(id); exists {
+		fs.append(*job)
+	}
+}
+
+// StartJob marks a job as processing and records it in the journal
+func (fs *FileJobStore) StartJob(id string) {
+	fs.mu.Lock()
+	defer fs.mu.Unlock()
+
+	fs.MemoryJobStore.StartJob(id)
+	if job, exists := fs.MemoryJobStore.GetJob(id); exists {
+		fs.append(*job)
+	}
+}
+
+// UpdateJobStatus updates the status of a job and records it in the journal
+func (fs *FileJobStore) UpdateJobStatus(id string, status JobStatus, filePath string, errorMsg string) {
+	fs.mu.Lock()
+	defer fs.mu.Unlock()
+
+	fs.MemoryJobStore.UpdateJobStatus(id, status, filePath, errorMsg)
+	if job, exists := fs.MemoryJobStore.GetJob(id); exists {
+		fs.append(*job)
+	}
+}
+
+// DeleteJob removes a job and records a tombstone in the journal
+func (fs *FileJobStore) DeleteJob(id string) {
+	fs.mu.Lock()
+	defer fs.mu.Unlock()
+
+	if _, exists := fs.MemoryJobStore.GetJob(id); !exists {
+		return
+	}
+	fs.MemoryJobStore.DeleteJob(id)
+	fs.appendEntry(journalEntry{ProcessingJob: ProcessingJob{ID: id}, Deleted: true})
+}
+
+// Close flushes and closes the journal
+func (fs *FileJobStore) Close() error {
+	fs.mu.Lock()
+	defer fs.mu.Unlock()
+
+	if fs.journal == nil {
+		return nil
+	}
+	if err := fs.journal.Sync(); err != nil {
+		fs.journal.Close()
+		return fmt.Errorf("failed to sync job journal: %w", err)
+	}
+	err := fs.journal.Close()
+	fs.journal = nil
+	return err
+}
+
+// append writes a job snapshot to the journal; callers must hold fs.mu
+func (fs *FileJobStore) append(job ProcessingJob) {
+	fs.appendEntry(journalEntry{ProcessingJob: job})
+}
+
+// appendEntry writes an entry to the journal; callers must hold fs.mu
+func (fs *FileJobStore) appendEntry(entry journalEntry) {
+	if fs.journal == nil {
+		return
+	}
+
+	data, err := json.Marshal(entry)
+	if err != nil {
+		log.Printf("Failed to encode job %s: %v", entry.ID, err)
+		return
+	}
+	if _, err := fs.journal.Write(append(data, '\n')); err != nil {
+		log.Printf("Failed to write job %s to journal: %v", entry.ID, err)
+		return
+	}
+
+	fs.entries++
+	if fs.entries >= fs.compactAt {
+		fs.compactRunning()
+	}
+}
+
+// compactRunning compacts the journal of a running store if it has grown
+// compactRatio times longer than the number of jobs, and otherwise puts
+// off the next check until it might have; callers must hold fs.mu
+func (fs *FileJobStore) compactRunning() {
+	if jobs := len(fs.list()); fs.entries < compactRatio*jobs {
+		fs.compactAt = compactRatio * jobs
+		return
+	}
+
+	if err := fs.compact(); err != nil {
+		log.Printf("Failed to compact job journal: %v", err)
+		fs.compactAt = fs.entries + minCompactEntries
+		return
+	}
+
+	// The open journal still points at the file that was replaced
+	fs.journal.Close()
+	fs.journal = nil
+	if err := fs.openJournal(); err != nil {
+		log.Printf("Failed to reopen job journal: %v", err)
+	}
+}
+
+// openJournal opens the journal for appending
+func (fs *FileJobStore) openJournal() error {
+	journal, err := os.OpenFile(fs.path, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0644)
+	if err != nil {
+		return fmt.Errorf("failed to open job journal: %w", err)
+	}
+	fs.journal = journal
+	return nil
+}
+
+// replay loads job snapshots from the journal, later entries winning and
+// tombstones removing earlier ones
+func (fs *FileJobStore) replay() error {
+	file, err := os.Open(fs.path)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("failed to open job journal: %w", err)
+	}
+	defer file.Close()
+
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+
+	lineNum := 0
+	for scanner.Scan() {
+		lineNum++
+		line := scanner.Bytes()
+		if len(line) == 0 {
+			continue
+		}
+
+		// A crash mid-write can leave a truncated entry; skip it
+		var entry journalEntry
+		if err := json.Unmarshal(line, &entry); err != nil || entry.ID == "" {
+			log.Printf("Skipping corrupt job journal entry on line %d", lineNum)
+			continue
+		}
+		if entry.Deleted {
+			fs.MemoryJobStore.DeleteJob(entry.ID)
+			continue
+		}
+		fs.put(entry.ProcessingJob)
+	}
+	if err := scanner.Err(); err != nil {
+		return fmt.Errorf("failed to read job journal: %w", err)
+	}
+
+	return nil
+}
+
+// compact rewrites the journal so it holds exactly one entry per job
+// and resets the compaction threshold
+func (fs *FileJobStore) compact() error {
+	jobs := fs.list()
+	sort.Slice(jobs, func(i, j int) bool {
+		return jobs[i].CreatedAt.Before(jobs[j].CreatedAt)
+	})
+
+	tmpPath := fs.path + ".tmp"
+	tmpFile, err := os.Create(tmpPath)
+	if err != nil {
+		return fmt.Errorf("failed to create job journal: %w", err)
+	}
+
+	writer := bufio.NewWriter(tmpFile)
+	encoder := json.NewEncoder(writer)
+	for _, job := range jobs {
+		if err := encoder.Encode(job); err != nil {
+			tmpFile.Close()
+			os.Remove(tmpPath)
+			return fmt.Errorf("failed to write job journal: %w", err)
+		}
+	}
+	if err := writer.Flush(); err != nil {
+		tmpFile.Close()
+		os.Remove(tmpPath)
+		return fmt.Errorf("failed to write job journal: %w", err)
+	}
+	if err := tmpFile.Sync(); err != nil {
+		tmpFile.Close()
+		os.Remove(tmpPath)
+		return fmt.Errorf("failed to sync job journal: %w", err)
+	}
+	if err := tmpFile.Close(); err != nil {
+		os.Remove(tmpPath)
+		return fmt.Errorf("failed to close job journal: %w", err)
+	}
+
+	if err := os.Rename(tmpPath, fs.path); err != nil {
+		return fmt.Errorf("failed to replace job journal: %w", err)
+	}
+
+	fs.entries = len(jobs)
+	fs.compactAt = max(minCompactEntries, compactRatio*len(jobs))
+	return nil
+}
@@ -0,0 +1,211 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestNewFileJobStore(t *testing.T) {
+	journalPath := filepath.Join(t.TempDir(), "data", "jobs.journal")
+
+	store, err := NewFileJobStore(journalPath)
+	if err != nil {
+		t.Fatalf("NewFileJobStore failed: %v", err)
+	}
+	defer store.Close()
+
+	if _, err := os.Stat(journalPath); os.IsNotExist(err) {
+		t.Errorf("Journal was not created at %s", journalPath)
+	}
+	if len(store.jobs) != 0 {
+		t.Errorf("Expected empty store, got %d jobs", len(store.jobs))
+	}
+}
+
+func TestFileJobStoreReload(t *testing.T) {
+	journalPath := filepath.Join(t.TempDir(), "jobs.journal")
+
+	store, err := NewFileJobStore(journalPath)
+	if err != nil {
+		t.Fatalf("NewFileJobStore failed: %v", err)
+	}
+
+	store.CreateJob("completed-job")
+	store.UpdateJobStatus("completed-job", JobStatusCompleted, "/path/processed.csv", "")
+	store.CreateJob("failed-job")
+	store.UpdateJobStatus("failed-job", JobStatusFailed, "", "Processing failed")
+	store.CreateJob("running-job")
+
+	if err := store.Close(); err != nil {
+		t.Fatalf("Close failed: %v", err)
+	}
+
+	// Reopen the journal as a restarted server would
+	reloaded, err := NewFileJobStore(journalPath)
+	if err != nil {
+		t.Fatalf("Reopening store failed: %v", err)
+	}
+	defer reloaded.Close()
+
+	tests := []struct {
+		jobID          string
+		expectedStatus JobStatus
+		expectedPath   string
+		expectedError  string
+	}{
+		{"completed-job", JobStatusCompleted, "/path/processed.csv", ""},
+		{"failed-job", JobStatusFailed, "", "Processing failed"},
+		{"running-job", JobStatusFailed, "", interruptedJobError},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.jobID, func(t *testing.T) {
+			job, exists := reloaded.GetJob(tt.jobID)
+			if !exists {
+				t.Fatalf("Job %s was not reloaded", tt.jobID)
+			}
+			if job.Status != tt.expectedStatus {
+				t.Errorf("Job status mismatch. Expected: %s, Got: %s", tt.expectedStatus, job.Status)
+			}
+			if job.FilePath != tt.expectedPath {
+				t.Errorf("Job file path mismatch. Expected: %s, Got: %s", tt.expectedPath, job.FilePath)
+			}
+			if job.Error != tt.expectedError {
+				t.Errorf("Job error mismatch. Expected: %s, Got: %s", tt.expectedError, job.Error)
+			}
+			if job.CreatedAt.IsZero() {
+				t.Error("Job CreatedAt was not reloaded")
+			}
+		})
+	}
+}
+
+func TestFileJobStoreCompaction(t *testing.T) {
+	journalPath := filepath.Join(t.TempDir(), "jobs.journal")
+
+	store, err := NewFileJobStore(journalPath)
+	if err != nil {
+		t.Fatalf("NewFileJobStore failed: %v", err)
+	}
+	store.CreateJob("job1")
+	store.UpdateJobStatus("job1", JobStatusCompleted, "/path/job1.csv", "")
+	store.CreateJob("job2")
+	store.Close()
+
+	// Reopening rewrites the journal with one entry per job
+	reloaded, err := NewFileJobStore(journalPath)
+	if err != nil {
+		t.Fatalf("Reopening store failed: %v", err)
+	}
+	reloaded.Close()
+
+	data, err := os.ReadFile(journalPath)
+	if err != nil {
+		t.Fatalf("Failed to read journal: %v", err)
+	}
+	lines := strings.Split(strings.TrimSpace(string(data)), "\n")
+	if len(lines) != 2 {
+		t.Errorf("Expected 2 journal entries after compaction, got %d", len(lines))
+	}
+}
+
+func TestFileJobStoreCompactsWhileRunning(t *testing.T) {
+	journalPath := filepath.Join(t.TempDir(), "jobs.journal")
+
+	store, err := NewFileJobStore(journalPath)
+	if err != nil {
+		t.Fatalf("NewFileJobStore failed: %v", err)
+	}
+	defer store.Close()
+
+	// Jobs that come and go leave snapshots and tombstones behind
+	store.CreateJob("kept-job")
+	for i := 0; i < minCompactEntries; i++ {
+		jobID := fmt.Sprintf("job-%d", i)
+		store.CreateJob(jobID)
+		store.UpdateJobStatus(jobID, JobStatusCompleted, "/path/"+jobID+".csv", "")
+		store.DeleteJob(jobID)
+	}
+	store.UpdateJobStatus("kept-job", JobStatusCompleted, "/path/kept.csv", "")
+
+	data, err := os.ReadFile(journalPath)
+	if err != nil {
+		t.Fatalf("Failed to read journal: %v", err)
+	}
+	lines := strings.Split(strings.TrimSpace(string(data)), "\n")
+	if len(lines) >= minCompactEntries {
+		t.Errorf("Expected the journal to be compacted, got %d entries", len(lines))
+	}
+
+	// Entries written after compaction reach the new journal
+	store.Close()
+	reloaded, err := NewFileJobStore(journalPath)
+	if err != nil {
+		t.Fatalf("Reopening store failed: %v", err)
+	}
+	defer reloaded.Close()
+
+	jobs := reloaded.ListJobs()
+	if len(jobs) != 1 || jobs[0].ID != "kept-job" || jobs[0].Status != JobStatusCompleted {
+		t.Errorf("Expected only the completed kept-job after reload, got %d jobs", len(jobs))
+	}
+}
+
+func TestFileJobStoreCorruptJournal(t *testing.T) {
+	journalPath := filepath.Join(t.TempDir(), "jobs.journal")
+
+	// Simulate a crash that truncated the last entry
+	journal := `{"id":"good-job","status":"completed","created_at":"2024-01-01T00:00:00Z","file_path":"/path/good.csv"}
+{"id":"bad-job","status":"compl`
+	if err := os.WriteFile(journalPath, []byte(journal), 0644); err != nil {
+		t.Fatalf("Failed to write journal: %v", err)
+	}
+
+	store, err := NewFileJobStore(journalPath)
+	if err != nil {
+		t.Fatalf("NewFileJobStore failed: %v", err)
+	}
+	defer store.Close()
+
+	if _, exists := store.GetJob("good-job"); !exists {
+		t.Error("Valid journal entry should be reloaded")
+	}
+	if _, exists := store.GetJob("bad-job"); exists {
+		t.Error("Corrupt journal entry should be skipped")
+	}
+}
+
+func TestFileJobStoreImplementsJobStore(t *testing.T) {
+	var _ JobStore = (*FileJobStore)(nil)
+	var _ JobStore = (*MemoryJobStore)(nil)
+}
+
+func TestFileJobStoreDeleteJob(t *testing.T) {
+	journalPath := filepath.Join(t.TempDir(), "jobs.journal")
+
+	store, err := NewFileJobStore(journalPath)
+	if err != nil {
+		t.Fatalf("NewFileJobStore failed: %v", err)
+	}
+	store.CreateJob("kept-job")
+	store.CreateJob("deleted-job")
+	store.DeleteJob("deleted-job")
+	store.Close()
+
+	// The tombstone must survive a restart
+	reloaded, err := NewFileJobStore(journalPath)
+	if err != nil {
+		t.Fatalf("Reopening store failed: %v", err)
+	}
+	defer reloaded.Close()
+
+	if _, exists := reloaded.GetJob("deleted-job"); exists {
+		t.Error("Deleted job should not be reloaded")
+	}
+	if _, exists := reloaded.GetJob("kept-job"); !exists {
+		t.Error("Kept job should be reloaded")
+	}
+}
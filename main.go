@@ -1,24 +1,111 @@
 package main
 
 import (
+	"context"
+	"errors"
+	"flag"
 	"fmt"
 	"log"
 	"net/http"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"syscall"
 
 	"github.com/gorilla/mux"
 )
 
 func main() {
+	// Load configuration from flags, environment and config file
+	cfg, err := LoadConfig(os.Args[1:], os.Getenv)
+	if errors.Is(err, flag.ErrHelp) {
+		return
+	}
+	if err != nil {
+		log.Fatalf("Invalid configuration: %v", err)
+	}
+
+	// Open persistent job store so jobs survive restarts
+	jobStore, err := NewFileJobStore(filepath.Join(cfg.StorageDir, "jobs.journal"))
+	if err != nil {
+		log.Fatalf("Failed to open job store: %v", err)
+	}
+	defer jobStore.Close()
+
+	// Remove expired uploads, processed files and job records in the background
+	janitor := NewJanitor(jobStore, cfg.StorageDir, cfg.Retention.Policy())
+	janitor.Start(cfg.Retention.JanitorInterval.Duration)
+	defer janitor.Stop()
+
 	// Create application instance
-	app := NewApp()
+	app, err := NewAppWithConfig(cfg, jobStore)
+	if err != nil {
+		log.Fatalf("Failed to create application: %v", err)
+	}
 
 	// Create router
+	router := NewRouter(app)
+
+	// Start server
+	fmt.Printf("Server starting on %s\n", cfg.ListenAddr)
+	fmt.Println("Available endpoints:")
+	fmt.Println("  POST /API/upload - Upload CSV file")
+	fmt.Println("  POST /API/validate - Validate addresses synchronously")
+	fmt.Println("  GET  /API/download/{id} - Download processed file")
+	fmt.Println("  GET  /API/errors/{id} - Download malformed row report")
+	fmt.Println("  GET  /API/jobs/{id} - Job status and progress")
+	fmt.Println("  DELETE /API/jobs/{id} - Cancel a queued or running job")
+	fmt.Println("  GET  /API/queue - Job queue depth")
+	fmt.Println("  GET  /health - Health check")
+
+	server := &http.Server{
+		Addr:    cfg.ListenAddr,
+		Handler: router,
+	}
+
+	// Stop on SIGINT or SIGTERM
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	serverErr := make(chan error, 1)
+	go func() {
+		serverErr <- server.ListenAndServe()
+	}()
+
+	select {
+	case err := <-serverErr:
+		log.Printf("Server stopped: %v", err)
+	case <-ctx.Done():
+		log.Println("Shutting down...")
+	}
+
+	// Drain requests and running jobs within the shutdown timeout
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), cfg.ShutdownTimeout.Duration)
+	defer cancel()
+
+	app.StopAcceptingUploads()
+	if err := server.Shutdown(shutdownCtx); err != nil {
+		log.Printf("HTTP server shutdown: %v", err)
+	}
+	if err := app.Shutdown(shutdownCtx); err != nil {
+		log.Printf("Interrupted unfinished jobs: %v", err)
+	}
+	log.Println("Shutdown complete")
+}
+
+// NewRouter registers the API routes for app
+func NewRouter(app *App) *mux.Router {
 	router := mux.NewRouter()
 
 	// API routes
 	api := router.PathPrefix("/API").Subrouter()
 	api.HandleFunc("/upload", app.UploadHandler).Methods("POST")
+	api.HandleFunc("/validate", app.ValidateHandler).Methods("POST")
 	api.HandleFunc("/download/{id}", app.DownloadHandler).Methods("GET")
+	api.HandleFunc("/errors/{id}", app.ErrorReportHandler).Methods("GET")
+	api.HandleFunc("/jobs/{id}", app.JobStatusHandler).Methods("GET")
+	api.HandleFunc("/jobs/{id}", app.CancelJobHandler).Methods("DELETE")
+	api.HandleFunc("/queue", app.QueueStatusHandler).Methods("GET")
 
 	// Health check endpoint
 	router.HandleFunc("/health", func(w http.ResponseWriter, r *http.Request) {
@@ -26,13 +113,5 @@ func main() {
 		fmt.Fprint(w, "OK")
 	}).Methods("GET")
 
-	// Start server
-	port := "8080"
-	fmt.Printf("Server starting on port %s\n", port)
-	fmt.Println("Available endpoints:")
-	fmt.Println("  POST /API/upload - Upload CSV file")
-	fmt.Println("  GET  /API/download/{id} - Download processed file")
-	fmt.Println("  GET  /health - Health check")
-
-	log.Fatal(http.ListenAndServe(":"+port, router))
+	return router
 }
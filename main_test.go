@@ -8,27 +8,15 @@ import (
 	"mime/multipart"
 	"net/http"
 	"net/http/httptest"
-	"os"
 	"strings"
 	"testing"
 	"time"
-
-	"github.com/gorilla/mux"
 )
 
 func TestMainIntegration(t *testing.T) {
 	// Create app instance
-	app := NewApp()
-	router := mux.NewRouter()
-
-	// Setup routes (same as main.go)
-	api := router.PathPrefix("/API").Subrouter()
-	api.HandleFunc("/upload", app.UploadHandler).Methods("POST")
-	api.HandleFunc("/download/{id}", app.DownloadHandler).Methods("GET")
-	router.HandleFunc("/health", func(w http.ResponseWriter, r *http.Request) {
-		w.WriteHeader(http.StatusOK)
-		fmt.Fprint(w, "OK")
-	}).Methods("GET")
+	app := newTestApp(t)
+	router := NewRouter(app)
 
 	// Test health endpoint
 	t.Run("Health Check", func(t *testing.T) {
@@ -47,15 +35,6 @@ func TestMainIntegration(t *testing.T) {
 
 	// Test complete upload and download flow
 	t.Run("Complete Upload and Download Flow", func(t *testing.T) {
-		// Create temporary directory for testing
-		tempDir := t.TempDir()
-		originalDir, _ := os.Getwd()
-		os.Chdir(tempDir)
-		defer os.Chdir(originalDir)
-
-		// Create uploads directory
-		os.MkdirAll("uploads", 0755)
-
 		// Test CSV content
 		csvContent := `name,email,phone,company
 John Doe,john.doe@example.com,555-1234,Acme Corp
@@ -141,15 +120,6 @@ Alice Brown,alice.brown@domain.co.uk,555-3456,Global Ltd`
 
 	// Test download while processing
 	t.Run("Download While Processing", func(t *testing.T) {
-		// Create temporary directory for testing
-		tempDir := t.TempDir()
-		originalDir, _ := os.Getwd()
-		os.Chdir(tempDir)
-		defer os.Chdir(originalDir)
-
-		// Create uploads directory
-		os.MkdirAll("uploads", 0755)
-
 		// Upload file
 		var body bytes.Buffer
 		writer := multipart.NewWriter(&body)
@@ -243,17 +213,8 @@ Alice Brown,alice.brown@domain.co.uk,555-3456,Global Ltd`
 
 func TestMainRoutes(t *testing.T) {
 	// Test that all routes are properly configured
-	app := NewApp()
-	router := mux.NewRouter()
-
-	// Setup routes
-	api := router.PathPrefix("/API").Subrouter()
-	api.HandleFunc("/upload", app.UploadHandler).Methods("POST")
-	api.HandleFunc("/download/{id}", app.DownloadHandler).Methods("GET")
-	router.HandleFunc("/health", func(w http.ResponseWriter, r *http.Request) {
-		w.WriteHeader(http.StatusOK)
-		fmt.Fprint(w, "OK")
-	}).Methods("GET")
+	app := newTestApp(t)
+	router := NewRouter(app)
 
 	// Test route matching
 	tests := []struct {
@@ -263,7 +224,12 @@ func TestMainRoutes(t *testing.T) {
 	}{
 		{"GET", "/health", http.StatusOK},
 		{"POST", "/API/upload", http.StatusBadRequest},          // No file provided
+		{"POST", "/API/validate", http.StatusBadRequest},        // No body provided
 		{"GET", "/API/download/test-id", http.StatusBadRequest}, // Invalid job ID
+		{"GET", "/API/errors/test-id", http.StatusBadRequest},   // Invalid job ID
+		{"GET", "/API/jobs/test-id", http.StatusBadRequest},     // Invalid job ID
+		{"GET", "/API/queue", http.StatusOK},
+		{"DELETE", "/API/jobs/test-id", http.StatusBadRequest}, // Invalid job ID
 		{"GET", "/invalid-path", http.StatusNotFound},
 		{"POST", "/API/invalid-endpoint", http.StatusNotFound},
 	}
@@ -283,26 +249,8 @@ func TestMainRoutes(t *testing.T) {
 
 func TestMainConcurrency(t *testing.T) {
 	// Test concurrent requests
-	app := NewApp()
-	router := mux.NewRouter()
-
-	// Setup routes
-	api := router.PathPrefix("/API").Subrouter()
-	api.HandleFunc("/upload", app.UploadHandler).Methods("POST")
-	api.HandleFunc("/download/{id}", app.DownloadHandler).Methods("GET")
-	router.HandleFunc("/health", func(w http.ResponseWriter, r *http.Request) {
-		w.WriteHeader(http.StatusOK)
-		fmt.Fprint(w, "OK")
-	}).Methods("GET")
-
-	// Create temporary directory for testing
-	tempDir := t.TempDir()
-	originalDir, _ := os.Getwd()
-	os.Chdir(tempDir)
-	defer os.Chdir(originalDir)
-
-	// Create uploads directory
-	os.MkdirAll("uploads", 0755)
+	app := newTestApp(t)
+	router := NewRouter(app)
 
 	// Test concurrent uploads
 	done := make(chan bool, 10)
@@ -363,13 +311,8 @@ func TestMainConcurrency(t *testing.T) {
 }
 
 func TestMainErrorHandling(t *testing.T) {
-	app := NewApp()
-	router := mux.NewRouter()
-
-	// Setup routes
-	api := router.PathPrefix("/API").Subrouter()
-	api.HandleFunc("/upload", app.UploadHandler).Methods("POST")
-	api.HandleFunc("/download/{id}", app.DownloadHandler).Methods("GET")
+	app := newTestApp(t)
+	router := NewRouter(app)
 
 	// Test various error conditions
 	tests := []struct {
@@ -1,6 +1,7 @@
 package main
 
 import (
+	"math"
 	"sync"
 	"time"
 )
@@ -9,72 +10,170 @@ import (
 type JobStatus string
 
 const (
+	JobStatusQueued     JobStatus = "queued"
 	JobStatusProcessing JobStatus = "processing"
 	JobStatusCompleted  JobStatus = "completed"
 	JobStatusFailed     JobStatus = "failed"
+	JobStatusCancelled  JobStatus = "cancelled"
+	JobStatusExpired    JobStatus = "expired"
 )
 
 // ProcessingJob represents a file processing job
 type ProcessingJob struct {
-	ID        string    `json:"id"`
-	Status    JobStatus `json:"status"`
-	CreatedAt time.Time `json:"created_at"`
-	FilePath  string    `json:"file_path,omitempty"`
-	Error     string    `json:"error,omitempty"`
+	ID              string      `json:"id"`
+	Status          JobStatus   `json:"status"`
+	CreatedAt       time.Time   `json:"created_at"`
+	StartedAt       *time.Time  `json:"started_at,omitempty"`
+	FinishedAt      *time.Time  `json:"finished_at,omitempty"`
+	Upload          *UploadInfo `json:"upload,omitempty"`
+	FilePath        string      `json:"file_path,omitempty"`
+	Error           string      `json:"error,omitempty"`
+	RowsProcessed   int64       `json:"rows_processed"`
+	RowsWithEmail   int64       `json:"rows_with_email"`
+	RowErrors       int64       `json:"row_errors"`
+	BytesRead       int64       `json:"bytes_read"`
+	TotalBytes      int64       `json:"total_bytes"`
+	PercentComplete float64     `json:"percent_complete"`
+}
+
+// UploadInfo describes the file uploaded for a job
+type UploadInfo struct {
+	FileName string `json:"file_name"`
+	Size     int64  `json:"size"`
+	SHA256   string `json:"sha256"`
+
+	// Encoding is the character encoding detected for the file, after any override
+	Encoding *TextEncoding `json:"encoding,omitempty"`
+
+	// Dialect is the layout detected for the file, after any overrides
+	Dialect *CSVDialect `json:"dialect,omitempty"`
+}
+
+// ProcessingProgress is a point-in-time report of how far a job has got
+type ProcessingProgress struct {
+	RowsProcessed int64
+	RowsWithEmail int64
+	RowErrors     int64
+	BytesRead     int64
+	TotalBytes    int64
+}
+
+// IsFinished reports whether the job has reached a terminal status
+func (job *ProcessingJob) IsFinished() bool {
+	switch job.Status {
+	case JobStatusCompleted, JobStatusFailed, JobStatusCancelled, JobStatusExpired:
+		return true
+	default:
+		return false
+	}
 }
 
 // UploadResponse represents the response for upload endpoint
 type UploadResponse struct {
-	ID string `json:"id"`
+	ID     string `json:"id"`
+	Size   int64  `json:"size,omitempty"`
+	SHA256 string `json:"sha256,omitempty"`
+}
+
+// EmailValidationResult is the outcome of validating one address with the validate endpoint
+type EmailValidationResult struct {
+	Email string `json:"email"`
+	ValidationResult
+
+	// Normalized is the canonical form of a valid address; see EmailNormalizer
+	Normalized string `json:"normalized,omitempty"`
+
+	// Suggestion is a corrected address when the domain looks like a typo
+	Suggestion string `json:"suggestion,omitempty"`
+}
+
+// ValidateResponse represents the response for a batch sent to the validate endpoint
+type ValidateResponse struct {
+	Results []EmailValidationResult `json:"results"`
 }
 
 // ErrorResponse represents an error response
 type ErrorResponse struct {
-	Error string `json:"error"`
+	Error            string   `json:"error"`
+	AvailableColumns []string `json:"available_columns,omitempty"`
 }
 
-// JobStore manages in-memory storage of processing jobs
-type JobStore struct {
+// JobStore is the interface implemented by job storage backends
+type JobStore interface {
+	CreateJob(id string) *ProcessingJob
+	GetJob(id string) (*ProcessingJob, bool)
+	RecordUpload(id string, upload UploadInfo)
+	StartJob(id string)
+	UpdateJobStatus(id string, status JobStatus, filePath string, errorMsg string)
+	UpdateJobProgress(id string, progress ProcessingProgress)
+	ListJobs() []*ProcessingJob
+	DeleteJob(id string)
+	Close() error
+}
+
+// MemoryJobStore manages in-memory storage of processing jobs
+type MemoryJobStore struct {
 	jobs map[string]*ProcessingJob
 	mu   sync.RWMutex
 }
 
-// NewJobStore creates a new job store
-func NewJobStore() *JobStore {
-	return &JobStore{
+// NewJobStore creates a new in-memory job store
+func NewJobStore() *MemoryJobStore {
+	return &MemoryJobStore{
 		jobs: make(map[string]*ProcessingJob),
 	}
 }
 
 // CreateJob creates a new processing job
-func (js *JobStore) CreateJob(id string) *ProcessingJob {
+func (js *MemoryJobStore) CreateJob(id string) *ProcessingJob {
 	js.mu.Lock()
 	defer js.mu.Unlock()
 
 	job := &ProcessingJob{
 		ID:        id,
-		Status:    JobStatusProcessing,
+		Status:    JobStatusQueued,
 		CreatedAt: time.Now(),
 	}
 	js.jobs[id] = job
-	return job
+
+	snapshot := *job
+	return &snapshot
 }
 
-// GetJob retrieves a job by ID
-func (js *JobStore) GetJob(id string) (*ProcessingJob, bool) {
+// GetJob retrieves a snapshot of a job by ID
+func (js *MemoryJobStore) GetJob(id string) (*ProcessingJob, bool) {
 	js.mu.RLock()
 	defer js.mu.RUnlock()
 
 	job, exists := js.jobs[id]
-	return job, exists
+	if !exists {
+		return nil, false
+	}
+
+	// Return a copy so callers never observe concurrent updates
+	snapshot := *job
+	return &snapshot, true
 }
 
-// UpdateJobStatus updates the status of a job
-func (js *JobStore) UpdateJobStatus(id string, status JobStatus, filePath string, errorMsg string) {
-	js.mu.Lock()
-	defer js.mu.Unlock()
+// RecordUpload attaches details of the uploaded file to a job
+func (js *MemoryJobStore) RecordUpload(id string, upload UploadInfo) {
+	js.update(id, func(job *ProcessingJob) {
+		job.Upload = &upload
+	})
+}
 
-	if job, exists := js.jobs[id]; exists {
+// StartJob marks a job as processing and records its start time
+func (js *MemoryJobStore) StartJob(id string) {
+	js.update(id, func(job *ProcessingJob) {
+		now := time.Now()
+		job.Status = JobStatusProcessing
+		job.StartedAt = &now
+	})
+}
+
+// UpdateJobStatus updates the status of a job
+func (js *MemoryJobStore) UpdateJobStatus(id string, status JobStatus, filePath string, errorMsg string) {
+	js.update(id, func(job *ProcessingJob) {
 		job.Status = status
 		if filePath != "" {
 			job.FilePath = filePath
@@ -82,5 +181,85 @@ func (js *JobStore) UpdateJobStatus(id string, status JobStatus, filePath string
 		if errorMsg != "" {
 			job.Error = errorMsg
 		}
+		if job.IsFinished() && job.FinishedAt == nil {
+			now := time.Now()
+			job.FinishedAt = &now
+		}
+		if status == JobStatusCompleted {
+			job.PercentComplete = 100
+		}
+	})
+}
+
+// UpdateJobProgress records the latest progress report for a job
+func (js *MemoryJobStore) UpdateJobProgress(id string, progress ProcessingProgress) {
+	js.update(id, func(job *ProcessingJob) {
+		job.RowsProcessed = progress.RowsProcessed
+		job.RowsWithEmail = progress.RowsWithEmail
+		job.RowErrors = progress.RowErrors
+		job.BytesRead = progress.BytesRead
+		job.TotalBytes = progress.TotalBytes
+		if progress.TotalBytes > 0 {
+			job.PercentComplete = math.Min(100, float64(progress.BytesRead)*100/float64(progress.TotalBytes))
+		}
+	})
+}
+
+// ListJobs returns snapshots of all stored jobs
+func (js *MemoryJobStore) ListJobs() []*ProcessingJob {
+	js.mu.RLock()
+	defer js.mu.RUnlock()
+
+	jobs := make([]*ProcessingJob, 0, len(js.jobs))
+	for _, job := range js.jobs {
+		snapshot := *job
+		jobs = append(jobs, &snapshot)
+	}
+	return jobs
+}
+
+// DeleteJob removes a job from the store
+func (js *MemoryJobStore) DeleteJob(id string) {
+	js.mu.Lock()
+	defer js.mu.Unlock()
+
+	delete(js.jobs, id)
+}
+
+// Close releases resources held by the store
+func (js *MemoryJobStore) Close() error {
+	return nil
+}
+
+// update applies fn to a job under the write lock and returns the resulting snapshot
+func (js *MemoryJobStore) update(id string, fn func(job *ProcessingJob)) (ProcessingJob, bool) {
+	js.mu.Lock()
+	defer js.mu.Unlock()
+
+	job, exists := js.jobs[id]
+	if !exists {
+		return ProcessingJob{}, false
+	}
+	fn(job)
+	return *job, true
+}
+
+// put stores a copy of job, replacing any existing job with the same ID
+func (js *MemoryJobStore) put(job ProcessingJob) {
+	js.mu.Lock()
+	defer js.mu.Unlock()
+
+	js.jobs[job.ID] = &job
+}
+
+// list returns snapshots of all stored jobs
+func (js *MemoryJobStore) list() []ProcessingJob {
+	js.mu.RLock()
+	defer js.mu.RUnlock()
+
+	jobs := make([]ProcessingJob, 0, len(js.jobs))
+	for _, job := range js.jobs {
+		jobs = append(jobs, *job)
 	}
+	return jobs
 }
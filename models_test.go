@@ -32,8 +32,8 @@ func TestCreateJob(t *testing.T) {
 	if job.ID != jobID {
 		t.Errorf("Job ID mismatch. Expected: %s, Got: %s", jobID, job.ID)
 	}
-	if job.Status != JobStatusProcessing {
-		t.Errorf("Job status mismatch. Expected: %s, Got: %s", JobStatusProcessing, job.Status)
+	if job.Status != JobStatusQueued {
+		t.Errorf("Job status mismatch. Expected: %s, Got: %s", JobStatusQueued, job.Status)
 	}
 	if job.CreatedAt.IsZero() {
 		t.Error("Job CreatedAt is zero")
@@ -128,6 +128,9 @@ func TestUpdateJobStatus(t *testing.T) {
 
 func TestJobStatusConstants(t *testing.T) {
 	// Test that constants have expected values
+	if JobStatusQueued != "queued" {
+		t.Errorf("JobStatusQueued mismatch. Expected: queued, Got: %s", JobStatusQueued)
+	}
 	if JobStatusProcessing != "processing" {
 		t.Errorf("JobStatusProcessing mismatch. Expected: processing, Got: %s", JobStatusProcessing)
 	}
@@ -137,6 +140,12 @@ func TestJobStatusConstants(t *testing.T) {
 	if JobStatusFailed != "failed" {
 		t.Errorf("JobStatusFailed mismatch. Expected: failed, Got: %s", JobStatusFailed)
 	}
+	if JobStatusCancelled != "cancelled" {
+		t.Errorf("JobStatusCancelled mismatch. Expected: cancelled, Got: %s", JobStatusCancelled)
+	}
+	if JobStatusExpired != "expired" {
+		t.Errorf("JobStatusExpired mismatch. Expected: expired, Got: %s", JobStatusExpired)
+	}
 }
 
 func TestProcessingJobStruct(t *testing.T) {
@@ -272,3 +281,97 @@ func TestJobStoreMultipleOperations(t *testing.T) {
 		}
 	}
 }
+
+func TestGetJobReturnsSnapshot(t *testing.T) {
+	store := NewJobStore()
+	store.CreateJob("job1")
+
+	// Mutating a retrieved job must not affect the stored job
+	job, _ := store.GetJob("job1")
+	job.Status = JobStatusFailed
+
+	stored, _ := store.GetJob("job1")
+	if stored.Status != JobStatusQueued {
+		t.Errorf("Stored job was modified through snapshot. Expected: %s, Got: %s", JobStatusQueued, stored.Status)
+	}
+}
+
+func TestStartJob(t *testing.T) {
+	store := NewJobStore()
+	store.CreateJob("job1")
+
+	store.StartJob("job1")
+
+	job, _ := store.GetJob("job1")
+	if job.Status != JobStatusProcessing {
+		t.Errorf("Job status mismatch. Expected: %s, Got: %s", JobStatusProcessing, job.Status)
+	}
+	if job.StartedAt == nil {
+		t.Error("Job StartedAt should be set")
+	}
+	if job.FinishedAt != nil {
+		t.Error("Job FinishedAt should not be set")
+	}
+
+	// Finishing the job records the finish time
+	store.UpdateJobStatus("job1", JobStatusCompleted, "/path/file.csv", "")
+
+	job, _ = store.GetJob("job1")
+	if job.FinishedAt == nil {
+		t.Error("Job FinishedAt should be set")
+	}
+	if job.PercentComplete != 100 {
+		t.Errorf("PercentComplete mismatch. Expected: 100, Got: %v", job.PercentComplete)
+	}
+}
+
+func TestUpdateJobProgress(t *testing.T) {
+	store := NewJobStore()
+	store.CreateJob("job1")
+
+	store.UpdateJobProgress("job1", ProcessingProgress{
+		RowsProcessed: 10,
+		RowsWithEmail: 7,
+		BytesRead:     250,
+		TotalBytes:    1000,
+	})
+
+	job, _ := store.GetJob("job1")
+	if job.RowsProcessed != 10 {
+		t.Errorf("RowsProcessed mismatch. Expected: 10, Got: %d", job.RowsProcessed)
+	}
+	if job.RowsWithEmail != 7 {
+		t.Errorf("RowsWithEmail mismatch. Expected: 7, Got: %d", job.RowsWithEmail)
+	}
+	if job.BytesRead != 250 {
+		t.Errorf("BytesRead mismatch. Expected: 250, Got: %d", job.BytesRead)
+	}
+	if job.PercentComplete != 25 {
+		t.Errorf("PercentComplete mismatch. Expected: 25, Got: %v", job.PercentComplete)
+	}
+
+	// Updating non-existent job (should not panic)
+	store.UpdateJobProgress("non-existent-job", ProcessingProgress{})
+}
+
+func TestListAndDeleteJobs(t *testing.T) {
+	store := NewJobStore()
+	store.CreateJob("job1")
+	store.CreateJob("job2")
+
+	jobs := store.ListJobs()
+	if len(jobs) != 2 {
+		t.Fatalf("Expected 2 jobs, got %d", len(jobs))
+	}
+
+	store.DeleteJob("job1")
+	if _, exists := store.GetJob("job1"); exists {
+		t.Error("Deleted job should not exist")
+	}
+	if len(store.ListJobs()) != 1 {
+		t.Errorf("Expected 1 job after delete, got %d", len(store.ListJobs()))
+	}
+
+	// Deleting non-existent job (should not panic)
+	store.DeleteJob("non-existent-job")
+}
@@ -0,0 +1,143 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+)
+
+// defaultOutputColumn is the name of the column appended in OutputHasEmail mode
+const defaultOutputColumn = "has_email"
+
+// CollisionMode selects what happens when an appended column has the name
+// of a column already in the header
+type CollisionMode string
+
+const (
+	// CollisionSuffix appends the column as <name>_2, <name>_3 and so on
+	CollisionSuffix CollisionMode = "suffix"
+
+	// CollisionOverwrite writes the results into the existing column
+	CollisionOverwrite CollisionMode = "overwrite"
+
+	// CollisionFail rejects the file
+	CollisionFail CollisionMode = "fail"
+)
+
+// ParseCollisionMode parses a collision mode name, defaulting to CollisionSuffix when empty
+func ParseCollisionMode(name string) (CollisionMode, error) {
+	switch mode := CollisionMode(strings.ToLower(strings.TrimSpace(name))); mode {
+	case "":
+		return CollisionSuffix, nil
+	case CollisionSuffix, CollisionOverwrite, CollisionFail:
+		return mode, nil
+	default:
+		return "", fmt.Errorf("unknown collision mode %q", name)
+	}
+}
+
+// ColumnCollisionError is returned in CollisionFail mode when an appended
+// column has the name of a column already in the header
+type ColumnCollisionError struct {
+	Name string
+}
+
+func (e *ColumnCollisionError) Error() string {
+	return fmt.Sprintf("output column %q already exists", e.Name)
+}
+
+// planColumns works out the output columns for a file whose first row is
+// first, a header only when hasHeader is set. It returns the target
+// columns, the header row to write, or nil when there is none, and where
+// each result value goes; see placeValues.
+func planColumns(first []string, hasHeader bool, opts ProcessOptions, extras []extraColumn) ([]int, []string, []int, error) {
+	targets, err := resolveTargets(first, hasHeader, opts)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	if opts.Output == OutputColumns && targets == nil {
+		targets = allColumns(first)
+	}
+	if !hasHeader {
+		return targets, nil, nil, nil
+	}
+
+	var names []string
+	if opts.Output == OutputColumns {
+		names = columnHeaders(first, targets, opts, extras)
+	} else {
+		name := opts.OutputColumn
+		if name == "" {
+			name = defaultOutputColumn
+		}
+		names = append(names, name)
+		if opts.Extract != ExtractOff {
+			names = append(names, "extracted_emails")
+		}
+		for _, extra := range extras {
+			names = append(names, extra.name)
+		}
+	}
+
+	header, positions, err := placeColumns(first, names, opts.OnCollision)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	return targets, header, positions, nil
+}
+
+// placeColumns adds names to header, resolving names already in it as
+// mode says. Appended columns whose names clash with each other are always
+// suffixed. It returns the new header and the index of each name in it.
+func placeColumns(header []string, names []string, mode CollisionMode) ([]string, []int, error) {
+	existing := make(map[string]int, len(header))
+	for i, column := range header {
+		if _, exists := existing[strings.TrimSpace(column)]; !exists {
+			existing[strings.TrimSpace(column)] = i
+		}
+	}
+
+	placed := append([]string(nil), header...)
+	positions := make([]int, len(names))
+	taken := make(map[int]bool)
+	for i, name := range names {
+		if index, exists := existing[name]; exists && !taken[index] {
+			switch mode {
+			case CollisionFail:
+				return nil, nil, &ColumnCollisionError{Name: name}
+			case CollisionOverwrite:
+				positions[i] = index
+				taken[index] = true
+				continue
+			}
+		}
+
+		// Suffix the name until it is free
+		unique := name
+		for n := 2; ; n++ {
+			if _, exists := existing[unique]; !exists {
+				break
+			}
+			unique = fmt.Sprintf("%s_%d", name, n)
+		}
+		existing[unique] = len(placed)
+		taken[len(placed)] = true
+		positions[i] = len(placed)
+		placed = append(placed, unique)
+	}
+	return placed, positions, nil
+}
+
+// placeValues writes each value into record at its position, or appends
+// them all when positions is nil
+func placeValues(record []string, values []string, positions []int) []string {
+	if positions == nil {
+		return append(record, values...)
+	}
+	for i, value := range values {
+		for len(record) <= positions[i] {
+			record = append(record, "")
+		}
+		record[positions[i]] = value
+	}
+	return record
+}
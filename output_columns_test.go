@@ -0,0 +1,134 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseCollisionMode(t *testing.T) {
+	tests := []struct {
+		input       string
+		expected    CollisionMode
+		expectError bool
+	}{
+		{"", CollisionSuffix, false},
+		{"suffix", CollisionSuffix, false},
+		{" Overwrite ", CollisionOverwrite, false},
+		{"fail", CollisionFail, false},
+		{"replace", "", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.input, func(t *testing.T) {
+			mode, err := ParseCollisionMode(tt.input)
+			if tt.expectError {
+				if err == nil {
+					t.Error("Expected error, got nil")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("ParseCollisionMode failed: %v", err)
+			}
+			if mode != tt.expected {
+				t.Errorf("Mode mismatch. Expected: %s, Got: %s", tt.expected, mode)
+			}
+		})
+	}
+}
+
+func TestPlaceColumns(t *testing.T) {
+	tests := []struct {
+		name              string
+		header            []string
+		names             []string
+		mode              CollisionMode
+		expectedHeader    []string
+		expectedPositions []int
+		expectError       bool
+	}{
+		{
+			name:              "No collision",
+			header:            []string{"name", "email"},
+			names:             []string{"has_email"},
+			mode:              CollisionFail,
+			expectedHeader:    []string{"name", "email", "has_email"},
+			expectedPositions: []int{2},
+		},
+		{
+			name:              "Suffix",
+			header:            []string{"has_email", "has_email_2"},
+			names:             []string{"has_email"},
+			mode:              CollisionSuffix,
+			expectedHeader:    []string{"has_email", "has_email_2", "has_email_3"},
+			expectedPositions: []int{2},
+		},
+		{
+			name:              "Overwrite",
+			header:            []string{"email", " has_email ", "notes"},
+			names:             []string{"has_email", "email_ascii"},
+			mode:              CollisionOverwrite,
+			expectedHeader:    []string{"email", " has_email ", "notes", "email_ascii"},
+			expectedPositions: []int{1, 3},
+		},
+		{
+			name:              "Appended names clash",
+			header:            []string{"x"},
+			names:             []string{"x", "x"},
+			mode:              CollisionOverwrite,
+			expectedHeader:    []string{"x", "x_2"},
+			expectedPositions: []int{0, 1},
+		},
+		{
+			name:        "Fail",
+			header:      []string{"email", "has_email"},
+			names:       []string{"has_email"},
+			mode:        CollisionFail,
+			expectError: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			header, positions, err := placeColumns(tt.header, tt.names, tt.mode)
+			if tt.expectError {
+				if err == nil {
+					t.Error("Expected error, got nil")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("placeColumns failed: %v", err)
+			}
+			if !reflect.DeepEqual(header, tt.expectedHeader) {
+				t.Errorf("Header mismatch. Expected: %q, Got: %q", tt.expectedHeader, header)
+			}
+			if !reflect.DeepEqual(positions, tt.expectedPositions) {
+				t.Errorf("Positions mismatch. Expected: %v, Got: %v", tt.expectedPositions, positions)
+			}
+		})
+	}
+}
+
+func TestPlaceValues(t *testing.T) {
+	tests := []struct {
+		name      string
+		record    []string
+		values    []string
+		positions []int
+		expected  []string
+	}{
+		{"Append", []string{"a"}, []string{"x", "y"}, nil, []string{"a", "x", "y"}},
+		{"Overwrite", []string{"a", "b"}, []string{"x"}, []int{0}, []string{"x", "b"}},
+		{"Short row", []string{"a"}, []string{"x"}, []int{2}, []string{"a", "", "x"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			record := placeValues(tt.record, tt.values, tt.positions)
+			if !reflect.DeepEqual(record, tt.expected) {
+				t.Errorf("Record mismatch. Expected: %q, Got: %q", tt.expected, record)
+			}
+		})
+	}
+}
@@ -0,0 +1,154 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
+)
+
+var (
+	// ErrQueueFull is returned when the job queue cannot accept another job
+	ErrQueueFull = errors.New("job queue is full")
+
+	// ErrQueueClosed is returned when the job queue is shutting down
+	ErrQueueClosed = errors.New("job queue is closed")
+)
+
+// processingTask describes an uploaded file waiting to be processed
+type processingTask struct {
+	JobID      string
+	UploadPath string
+	Options    ProcessOptions
+}
+
+// QueueStats reports the current state of the job queue
+type QueueStats struct {
+	Depth    int `json:"depth"`
+	Capacity int `json:"capacity"`
+	Workers  int `json:"workers"`
+	Active   int `json:"active"`
+}
+
+// JobQueue is a bounded queue of processing tasks served by a fixed pool of workers
+type JobQueue struct {
+	tasks   chan processingTask
+	workers int
+	active  int64
+	handler func(task processingTask)
+	discard func(task processingTask)
+	closed  bool
+	mu      sync.RWMutex
+	wg      sync.WaitGroup
+}
+
+// NewJobQueue creates a job queue holding up to capacity waiting tasks and
+// starts workers goroutines that pass each task to handler
+func NewJobQueue(workers, capacity int, handler func(task processingTask)) *JobQueue {
+	if workers < 1 {
+		workers = 1
+	}
+	if capacity < 1 {
+		capacity = 1
+	}
+
+	q := &JobQueue{
+		tasks:   make(chan processingTask, capacity),
+		workers: workers,
+		handler: handler,
+	}
+
+	for i := 0; i < workers; i++ {
+		q.wg.Add(1)
+		go q.worker()
+	}
+
+	return q
+}
+
+// Enqueue adds a task to the queue without blocking
+func (q *JobQueue) Enqueue(task processingTask) error {
+	q.mu.RLock()
+	defer q.mu.RUnlock()
+
+	if q.closed {
+		return ErrQueueClosed
+	}
+
+	select {
+	case q.tasks <- task:
+		return nil
+	default:
+		return ErrQueueFull
+	}
+}
+
+// IsFull reports whether the queue currently has no room for another task
+func (q *JobQueue) IsFull() bool {
+	return len(q.tasks) >= cap(q.tasks)
+}
+
+// Stats returns the current queue depth and worker utilisation
+func (q *JobQueue) Stats() QueueStats {
+	return QueueStats{
+		Depth:    len(q.tasks),
+		Capacity: cap(q.tasks),
+		Workers:  q.workers,
+		Active:   int(atomic.LoadInt64(&q.active)),
+	}
+}
+
+// Shutdown stops accepting tasks, hands every task still waiting to discard
+// and waits for running tasks to finish or ctx to be done
+func (q *JobQueue) Shutdown(ctx context.Context, discard func(task processingTask)) error {
+	q.mu.Lock()
+	if !q.closed {
+		q.closed = true
+		q.discard = discard
+		close(q.tasks)
+	}
+	q.mu.Unlock()
+
+	return q.Wait(ctx)
+}
+
+// Wait blocks until all workers have exited after Shutdown or ctx is done
+func (q *JobQueue) Wait(ctx context.Context) error {
+	done := make(chan struct{})
+	go func() {
+		q.wg.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// worker processes tasks until the queue is closed
+func (q *JobQueue) worker() {
+	defer q.wg.Done()
+
+	for task := range q.tasks {
+		// Tasks still waiting at shutdown are not started
+		if discard := q.discardFunc(); discard != nil {
+			discard(task)
+			continue
+		}
+
+		atomic.AddInt64(&q.active, 1)
+		q.handler(task)
+		atomic.AddInt64(&q.active, -1)
+	}
+}
+
+// discardFunc returns the shutdown discard function, or nil while the queue is open
+func (q *JobQueue) discardFunc() func(task processingTask) {
+	q.mu.RLock()
+	defer q.mu.RUnlock()
+
+	return q.discard
+}
@@ -0,0 +1,163 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestNewJobQueue(t *testing.T) {
+	queue := NewJobQueue(0, 0, func(task processingTask) {})
+	if queue == nil {
+		t.Fatal("NewJobQueue() returned nil")
+	}
+
+	stats := queue.Stats()
+	if stats.Workers != 1 {
+		t.Errorf("Workers mismatch. Expected: 1, Got: %d", stats.Workers)
+	}
+	if stats.Capacity != 1 {
+		t.Errorf("Capacity mismatch. Expected: 1, Got: %d", stats.Capacity)
+	}
+}
+
+func TestJobQueueProcessesTasks(t *testing.T) {
+	var mu sync.Mutex
+	processed := make(map[string]bool)
+	var wg sync.WaitGroup
+
+	queue := NewJobQueue(3, 10, func(task processingTask) {
+		defer wg.Done()
+		mu.Lock()
+		processed[task.JobID] = true
+		mu.Unlock()
+	})
+
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		if err := queue.Enqueue(processingTask{JobID: fmt.Sprintf("job-%d", i)}); err != nil {
+			t.Fatalf("Enqueue %d failed: %v", i, err)
+		}
+	}
+	wg.Wait()
+
+	if len(processed) != 10 {
+		t.Errorf("Expected 10 processed tasks, got %d", len(processed))
+	}
+}
+
+func TestJobQueueFull(t *testing.T) {
+	started := make(chan struct{}, 3)
+	release := make(chan struct{})
+	defer close(release)
+
+	queue := NewJobQueue(1, 2, func(task processingTask) {
+		started <- struct{}{}
+		<-release
+	})
+
+	// First task occupies the only worker
+	if err := queue.Enqueue(processingTask{JobID: "running"}); err != nil {
+		t.Fatalf("Enqueue failed: %v", err)
+	}
+	select {
+	case <-started:
+	case <-time.After(time.Second):
+		t.Fatal("Worker did not pick up task")
+	}
+
+	// Next two tasks fill the queue
+	for i := 0; i < 2; i++ {
+		if err := queue.Enqueue(processingTask{JobID: fmt.Sprintf("waiting-%d", i)}); err != nil {
+			t.Fatalf("Enqueue %d failed: %v", i, err)
+		}
+	}
+
+	if !queue.IsFull() {
+		t.Error("Queue should report full")
+	}
+	if err := queue.Enqueue(processingTask{JobID: "rejected"}); err != ErrQueueFull {
+		t.Errorf("Expected ErrQueueFull, got %v", err)
+	}
+
+	stats := queue.Stats()
+	if stats.Depth != 2 {
+		t.Errorf("Depth mismatch. Expected: 2, Got: %d", stats.Depth)
+	}
+	if stats.Active != 1 {
+		t.Errorf("Active mismatch. Expected: 1, Got: %d", stats.Active)
+	}
+}
+
+func TestJobQueueShutdown(t *testing.T) {
+	started := make(chan struct{}, 1)
+	release := make(chan struct{})
+
+	var mu sync.Mutex
+	var handled, discarded []string
+
+	queue := NewJobQueue(1, 5, func(task processingTask) {
+		started <- struct{}{}
+		<-release
+		mu.Lock()
+		handled = append(handled, task.JobID)
+		mu.Unlock()
+	})
+
+	queue.Enqueue(processingTask{JobID: "running"})
+	<-started
+	queue.Enqueue(processingTask{JobID: "waiting-1"})
+	queue.Enqueue(processingTask{JobID: "waiting-2"})
+
+	// Let the running task finish shortly after shutdown begins
+	go func() {
+		time.Sleep(10 * time.Millisecond)
+		close(release)
+	}()
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	err := queue.Shutdown(ctx, func(task processingTask) {
+		mu.Lock()
+		discarded = append(discarded, task.JobID)
+		mu.Unlock()
+	})
+	if err != nil {
+		t.Fatalf("Shutdown failed: %v", err)
+	}
+
+	if len(handled) != 1 || handled[0] != "running" {
+		t.Errorf("Expected only the running task to be handled, got %v", handled)
+	}
+	if len(discarded) != 2 {
+		t.Errorf("Expected 2 discarded tasks, got %v", discarded)
+	}
+
+	if err := queue.Enqueue(processingTask{JobID: "late"}); err != ErrQueueClosed {
+		t.Errorf("Expected ErrQueueClosed, got %v", err)
+	}
+}
+
+func TestJobQueueShutdownDeadline(t *testing.T) {
+	release := make(chan struct{})
+	defer close(release)
+
+	started := make(chan struct{}, 1)
+	queue := NewJobQueue(1, 1, func(task processingTask) {
+		started <- struct{}{}
+		<-release
+	})
+	queue.Enqueue(processingTask{JobID: "stuck"})
+	<-started
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+
+	err := queue.Shutdown(ctx, func(task processingTask) {})
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("Expected context.DeadlineExceeded, got %v", err)
+	}
+}
@@ -0,0 +1,72 @@
+package main
+
+import (
+	_ "embed"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+	"unicode/utf8"
+
+	"golang.org/x/text/unicode/norm"
+)
+
+//go:embed lists/role_accounts.txt
+var defaultRoleAccounts string
+
+// RoleAccountList recognises local parts that belong to a function rather
+// than a person, such as info@, admin@ or noreply@
+type RoleAccountList struct {
+	*reloadableSet
+}
+
+// NewRoleAccountList creates a list of the local parts in defaults, one per
+// line, plus those in the file at path when it is not empty. The file is
+// checked for changes at most once per interval while the list is in use.
+func NewRoleAccountList(defaults, path string, interval time.Duration) (*RoleAccountList, error) {
+	set, err := newReloadableSet(defaults, path, interval, normalizeListLocalPart)
+	if err != nil {
+		return nil, err
+	}
+	return &RoleAccountList{set}, nil
+}
+
+// defaultRoleAccountList is the embedded list, shared by validators that are not given their own
+var defaultRoleAccountList = sync.OnceValue(func() *RoleAccountList {
+	list, err := NewRoleAccountList(defaultRoleAccounts, "", 0)
+	if err != nil {
+		panic(err)
+	}
+	return list
+})
+
+// Contains reports whether localPart names a role account
+func (rl *RoleAccountList) Contains(localPart string) bool {
+	_, exists := rl.snapshot()[roleKey(localPart)]
+	return exists
+}
+
+// roleKey reduces a local part to the form role accounts are matched on:
+// lower case, without a +tag and without dots, hyphens or underscores
+func roleKey(localPart string) string {
+	localPart = strings.ToLower(norm.NFC.String(localPart))
+	if plus := strings.IndexByte(localPart, '+'); plus > 0 {
+		localPart = localPart[:plus]
+	}
+	return strings.Map(func(r rune) rune {
+		switch r {
+		case '.', '-', '_':
+			return -1
+		}
+		return r
+	}, localPart)
+}
+
+// normalizeListLocalPart converts a listed local part to its matching form
+func normalizeListLocalPart(text string) (string, error) {
+	key := roleKey(text)
+	if key == "" || !utf8.ValidString(key) || strings.ContainsAny(key, "@ \t") {
+		return "", fmt.Errorf("invalid local part %q", text)
+	}
+	return key, nil
+}
@@ -0,0 +1,57 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestRoleAccountListContains(t *testing.T) {
+	list := defaultRoleAccountList()
+
+	tests := []struct {
+		localPart string
+		expected  bool
+	}{
+		{"info", true},
+		{"INFO", true},
+		{"noreply", true},
+		{"no-reply", true},
+		{"No_Reply+billing", true},
+		{"do.not.reply", true},
+		{"donotreply", true},
+		{"john", false},
+		{"information", false},
+		{"john+info", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.localPart, func(t *testing.T) {
+			if got := list.Contains(tt.localPart); got != tt.expected {
+				t.Errorf("Contains mismatch. Expected: %t, Got: %t", tt.expected, got)
+			}
+		})
+	}
+}
+
+func TestRoleAccountListFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "roles.txt")
+	if err := os.WriteFile(path, []byte("# custom roles\ncompliance\nit-desk\n"), 0644); err != nil {
+		t.Fatalf("Failed to write list: %v", err)
+	}
+
+	list, err := NewRoleAccountList("info\n", path, time.Hour)
+	if err != nil {
+		t.Fatalf("NewRoleAccountList failed: %v", err)
+	}
+	for _, localPart := range []string{"info", "compliance", "IT.Desk"} {
+		if !list.Contains(localPart) {
+			t.Errorf("Expected %s to be a role account", localPart)
+		}
+	}
+
+	if _, err := NewRoleAccountList("info@example.com\n", "", 0); err == nil {
+		t.Error("Expected error for an address in the list")
+	}
+}
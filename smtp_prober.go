@@ -0,0 +1,264 @@
+package main
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net"
+	"net/netip"
+	"net/smtp"
+	"net/textproto"
+	"strings"
+	"syscall"
+	"time"
+)
+
+const (
+	// defaultSMTPTimeout bounds a whole probe, from connecting to QUIT
+	defaultSMTPTimeout = 10 * time.Second
+
+	// defaultSMTPMaxConcurrent is the number of probes allowed in flight at once
+	defaultSMTPMaxConcurrent = 4
+
+	// defaultSMTPHeloName is the name sent in EHLO
+	defaultSMTPHeloName = "localhost"
+
+	// defaultSMTPMailFrom is the envelope sender used for probes
+	defaultSMTPMailFrom = "verify@localhost"
+
+	// smtpPort is the port mail exchangers listen on
+	smtpPort = "25"
+
+	// maxMXAttempts is the number of mail exchangers tried before giving up
+	maxMXAttempts = 3
+)
+
+// MailboxStatus classifies whether a mailbox accepts mail
+type MailboxStatus string
+
+const (
+	// MailboxDeliverable means the server accepted the recipient and rejected a made-up one
+	MailboxDeliverable MailboxStatus = "deliverable"
+
+	// MailboxUndeliverable means the server permanently rejected the recipient
+	MailboxUndeliverable MailboxStatus = "undeliverable"
+
+	// MailboxCatchAll means the server accepts any recipient at the domain
+	MailboxCatchAll MailboxStatus = "catch_all"
+
+	// MailboxUnknown means the server could not be reached or gave a temporary answer
+	MailboxUnknown MailboxStatus = "unknown"
+)
+
+// SMTPProberOptions configures an SMTPProber
+type SMTPProberOptions struct {
+	// Server, if set, is the host:port every probe connects to instead of
+	// the domain's mail exchangers
+	Server string
+
+	// Resolver is the host:port of the DNS server used to find mail
+	// exchangers; the system resolver is used when empty
+	Resolver string
+
+	HeloName      string
+	MailFrom      string
+	Timeout       time.Duration
+	MaxConcurrent int
+}
+
+// SMTPProber checks whether mailboxes exist by starting an SMTP transaction
+// and stopping after RCPT TO, so no message is ever sent
+type SMTPProber struct {
+	server   string
+	resolver *net.Resolver
+	heloName string
+	mailFrom string
+	timeout  time.Duration
+
+	// slots bounds the number of concurrent probes
+	slots chan struct{}
+}
+
+// NewSMTPProber creates an SMTP prober, filling in defaults for unset options
+func NewSMTPProber(opts SMTPProberOptions) *SMTPProber {
+	if opts.HeloName == "" {
+		opts.HeloName = defaultSMTPHeloName
+	}
+	if opts.MailFrom == "" {
+		opts.MailFrom = defaultSMTPMailFrom
+	}
+	if opts.Timeout <= 0 {
+		opts.Timeout = defaultSMTPTimeout
+	}
+	if opts.MaxConcurrent < 1 {
+		opts.MaxConcurrent = defaultSMTPMaxConcurrent
+	}
+
+	return &SMTPProber{
+		server:   opts.Server,
+		resolver: newResolver(opts.Resolver),
+		heloName: opts.HeloName,
+		mailFrom: opts.MailFrom,
+		timeout:  opts.Timeout,
+		slots:    make(chan struct{}, opts.MaxConcurrent),
+	}
+}
+
+// Probe classifies the mailbox of a syntactically valid address
+func (p *SMTPProber) Probe(ctx context.Context, address string) MailboxStatus {
+	at := strings.LastIndex(address, "@")
+	if at < 0 {
+		return MailboxUnknown
+	}
+	domain := address[at+1:]
+
+	// Wait for a free probe slot
+	select {
+	case p.slots <- struct{}{}:
+		defer func() { <-p.slots }()
+	case <-ctx.Done():
+		return MailboxUnknown
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, p.timeout)
+	defer cancel()
+
+	hosts, err := p.mailHosts(ctx, domain)
+	if err != nil {
+		return MailboxUnknown
+	}
+
+	// Try the next exchanger only when one cannot be reached at all
+	for _, host := range hosts {
+		conn, err := p.dial(ctx, host)
+		if err != nil {
+			continue
+		}
+		return p.probe(ctx, conn, host, address, domain)
+	}
+	return MailboxUnknown
+}
+
+// mailHosts returns the host:port addresses to probe for domain, in order of preference
+func (p *SMTPProber) mailHosts(ctx context.Context, domain string) ([]string, error) {
+	if p.server != "" {
+		return []string{p.server}, nil
+	}
+
+	// Domain literals would let uploads pick any address to connect to
+	if strings.HasPrefix(domain, "[") {
+		return nil, errors.New("domain literals are only probed through smtp.server")
+	}
+
+	records, err := p.resolver.LookupMX(ctx, domain)
+	if err != nil && !isNotFound(err) {
+		return nil, err
+	}
+
+	var hosts []string
+	for _, record := range records {
+		host := strings.TrimSuffix(record.Host, ".")
+		if host == "" {
+			// Null MX: the domain accepts no mail
+			return nil, errors.New("domain publishes a null MX")
+		}
+		hosts = append(hosts, net.JoinHostPort(host, smtpPort))
+		if len(hosts) == maxMXAttempts {
+			break
+		}
+	}
+
+	// Without MX records mail goes to the domain itself
+	if len(hosts) == 0 {
+		hosts = append(hosts, net.JoinHostPort(domain, smtpPort))
+	}
+	return hosts, nil
+}
+
+// dial connects to host and applies the probe deadline to the connection.
+// Unless smtp.server is set, internal addresses are refused.
+func (p *SMTPProber) dial(ctx context.Context, host string) (net.Conn, error) {
+	var dialer net.Dialer
+	if p.server == "" {
+		dialer.Control = refuseInternalAddress
+	}
+	conn, err := dialer.DialContext(ctx, "tcp", host)
+	if err != nil {
+		return nil, err
+	}
+	if deadline, ok := ctx.Deadline(); ok {
+		conn.SetDeadline(deadline)
+	}
+	return conn, nil
+}
+
+// probe runs EHLO, MAIL FROM and RCPT TO on conn, then checks a made-up
+// recipient at the same domain to detect catch-all servers
+func (p *SMTPProber) probe(ctx context.Context, conn net.Conn, host, address, domain string) MailboxStatus {
+	// Unblock the conversation as soon as ctx is done
+	stop := context.AfterFunc(ctx, func() { conn.Close() })
+	defer stop()
+
+	serverName, _, _ := net.SplitHostPort(host)
+	client, err := smtp.NewClient(conn, serverName)
+	if err != nil {
+		conn.Close()
+		return MailboxUnknown
+	}
+	defer client.Close()
+
+	if err := client.Hello(p.heloName); err != nil {
+		return MailboxUnknown
+	}
+	if err := client.Mail(p.mailFrom); err != nil {
+		return MailboxUnknown
+	}
+
+	if err := client.Rcpt(address); err != nil {
+		if isPermanentFailure(err) {
+			client.Quit()
+			return MailboxUndeliverable
+		}
+		return MailboxUnknown
+	}
+
+	// A server that also takes a random recipient accepts everything
+	status := MailboxDeliverable
+	if err := client.Rcpt(randomLocalPart() + "@" + domain); err == nil {
+		status = MailboxCatchAll
+	}
+
+	client.Reset()
+	client.Quit()
+	return status
+}
+
+// refuseInternalAddress stops a dial to a loopback, private, link-local or
+// unspecified address, checked after resolution so DNS cannot point a probe
+// at an internal host
+func refuseInternalAddress(network, address string, _ syscall.RawConn) error {
+	addrPort, err := netip.ParseAddrPort(address)
+	if err != nil {
+		return err
+	}
+	ip := addrPort.Addr().Unmap()
+	if ip.IsLoopback() || ip.IsPrivate() || ip.IsLinkLocalUnicast() || ip.IsLinkLocalMulticast() || ip.IsUnspecified() {
+		return fmt.Errorf("refusing to probe internal address %s", ip)
+	}
+	return nil
+}
+
+// isPermanentFailure reports whether err is a 5xx SMTP reply
+func isPermanentFailure(err error) bool {
+	var protoErr *textproto.Error
+	return errors.As(err, &protoErr) && protoErr.Code >= 500 && protoErr.Code < 600
+}
+
+// randomLocalPart returns a local part that is very unlikely to exist
+func randomLocalPart() string {
+	buf := make([]byte, 8)
+	rand.Read(buf)
+	return "probe-" + hex.EncodeToString(buf)
+}
@@ -0,0 +1,286 @@
+package main
+
+import (
+	"context"
+	"net"
+	"net/textproto"
+	"strings"
+	"testing"
+	"time"
+)
+
+// fakeSMTPServer is a minimal SMTP server that answers RCPT TO from a fixed
+// set of mailboxes
+type fakeSMTPServer struct {
+	listener  net.Listener
+	mailboxes map[string]bool
+}
+
+// fakeSMTPDomains changes how the fake server answers recipients at a domain
+var fakeSMTPDomains = map[string]int{
+	"catchall.example": 250,
+	"greylist.example": 450,
+}
+
+// newFakeSMTPServer starts a fake SMTP server on a local port until the test ends
+func newFakeSMTPServer(t *testing.T, mailboxes ...string) *fakeSMTPServer {
+	t.Helper()
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Failed to listen: %v", err)
+	}
+	server := &fakeSMTPServer{listener: listener, mailboxes: make(map[string]bool)}
+	for _, mailbox := range mailboxes {
+		server.mailboxes[mailbox] = true
+	}
+	t.Cleanup(func() { listener.Close() })
+
+	go server.serve()
+	return server
+}
+
+// Addr returns the host:port the server listens on
+func (s *fakeSMTPServer) Addr() string {
+	return s.listener.Addr().String()
+}
+
+func (s *fakeSMTPServer) serve() {
+	for {
+		conn, err := s.listener.Accept()
+		if err != nil {
+			return
+		}
+		go s.handle(textproto.NewConn(conn))
+	}
+}
+
+// handle runs one SMTP session
+func (s *fakeSMTPServer) handle(conn *textproto.Conn) {
+	defer conn.Close()
+
+	conn.PrintfLine("220 fake.example ESMTP")
+	for {
+		line, err := conn.ReadLine()
+		if err != nil {
+			return
+		}
+		command := strings.ToUpper(strings.SplitN(line, " ", 2)[0])
+		switch command {
+		case "EHLO", "HELO", "MAIL", "RSET", "NOOP":
+			conn.PrintfLine("250 OK")
+		case "RCPT":
+			conn.PrintfLine("%d %s", s.rcptCode(line), "recipient")
+		case "QUIT":
+			conn.PrintfLine("221 Bye")
+			return
+		default:
+			conn.PrintfLine("502 Command not implemented")
+		}
+	}
+}
+
+// rcptCode returns the reply code for a RCPT TO command
+func (s *fakeSMTPServer) rcptCode(line string) int {
+	start, end := strings.Index(line, "<"), strings.LastIndex(line, ">")
+	if start < 0 || end < start {
+		return 501
+	}
+	address := strings.ToLower(line[start+1 : end])
+
+	if code, exists := fakeSMTPDomains[address[strings.LastIndex(address, "@")+1:]]; exists {
+		return code
+	}
+	if s.mailboxes[address] {
+		return 250
+	}
+	return 550
+}
+
+func TestSMTPProberProbe(t *testing.T) {
+	server := newFakeSMTPServer(t, "alice@example.com")
+	prober := NewSMTPProber(SMTPProberOptions{
+		Server:  server.Addr(),
+		Timeout: time.Second,
+	})
+
+	tests := []struct {
+		address  string
+		expected MailboxStatus
+	}{
+		{"alice@example.com", MailboxDeliverable},
+		{"bob@example.com", MailboxUndeliverable},
+		{"anyone@catchall.example", MailboxCatchAll},
+		{"alice@greylist.example", MailboxUnknown},
+		{"not-an-email", MailboxUnknown},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.address, func(t *testing.T) {
+			status := prober.Probe(context.Background(), tt.address)
+			if status != tt.expected {
+				t.Errorf("Status mismatch. Expected: %s, Got: %s", tt.expected, status)
+			}
+		})
+	}
+}
+
+func TestSMTPProberUnreachable(t *testing.T) {
+	// Reserve a port and close it so nothing is listening
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Failed to listen: %v", err)
+	}
+	addr := listener.Addr().String()
+	listener.Close()
+
+	prober := NewSMTPProber(SMTPProberOptions{Server: addr, Timeout: time.Second})
+	if status := prober.Probe(context.Background(), "alice@example.com"); status != MailboxUnknown {
+		t.Errorf("Expected %s, got %s", MailboxUnknown, status)
+	}
+}
+
+func TestSMTPProberTimeout(t *testing.T) {
+	// A server that accepts connections but never greets
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Failed to listen: %v", err)
+	}
+	t.Cleanup(func() { listener.Close() })
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			t.Cleanup(func() { conn.Close() })
+		}
+	}()
+
+	prober := NewSMTPProber(SMTPProberOptions{
+		Server:  listener.Addr().String(),
+		Timeout: 100 * time.Millisecond,
+	})
+
+	start := time.Now()
+	if status := prober.Probe(context.Background(), "alice@example.com"); status != MailboxUnknown {
+		t.Errorf("Expected %s, got %s", MailboxUnknown, status)
+	}
+	if elapsed := time.Since(start); elapsed > 5*time.Second {
+		t.Errorf("Probe ignored its timeout and took %v", elapsed)
+	}
+}
+
+func TestSMTPProberMailHosts(t *testing.T) {
+	server := newStubDNSServer(t, map[string]stubZone{
+		"mx.example":      {mx: []string{"mail1.mx.example", "mail2.mx.example"}},
+		"address.example": {a: []string{"192.0.2.1"}},
+		"nullmx.example":  {mx: []string{"."}},
+	})
+	prober := NewSMTPProber(SMTPProberOptions{Resolver: server.Addr(), Timeout: time.Second})
+
+	tests := []struct {
+		domain      string
+		expected    string
+		expectError bool
+	}{
+		{"mx.example", "mail1.mx.example:25,mail2.mx.example:25", false},
+		{"address.example", "address.example:25", false},
+		{"[192.0.2.1]", "", true},
+		{"[IPv6:2001:db8::1]", "", true},
+		{"[10.0.0.5]", "", true},
+		{"nullmx.example", "", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.domain, func(t *testing.T) {
+			hosts, err := prober.mailHosts(context.Background(), tt.domain)
+			if tt.expectError {
+				if err == nil {
+					t.Errorf("Expected error, got hosts %v", hosts)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("mailHosts failed: %v", err)
+			}
+			if strings.Join(hosts, ",") != tt.expected {
+				t.Errorf("Hosts mismatch. Expected: %s, Got: %s", tt.expected, strings.Join(hosts, ","))
+			}
+		})
+	}
+}
+
+func TestSMTPProberRelayMailHosts(t *testing.T) {
+	// A configured relay is trusted with every domain, literals included
+	prober := NewSMTPProber(SMTPProberOptions{Server: "relay.example:25", Timeout: time.Second})
+
+	hosts, err := prober.mailHosts(context.Background(), "[10.0.0.5]")
+	if err != nil {
+		t.Fatalf("mailHosts failed: %v", err)
+	}
+	if strings.Join(hosts, ",") != "relay.example:25" {
+		t.Errorf("Hosts mismatch. Expected: relay.example:25, Got: %s", strings.Join(hosts, ","))
+	}
+}
+
+func TestSMTPProberRefusesInternalAddresses(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Failed to listen: %v", err)
+	}
+	defer listener.Close()
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			conn.Close()
+		}
+	}()
+
+	ctx := context.Background()
+
+	// Without a relay, a domain resolving to loopback is not dialled
+	prober := NewSMTPProber(SMTPProberOptions{Timeout: time.Second})
+	if conn, err := prober.dial(ctx, listener.Addr().String()); err == nil {
+		conn.Close()
+		t.Error("Expected dial to a loopback address to be refused")
+	}
+
+	// An explicit relay may be internal
+	relayed := NewSMTPProber(SMTPProberOptions{Server: listener.Addr().String(), Timeout: time.Second})
+	conn, err := relayed.dial(ctx, listener.Addr().String())
+	if err != nil {
+		t.Fatalf("Dial to configured relay failed: %v", err)
+	}
+	conn.Close()
+
+	tests := []struct {
+		address string
+		refused bool
+	}{
+		{"127.0.0.1:25", true},
+		{"[::1]:25", true},
+		{"10.0.0.5:25", true},
+		{"192.168.1.1:25", true},
+		{"172.16.0.1:25", true},
+		{"169.254.169.254:25", true},
+		{"[fe80::1]:25", true},
+		{"[fd00::1]:25", true},
+		{"0.0.0.0:25", true},
+		{"[::ffff:10.0.0.5]:25", true},
+		{"192.0.2.1:25", false},
+		{"[2001:db8::1]:25", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.address, func(t *testing.T) {
+			err := refuseInternalAddress("tcp", tt.address, nil)
+			if refused := err != nil; refused != tt.refused {
+				t.Errorf("Refused mismatch. Expected: %v, Got: %v (%v)", tt.refused, refused, err)
+			}
+		})
+	}
+}